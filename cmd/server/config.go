@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// typedEnv are all environment variables that need parsing, together with a check that they parse.
+// Plain string variables can't be invalid, so they're not listed.
+var typedEnv = []struct {
+	name  string
+	check func(v string) error
+}{
+	{"ADMIN_PORT", checkInt},
+	{"ADMIN_SHUTDOWN_FIRST", checkBool},
+	{"BIND_RETRY", checkInt},
+	{"BIND_RETRY_DELAY", checkDuration},
+	{"COMPRESSION_ENABLED", checkBool},
+	{"CONFIG_CHECK", checkBool},
+	{"CONFIG_STRICT", checkBool},
+	{"DEBUG_BODIES", checkBool},
+	{"DEBUG_BODIES_MAX_BYTES", checkInt},
+	{"DEBUG_GC", checkBool},
+	{"DISABLE_DEFAULT_ROUTES", checkBool},
+	{"DISABLE_KEEP_ALIVES", checkBool},
+	{"H2C_ENABLED", checkBool},
+	{"HEALTH_CHECK_TIMEOUT", checkDuration},
+	{"IDLE_TIMEOUT", checkDuration},
+	{"LOG_BUFFER_SIZE", checkInt},
+	{"LOG_REQUESTS", checkBool},
+	{"LOG_SAMPLE_RATE", checkInt},
+	{"LOG_SPLIT_STREAMS", checkBool},
+	{"MAINTENANCE_MODE", checkBool},
+	{"MAX_CONCURRENT_REQUESTS", checkInt},
+	{"MAX_CONNECTIONS", checkInt},
+	{"MAX_HEADER_BYTES", checkInt},
+	{"MAX_LIFETIME", checkDuration},
+	{"MAX_REQUEST_BODY_BYTES", checkInt},
+	{"MEMORY_SHUTDOWN_THRESHOLD", checkInt},
+	{"METRICS_ENABLED", checkBool},
+	{"OTEL_ENABLED", checkBool},
+	{"PORT", checkInt},
+	{"PPROF_ENABLED", checkBool},
+	{"PRESTOP_DELAY", checkDuration},
+	{"PROXY_PROTOCOL", checkBool},
+	{"RATE_BURST", checkInt},
+	{"RATE_LIMIT", checkFloat},
+	{"READ_TIMEOUT", checkDuration},
+	{"REDIRECT_HTTP_TO_HTTPS", checkBool},
+	{"REQUEST_TIMEOUT", checkDuration},
+	{"ROUTE_CONCURRENCY_LIMITS", checkIntMap},
+	{"SECURITY_HEADERS", checkBool},
+	{"SHUTDOWN_TIMEOUT", checkDuration},
+	{"SLOW_REQUEST_THRESHOLD", checkDuration},
+	{"STREAM_DRAIN_TIMEOUT", checkDuration},
+	{"TCP_KEEP_ALIVE", checkDuration},
+	{"TRUSTED_PROXY_HOPS", checkInt},
+	{"WARMUP_TIMEOUT", checkDuration},
+	{"WORKER_POOL_SIZE", checkInt},
+	{"WRITE_TIMEOUT", checkDuration},
+}
+
+// validateConfig returns an error if PORT is set but invalid, instead of silently falling back to the default.
+// In strict mode, this applies to all environment variables in typedEnv.
+func validateConfig(strict bool) error {
+	for _, e := range typedEnv {
+		if !strict && e.name != "PORT" {
+			continue
+		}
+		v, ok := os.LookupEnv(e.name)
+		if !ok {
+			continue
+		}
+		if err := e.check(v); err != nil {
+			return fmt.Errorf("invalid value %q for %v: %w", v, e.name, err)
+		}
+	}
+	return nil
+}
+
+func checkBool(v string) error {
+	_, err := strconv.ParseBool(v)
+	return err
+}
+
+func checkDuration(v string) error {
+	_, err := time.ParseDuration(v)
+	return err
+}
+
+func checkFloat(v string) error {
+	_, err := strconv.ParseFloat(v, 64)
+	return err
+}
+
+func checkInt(v string) error {
+	_, err := strconv.Atoi(v)
+	return err
+}
+
+func checkIntMap(v string) error {
+	for _, e := range strings.Split(v, ",") {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		_, value, ok := strings.Cut(e, "=")
+		if !ok {
+			return fmt.Errorf("expected KEY=N, got %q", e)
+		}
+		if err := checkInt(strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fallbackReason is why a variable has its default value, or fallbackNone if it's set and valid.
+type fallbackReason string
+
+const (
+	fallbackNone    fallbackReason = ""
+	fallbackUnset   fallbackReason = "unset"
+	fallbackInvalid fallbackReason = "invalid"
+)
+
+// lookup the variable name and parse it, returning defaultV and the reason if it's unset or doesn't parse.
+func lookup[T any](name string, defaultV T, parse func(v string) (T, error)) (T, fallbackReason) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return defaultV, fallbackUnset
+	}
+	parsed, err := parse(v)
+	if err != nil {
+		return defaultV, fallbackInvalid
+	}
+	return parsed, fallbackNone
+}
+
+func lookupString(name, defaultV string) (string, fallbackReason) {
+	return lookup(name, defaultV, func(v string) (string, error) { return v, nil })
+}
+
+func lookupInt(name string, defaultV int) (int, fallbackReason) {
+	return lookup(name, defaultV, strconv.Atoi)
+}
+
+func lookupFloat(name string, defaultV float64) (float64, fallbackReason) {
+	return lookup(name, defaultV, func(v string) (float64, error) { return strconv.ParseFloat(v, 64) })
+}
+
+func lookupDuration(name string, defaultV time.Duration) (time.Duration, fallbackReason) {
+	return lookup(name, defaultV, time.ParseDuration)
+}
+
+func lookupBool(name string, defaultV bool) (bool, fallbackReason) {
+	return lookup(name, defaultV, strconv.ParseBool)
+}
+
+func getStringOrDefault(name, defaultV string) string {
+	v, _ := lookupString(name, defaultV)
+	return v
+}
+
+func getIntOrDefault(name string, defaultV int) int {
+	v, _ := lookupInt(name, defaultV)
+	return v
+}
+
+func getFloatOrDefault(name string, defaultV float64) float64 {
+	v, _ := lookupFloat(name, defaultV)
+	return v
+}
+
+func getDurationOrDefault(name string, defaultV time.Duration) time.Duration {
+	v, _ := lookupDuration(name, defaultV)
+	return v
+}
+
+func getBoolOrDefault(name string, defaultV bool) bool {
+	v, _ := lookupBool(name, defaultV)
+	return v
+}
+
+// configFallbacks returns the names of the variables in typedEnv that fall back to their defaults, by reason.
+func configFallbacks() map[fallbackReason][]string {
+	fallbacks := map[fallbackReason][]string{}
+	for _, e := range typedEnv {
+		_, reason := lookup(e.name, struct{}{}, func(v string) (struct{}, error) { return struct{}{}, e.check(v) })
+		if reason != fallbackNone {
+			fallbacks[reason] = append(fallbacks[reason], e.name)
+		}
+	}
+	return fallbacks
+}
+
+// getListOrDefault splits a comma-separated variable into its trimmed, non-empty elements.
+func getListOrDefault(name string, defaultV []string) []string {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return defaultV
+	}
+	var list []string
+	for _, e := range strings.Split(v, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// getIntMapOrDefault parses the variable name as comma-separated KEY=N pairs, like /reports/{id}=2,/exports=1.
+// Pairs that don't parse are skipped.
+func getIntMapOrDefault(name string, defaultV map[string]int) map[string]int {
+	if _, ok := os.LookupEnv(name); !ok {
+		return defaultV
+	}
+	m := map[string]int{}
+	for _, e := range getListOrDefault(name, nil) {
+		key, value, ok := strings.Cut(e, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil {
+			continue
+		}
+		m[strings.TrimSpace(key)] = n
+	}
+	return m
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		strict  bool
+		invalid bool
+	}{
+		{name: "accepts unset variables"},
+		{name: "accepts a valid port", env: map[string]string{"PORT": "8081"}},
+		{name: "rejects an invalid port", env: map[string]string{"PORT": "eighty"}, invalid: true},
+		{name: "rejects an invalid port in strict mode", env: map[string]string{"PORT": "eighty"}, strict: true, invalid: true},
+		{name: "ignores other invalid values when not strict", env: map[string]string{"READ_TIMEOUT": "soon"}},
+		{name: "rejects other invalid values in strict mode", env: map[string]string{"READ_TIMEOUT": "soon"}, strict: true, invalid: true},
+		{name: "accepts valid values in strict mode", env: map[string]string{"PORT": "8081", "READ_TIMEOUT": "1s", "LOG_REQUESTS": "false"}, strict: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+			err := validateConfig(test.strict)
+			if test.invalid && err == nil {
+				t.Fatal("expected an error")
+			}
+			if !test.invalid && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestLookupInt(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		set      bool
+		expected int
+		reason   fallbackReason
+	}{
+		{name: "uses valid values", value: "42", set: true, expected: 42, reason: fallbackNone},
+		{name: "falls back when unset", expected: 7, reason: fallbackUnset},
+		{name: "falls back when invalid", value: "many", set: true, expected: 7, reason: fallbackInvalid},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.set {
+				t.Setenv("TEST_INT", test.value)
+			}
+			v, reason := lookupInt("TEST_INT", 7)
+			if v != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, v)
+			}
+			if reason != test.reason {
+				t.Fatalf("expected reason %q, got %q", test.reason, reason)
+			}
+		})
+	}
+}
+
+func TestLookupString(t *testing.T) {
+	t.Run("uses set values, even if empty", func(t *testing.T) {
+		t.Setenv("TEST_STRING", "")
+		if v, reason := lookupString("TEST_STRING", "default"); v != "" || reason != fallbackNone {
+			t.Fatalf("expected the empty value without a reason, got %q and %q", v, reason)
+		}
+	})
+
+	t.Run("falls back when unset", func(t *testing.T) {
+		if v, reason := lookupString("TEST_STRING", "default"); v != "default" || reason != fallbackUnset {
+			t.Fatalf("expected the default because it's unset, got %q and %q", v, reason)
+		}
+	})
+}
+
+func TestConfigFallbacks(t *testing.T) {
+	t.Run("reports unset and invalid variables", func(t *testing.T) {
+		t.Setenv("PORT", "8081")
+		t.Setenv("READ_TIMEOUT", "soon")
+
+		fallbacks := configFallbacks()
+		if !slices.Equal(fallbacks[fallbackInvalid], []string{"READ_TIMEOUT"}) {
+			t.Fatalf("expected READ_TIMEOUT to be invalid, got %v", fallbacks[fallbackInvalid])
+		}
+		if !slices.Contains(fallbacks[fallbackUnset], "WRITE_TIMEOUT") {
+			t.Fatalf("expected WRITE_TIMEOUT to be unset, got %v", fallbacks[fallbackUnset])
+		}
+		if slices.Contains(fallbacks[fallbackUnset], "PORT") || slices.Contains(fallbacks[fallbackInvalid], "PORT") {
+			t.Fatal("expected PORT not to fall back")
+		}
+	})
+}
+
+func TestGetDurationOrDefault(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		set      bool
+		expected time.Duration
+	}{
+		{name: "uses a valid duration", value: "3s", set: true, expected: 3 * time.Second},
+		{name: "uses a compound duration", value: "1m30s", set: true, expected: 90 * time.Second},
+		{name: "falls back when unset", expected: time.Minute},
+		{name: "falls back when empty", value: "", set: true, expected: time.Minute},
+		{name: "falls back when malformed", value: "soon", set: true, expected: time.Minute},
+		{name: "falls back when missing a unit", value: "10", set: true, expected: time.Minute},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.set {
+				t.Setenv("TEST_DURATION", test.value)
+			}
+			if v := getDurationOrDefault("TEST_DURATION", time.Minute); v != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, v)
+			}
+		})
+	}
+}
+
+func TestGetBoolOrDefault(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		set      bool
+		expected bool
+	}{
+		{name: "uses true", value: "true", set: true, expected: true},
+		{name: "uses false", value: "false", set: true, expected: false},
+		{name: "falls back when unset", expected: true},
+		{name: "falls back when malformed", value: "yes please", set: true, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.set {
+				t.Setenv("TEST_BOOL", test.value)
+			}
+			if v := getBoolOrDefault("TEST_BOOL", true); v != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, v)
+			}
+		})
+	}
+}
+
+func TestGetListOrDefault(t *testing.T) {
+	t.Run("splits on commas and trims elements", func(t *testing.T) {
+		t.Setenv("TEST_LIST", " a, b,,c ")
+		if v := getListOrDefault("TEST_LIST", nil); !slices.Equal(v, []string{"a", "b", "c"}) {
+			t.Fatalf("expected [a b c], got %v", v)
+		}
+	})
+
+	t.Run("falls back when unset", func(t *testing.T) {
+		if v := getListOrDefault("TEST_LIST", []string{"x"}); !slices.Equal(v, []string{"x"}) {
+			t.Fatalf("expected [x], got %v", v)
+		}
+	})
+}
+
+func TestGetIntMapOrDefault(t *testing.T) {
+	t.Run("parses comma-separated pairs, skipping invalid ones", func(t *testing.T) {
+		t.Setenv("TEST_INT_MAP", " /reports/{id}=2, /exports = 1,/broken,/nan=x ")
+		v := getIntMapOrDefault("TEST_INT_MAP", nil)
+		if !maps.Equal(v, map[string]int{"/reports/{id}": 2, "/exports": 1}) {
+			t.Fatalf("expected /reports/{id}=2 and /exports=1, got %v", v)
+		}
+	})
+
+	t.Run("falls back when unset", func(t *testing.T) {
+		if v := getIntMapOrDefault("TEST_INT_MAP", map[string]int{"/x": 1}); !maps.Equal(v, map[string]int{"/x": 1}) {
+			t.Fatalf("expected /x=1, got %v", v)
+		}
+	})
+}
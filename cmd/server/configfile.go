@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// mapSettings are the settings that take comma-separated KEY=VALUE pairs, which can be maps in config files.
+var mapSettings = map[string]bool{
+	"ROUTE_CONCURRENCY_LIMITS": true,
+}
+
+// loadConfigFile sets environment variables from the settings in the JSON or YAML file at path,
+// unless they're already set in the process environment, so the usual defaults apply to the ones left out.
+// Settings are named like the environment variables, in any case, like port or SHUTDOWN_TIMEOUT.
+// Values can be strings, numbers, booleans, or lists, which are joined with commas.
+// Settings in mapSettings can also be maps, which are joined like KEY=VALUE,KEY=VALUE.
+// The format is picked by the extension, .json, .yaml, or .yml. An empty path loads nothing.
+func loadConfigFile(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var settings map[string]any
+	switch ext := filepath.Ext(path); ext {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&settings)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &settings)
+	default:
+		return fmt.Errorf("unknown config file extension %q, expected .json, .yaml, or .yml", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing %v: %w", path, err)
+	}
+
+	for key, value := range settings {
+		v, err := configValue(value, mapSettings[strings.ToUpper(key)])
+		if err != nil {
+			return fmt.Errorf("invalid value for %v in %v: %w", key, path, err)
+		}
+
+		key = strings.ToUpper(key)
+		if _, set := os.LookupEnv(key); set {
+			continue
+		}
+		if err := os.Setenv(key, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// configValue formats a value from a config file like the environment variable it sets.
+// Maps are only allowed if allowMap is set, so nested settings like server: {port: 8081} don't silently become pairs.
+func configValue(value any, allowMap bool) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case []any:
+		list := make([]string, 0, len(value))
+		for _, e := range value {
+			v, err := configValue(e, false)
+			if err != nil {
+				return "", err
+			}
+			list = append(list, v)
+		}
+		return strings.Join(list, ","), nil
+	case map[string]any:
+		if !allowMap {
+			return "", fmt.Errorf("nested settings are not supported")
+		}
+		pairs := make([]string, 0, len(value))
+		for _, k := range slices.Sorted(maps.Keys(value)) {
+			v, err := configValue(value[k], false)
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, k+"="+v)
+		}
+		return strings.Join(pairs, ","), nil
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Run("uses settings from a JSON or YAML file", func(t *testing.T) {
+		for name, content := range map[string]string{
+			"config.json": `{"port": 8081, "trusted_proxies": ["10.0.0.1", "10.0.0.2"], "METRICS_ENABLED": true,
+				"route_concurrency_limits": {"/reports/{id}": 2, "/exports": 1}}`,
+			"config.yaml": "port: 8081\ntrusted_proxies:\n  - 10.0.0.1\n  - 10.0.0.2\nMETRICS_ENABLED: true\n" +
+				"route_concurrency_limits:\n  /reports/{id}: 2\n  /exports: 1\n",
+		} {
+			path := writeConfigFile(t, name, content)
+			unsetEnv(t, "PORT", "TRUSTED_PROXIES", "METRICS_ENABLED", "ROUTE_CONCURRENCY_LIMITS")
+
+			if err := loadConfigFile(path); err != nil {
+				t.Fatal(err)
+			}
+			if port := getIntOrDefault("PORT", 8080); port != 8081 {
+				t.Fatalf("expected port 8081 from %v, got %v", name, port)
+			}
+			if proxies := getStringOrDefault("TRUSTED_PROXIES", ""); proxies != "10.0.0.1,10.0.0.2" {
+				t.Fatalf("expected proxies from %v, got %v", name, proxies)
+			}
+			if !getBoolOrDefault("METRICS_ENABLED", false) {
+				t.Fatalf("expected metrics enabled from %v", name)
+			}
+			if limits := getStringOrDefault("ROUTE_CONCURRENCY_LIMITS", ""); limits != "/exports=1,/reports/{id}=2" {
+				t.Fatalf("expected route concurrency limits from %v, got %v", name, limits)
+			}
+		}
+	})
+
+	t.Run("lets the process environment win", func(t *testing.T) {
+		path := writeConfigFile(t, "config.json", `{"port": 8081}`)
+		t.Setenv("PORT", "9090")
+
+		if err := loadConfigFile(path); err != nil {
+			t.Fatal(err)
+		}
+		if port := getIntOrDefault("PORT", 8080); port != 9090 {
+			t.Fatalf("expected 9090, got %v", port)
+		}
+	})
+
+	t.Run("falls back to defaults for missing keys", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yml", "port: 8081\n")
+		unsetEnv(t, "PORT", "SHUTDOWN_TIMEOUT")
+
+		if err := loadConfigFile(path); err != nil {
+			t.Fatal(err)
+		}
+		if timeout := getDurationOrDefault("SHUTDOWN_TIMEOUT", 15*time.Second); timeout != 15*time.Second {
+			t.Fatalf("expected the default of 15s, got %v", timeout)
+		}
+	})
+
+	t.Run("loads nothing without a path", func(t *testing.T) {
+		if err := loadConfigFile(""); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("errors on missing, unknown, and malformed files", func(t *testing.T) {
+		for _, path := range []string{
+			filepath.Join(t.TempDir(), "config.json"),
+			writeConfigFile(t, "config.toml", "port = 8081\n"),
+			writeConfigFile(t, "config.json", `{"port": 8081`),
+			writeConfigFile(t, "config.yaml", "server:\n  port: 8081\n"),
+			writeConfigFile(t, "config.yaml", "route_concurrency_limits:\n  /reports:\n    limit: 2\n"),
+		} {
+			if err := loadConfigFile(path); err == nil {
+				t.Fatalf("expected an error for %v", path)
+			}
+		}
+	})
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
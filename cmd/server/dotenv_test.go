@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadDotEnv(t *testing.T) {
+	t.Run("sets variables from the file, skipping comments and empty lines", func(t *testing.T) {
+		path := writeDotEnv(t, "# The port\nTEST_DOTENV_PORT=8081\n\n  # Indented comment\nTEST_DOTENV_HOST = \"example.com\"\nTEST_DOTENV_EMPTY=\n")
+		unsetEnv(t, "TEST_DOTENV_PORT", "TEST_DOTENV_HOST", "TEST_DOTENV_EMPTY")
+
+		if err := loadDotEnv(path); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]string{"TEST_DOTENV_PORT": "8081", "TEST_DOTENV_HOST": "example.com", "TEST_DOTENV_EMPTY": ""}
+		for k, v := range expected {
+			if actual, ok := os.LookupEnv(k); !ok || actual != v {
+				t.Fatalf("expected %v to be %q, got %q", k, v, actual)
+			}
+		}
+	})
+
+	t.Run("lets the process environment win", func(t *testing.T) {
+		path := writeDotEnv(t, "TEST_DOTENV_PORT=8081\n")
+		t.Setenv("TEST_DOTENV_PORT", "9090")
+
+		if err := loadDotEnv(path); err != nil {
+			t.Fatal(err)
+		}
+		if v := os.Getenv("TEST_DOTENV_PORT"); v != "9090" {
+			t.Fatalf("expected 9090, got %v", v)
+		}
+	})
+
+	t.Run("ignores a missing file", func(t *testing.T) {
+		if err := loadDotEnv(filepath.Join(t.TempDir(), ".env")); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("errors on malformed lines", func(t *testing.T) {
+		path := writeDotEnv(t, "NOT A VARIABLE\n")
+		if err := loadDotEnv(path); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func writeDotEnv(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// unsetEnv for the duration of the test, restoring the previous values afterwards.
+func unsetEnv(t *testing.T, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		// Setenv registers the cleanup that restores the original value.
+		t.Setenv(name, "")
+		if err := os.Unsetenv(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
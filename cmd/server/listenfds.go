@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first file descriptor passed with systemd-style socket activation.
+const listenFDsStart = 3
+
+// inheritedListener returns the listener passed by the parent process with systemd-style socket activation,
+// or nil if there is none. A listener is passed if LISTEN_PID is the pid of this process and LISTEN_FDS is at least 1.
+// Only the first passed file descriptor is used. The variables are unset, so child processes don't inherit them.
+func inheritedListener() (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) || getIntOrDefault("LISTEN_FDS", 0) < 1 {
+		return nil, nil
+	}
+
+	for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		_ = os.Unsetenv(name)
+	}
+	return listenerFromFD(listenFDsStart)
+}
+
+// listenerFromFD creates a listener for the socket with the file descriptor fd, which is closed afterward.
+func listenerFromFD(fd uintptr) (net.Listener, error) {
+	f := os.NewFile(fd, "listener")
+	defer func() {
+		_ = f.Close()
+	}()
+
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("error inheriting listener from file descriptor %v: %w", fd, err)
+	}
+	return l, nil
+}
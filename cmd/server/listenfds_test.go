@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"canvas/server"
+)
+
+func TestListenerFromFD(t *testing.T) {
+	t.Run("serves on the inherited listener", func(t *testing.T) {
+		parent, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := parent.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The new listener shares the socket, so the parent can close its own.
+		_ = parent.Close()
+
+		l, err := listenerFromFD(dupFD(t, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := server.NewWithListener(server.Options{}, l)
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		if err := s.WaitReady(context.Background()); err != nil {
+			t.Fatal(err, <-errs)
+		}
+		defer func() {
+			if err := s.Stop(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+
+		res, err := http.Get("http://" + l.Addr().String() + "/healthz/live")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.StatusCode)
+		}
+	})
+
+	t.Run("errors on file descriptors that are not sockets", func(t *testing.T) {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := listenerFromFD(dupFD(t, f)); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestInheritedListener(t *testing.T) {
+	t.Run("returns nil without passed file descriptors", func(t *testing.T) {
+		l, err := inheritedListener()
+		if err != nil || l != nil {
+			t.Fatalf("expected no listener and no error, got %v, %v", l, err)
+		}
+	})
+
+	t.Run("returns nil for file descriptors passed to another process", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+		t.Setenv("LISTEN_FDS", "1")
+
+		l, err := inheritedListener()
+		if err != nil || l != nil {
+			t.Fatalf("expected no listener and no error, got %v, %v", l, err)
+		}
+	})
+}
+
+// dupFD of f, which is closed afterward, like a file descriptor passed from a parent process that the test doesn't own.
+// This keeps the finalizer of f from closing a file descriptor that listenerFromFD already closed and that's been reused.
+func dupFD(t *testing.T, f *os.File) uintptr {
+	t.Helper()
+
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+	return uintptr(fd)
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// reopenableScheme is the zap sink scheme for log files that can be reopened after rotation.
+const reopenableScheme = "reopenable"
+
+// logFiles are the log files opened by loggers from createLogger. It's a variable so tests can use their own.
+var logFiles = newReopenableFiles()
+
+func init() {
+	if err := zap.RegisterSink(reopenableScheme, func(u *url.URL) (zap.Sink, error) {
+		return logFiles.open(u)
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// reopenableOutputs returns outputs with file paths turned into URLs for reopenable log files owned by owner,
+// leaving stdout, stderr, and other URLs as they are.
+func reopenableOutputs(outputs []string, owner string) ([]string, error) {
+	var result []string
+	for _, o := range outputs {
+		if o == "stdout" || o == "stderr" || strings.Contains(o, "://") {
+			result = append(result, o)
+			continue
+		}
+		path, err := filepath.Abs(o)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving log output %v: %w", o, err)
+		}
+		u := url.URL{Scheme: reopenableScheme, Path: path, RawQuery: url.Values{"owner": {owner}}.Encode()}
+		result = append(result, u.String())
+	}
+	return result, nil
+}
+
+// reopenableFiles are open log files by path, and the sinks using them by the logger owning them.
+// Loggers for the same path share the file, which is closed once the last of their sinks is closed.
+type reopenableFiles struct {
+	files     map[string]*reopenableFile
+	lock      sync.Mutex
+	nextOwner int
+	owners    map[string][]*reopenableSink
+}
+
+func newReopenableFiles() *reopenableFiles {
+	return &reopenableFiles{files: map[string]*reopenableFile{}, owners: map[string][]*reopenableSink{}}
+}
+
+// newOwner returns a name for a logger to own the sinks it opens, for reopenableOutputs and release.
+func (r *reopenableFiles) newOwner() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.nextOwner++
+	return strconv.Itoa(r.nextOwner)
+}
+
+// open is a zap sink factory for URLs with the reopenableScheme.
+func (r *reopenableFiles) open(u *url.URL) (zap.Sink, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	f, ok := r.files[u.Path]
+	if !ok {
+		file, err := openLogFile(u.Path)
+		if err != nil {
+			return nil, err
+		}
+		f = &reopenableFile{file: file, path: u.Path}
+		r.files[u.Path] = f
+	}
+	f.refs++
+
+	owner := u.Query().Get("owner")
+	sink := &reopenableSink{reopenableFile: f, files: r}
+	r.owners[owner] = append(r.owners[owner], sink)
+	return sink, nil
+}
+
+// release the sinks owned by owner, like when its logger is replaced. Files no other logger uses are closed.
+func (r *reopenableFiles) release(owner string) error {
+	r.lock.Lock()
+	sinks := r.owners[owner]
+	delete(r.owners, owner)
+	r.lock.Unlock()
+
+	var errs []error
+	for _, sink := range sinks {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// closeSink drops a reference to f, closing it and forgetting its path once there are none left.
+func (r *reopenableFiles) closeSink(f *reopenableFile) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if f.refs--; f.refs > 0 {
+		return nil
+	}
+	delete(r.files, f.path)
+	return f.close()
+}
+
+// reopen all files, so logs go to new files at the same paths after the old ones were moved away.
+// It returns the errors for the paths that couldn't be reopened, which keep their current files.
+func (r *reopenableFiles) reopen() (reopened int, errs map[string]error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	errs = map[string]error{}
+	for path, f := range r.files {
+		if err := f.reopen(); err != nil {
+			errs[path] = err
+			continue
+		}
+		reopened++
+	}
+	return reopened, errs
+}
+
+// reopenableSink is a zap sink writing to a shared reopenableFile.
+type reopenableSink struct {
+	*reopenableFile
+	closeOnce sync.Once
+	files     *reopenableFiles
+}
+
+// Close the sink, and the file if no other sink uses it. Closing again does nothing.
+func (s *reopenableSink) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		err = s.files.closeSink(s.reopenableFile)
+	})
+	return err
+}
+
+// reopenableFile is a log file that can be closed and opened again at the same path.
+// Writes wait for a reopen to finish, so none are lost or go to a closed file.
+// refs are the sinks using it, protected by the lock of the reopenableFiles it belongs to.
+type reopenableFile struct {
+	file *os.File
+	lock sync.Mutex
+	path string
+	refs int
+}
+
+func (f *reopenableFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.file.Write(p)
+}
+
+func (f *reopenableFile) Sync() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.file.Sync()
+}
+
+// close the file, syncing it first.
+func (f *reopenableFile) close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	// Syncing errors are ignored like on reopening, since there's nothing to be done about them.
+	_ = f.file.Sync()
+	return f.file.Close()
+}
+
+// reopen opens the path again before closing the current file. If opening fails, the current file is kept.
+func (f *reopenableFile) reopen() error {
+	file, err := openLogFile(f.path)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	old := f.file
+	f.file = file
+	// Syncing errors are ignored like on closing, since there's nothing to be done about them.
+	_ = old.Sync()
+	return old.Close()
+}
+
+// openLogFile for appending, creating it if needed, like zap does for file outputs.
+func openLogFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o666)
+}
+
+// reopenLogFilesOnSignal reopens all log files in files each time a signal arrives on signals, until ctx is done.
+// It's for tools like logrotate that move log files away and then signal the process to write to new ones.
+// Files that can't be reopened are logged, and keep being written to where they were.
+func reopenLogFilesOnSignal(ctx context.Context, signals <-chan os.Signal, files *reopenableFiles, log *zap.Logger) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			reopened, errs := files.reopen()
+			for _, path := range slices.Sorted(maps.Keys(errs)) {
+				log.Warn("Error reopening log file", zap.String("path", path), zap.Error(errs[path]))
+			}
+			log.Info("Reopened log files", zap.Int("files", reopened), zap.Int("failed", len(errs)))
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestReopenLogFilesOnSignal(t *testing.T) {
+	t.Run("writes to a new file after rotation on SIGUSR2", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "server.log")
+		rotated := filepath.Join(dir, "server.log.1")
+
+		files := useLogFiles(t)
+		log := createFileLogger(t, file)
+		log.Info("Before rotation")
+
+		if err := os.Rename(file, rotated); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGUSR2)
+		defer signal.Stop(signals)
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		done := make(chan struct{})
+		go func() {
+			reopenLogFilesOnSignal(ctx, signals, files, zap.New(core))
+			close(done)
+		}()
+
+		if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; logs.Len() == 0 && i < 100; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		<-done
+
+		if logs.FilterMessage("Reopened log files").Len() != 1 {
+			t.Fatalf("expected log files to be reopened, got logs %v", logs.All())
+		}
+
+		log.Info("After rotation")
+		if err := log.Sync(); err != nil {
+			t.Fatal(err)
+		}
+
+		old, err := os.ReadFile(rotated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(old), "Before rotation") || strings.Contains(string(old), "After rotation") {
+			t.Fatalf("expected only the log line from before rotation in the rotated file, got %q", old)
+		}
+		current, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(current), "After rotation") || strings.Contains(string(current), "Before rotation") {
+			t.Fatalf("expected only the log line from after rotation in the new file, got %q", current)
+		}
+	})
+	t.Run("reopens the other files if one can't be reopened", func(t *testing.T) {
+		files := useLogFiles(t)
+		goneDir := t.TempDir()
+		gone := filepath.Join(goneDir, "gone.log")
+		file := filepath.Join(t.TempDir(), "server.log")
+		rotated := file + ".1"
+		createFileLogger(t, gone)
+		log := createFileLogger(t, file)
+
+		if err := os.RemoveAll(goneDir); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(file, rotated); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		signals := make(chan os.Signal, 1)
+		core, logs := observer.New(zapcore.InfoLevel)
+		done := make(chan struct{})
+		go func() {
+			reopenLogFilesOnSignal(ctx, signals, files, zap.New(core))
+			close(done)
+		}()
+
+		signals <- syscall.SIGUSR2
+		for i := 0; logs.FilterMessage("Reopened log files").Len() == 0 && i < 100; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		<-done
+
+		warnings := logs.FilterMessage("Error reopening log file").All()
+		if len(warnings) != 1 || warnings[0].ContextMap()["path"] != gone {
+			t.Fatalf("expected a warning for %v, got logs %v", gone, logs.All())
+		}
+		reopened := logs.FilterMessage("Reopened log files").All()
+		if len(reopened) != 1 || reopened[0].ContextMap()["files"] != int64(1) || reopened[0].ContextMap()["failed"] != int64(1) {
+			t.Fatalf("expected one file to be reopened and one to fail, got logs %v", logs.All())
+		}
+
+		log.Info("After rotation")
+		if err := log.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		current, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(current), "After rotation") {
+			t.Fatalf("expected the log line from after rotation in the new file, got %q", current)
+		}
+	})
+}
+
+func TestReopenableFiles(t *testing.T) {
+	t.Run("closes a file once the last logger writing to it is closed", func(t *testing.T) {
+		files := useLogFiles(t)
+		file := filepath.Join(t.TempDir(), "server.log")
+
+		_, close1, err := createLogger("production", zap.NewAtomicLevel(), loggerOptions{Outputs: []string{file}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		log2, close2, err := createLogger("production", zap.NewAtomicLevel(), loggerOptions{Outputs: []string{file}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files.files) != 1 {
+			t.Fatalf("expected 1 shared file, got %v", len(files.files))
+		}
+		f := files.files[file]
+
+		if err := close1(); err != nil {
+			t.Fatal(err)
+		}
+		if len(files.files) != 1 {
+			t.Fatalf("expected the file to stay open for the other logger, got %v files", len(files.files))
+		}
+		log2.Info("Still logging")
+		if err := log2.Sync(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := close2(); err != nil {
+			t.Fatal(err)
+		}
+		if len(files.files) != 0 || len(files.owners) != 0 {
+			t.Fatalf("expected no files or owners left, got %v and %v", files.files, files.owners)
+		}
+		if _, err := f.file.Write([]byte("closed")); err == nil {
+			t.Fatal("expected an error writing to the closed file")
+		}
+		// Closing again does nothing.
+		if err := close1(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// useLogFiles replaces the log files registry with a new one for the duration of the test.
+func useLogFiles(t *testing.T) *reopenableFiles {
+	t.Helper()
+	previous := logFiles
+	logFiles = newReopenableFiles()
+	t.Cleanup(func() {
+		logFiles = previous
+	})
+	return logFiles
+}
+
+// createFileLogger writing to file, which is closed at the end of the test.
+func createFileLogger(t *testing.T, file string) *zap.Logger {
+	t.Helper()
+	log, closeLog, err := createLogger("production", zap.NewAtomicLevel(), loggerOptions{Outputs: []string{file}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = closeLog()
+	})
+	return log
+}
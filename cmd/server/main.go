@@ -3,9 +3,623 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/sync/errgroup"
+
+	"canvas/server"
 )
 
+// release is set through the linker at build time, generally from a git sha.
+// Used for logging and error reporting.
+var release string
+
 func main() {
-	fmt.Println("🤓")
+	os.Exit(start())
+}
+
+func start() (code int) {
+	if err := loadDotEnv(getStringOrDefault("ENV_FILE", ".env")); err != nil {
+		fmt.Println("Error loading environment file:", err)
+		return 2
+	}
+	// Settings in the config file come after the environment, and before the defaults.
+	if err := loadConfigFile(getStringOrDefault("CONFIG_FILE", "")); err != nil {
+		fmt.Println("Error loading config file:", err)
+		return 2
+	}
+
+	logEnv := getStringOrDefault("LOG_ENV", "development")
+	// The level is shared by all loggers built here, including after reloading, and can be changed at runtime.
+	level := zap.NewAtomicLevel()
+	baseLog, closeLog, err := createLogger(logEnv, level, loggerOptionsFromEnv())
+	if err != nil {
+		fmt.Println("Error setting up the logger:", err)
+		return 1
+	}
+	// Closing runs after the deferred sync below, and its errors are ignored for the same reason.
+	defer func() {
+		_ = closeLog()
+	}()
+
+	// The server tags its own logs with the release, so it gets the logger without it.
+	log := baseLog.With(zap.String("release", release))
+
+	defer func() {
+		// If we cannot sync, there's probably something wrong with outputting logs,
+		// so we probably cannot write using fmt.Println either. So just ignore the error.
+		_ = log.Sync()
+	}()
+
+	// Recover panics from here on so they get logged, and then flushed by the deferred sync above,
+	// instead of losing the buffered logs along with the crash context.
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("Panic while running", zap.Any("panic", r), zap.Stack("stack"))
+			code = 2
+		}
+	}()
+
+	// Checking the configuration is strict, so it catches everything that would fall back to a default.
+	check := getBoolOrDefault("CONFIG_CHECK", false)
+	if err := validateConfig(check || getBoolOrDefault("CONFIG_STRICT", false)); err != nil {
+		log.Error("Invalid configuration", zap.Error(err))
+		return 2
+	}
+	logConfigFallbacks(log, configFallbacks())
+
+	host := getStringOrDefault("HOST", "localhost")
+	port := getIntOrDefault("PORT", 8080)
+
+	opts := server.Options{
+		AccessLogFields:         getListOrDefault("ACCESS_LOG_FIELDS", nil),
+		AdminPassword:           getStringOrDefault("ADMIN_PASSWORD", ""),
+		AdminPort:               getIntOrDefault("ADMIN_PORT", 0),
+		AdminShutdownFirst:      getBoolOrDefault("ADMIN_SHUTDOWN_FIRST", false),
+		AdminUser:               getStringOrDefault("ADMIN_USER", ""),
+		AllowedCIDRs:            getListOrDefault("ALLOWED_CIDRS", nil),
+		BasePath:                getStringOrDefault("BASE_PATH", ""),
+		BindRetries:             getIntOrDefault("BIND_RETRY", 0),
+		BindRetryDelay:          getDurationOrDefault("BIND_RETRY_DELAY", 0),
+		CompressionEnabled:      getBoolOrDefault("COMPRESSION_ENABLED", true),
+		CORSAllowedOrigins:      getListOrDefault("CORS_ALLOWED_ORIGINS", nil),
+		DebugBodies:             getBoolOrDefault("DEBUG_BODIES", false),
+		DebugBodiesMaxBytes:     getIntOrDefault("DEBUG_BODIES_MAX_BYTES", 0),
+		DebugBodiesRedact:       getListOrDefault("DEBUG_BODIES_REDACT", nil),
+		DebugGC:                 getBoolOrDefault("DEBUG_GC", false),
+		DeniedCIDRs:             getListOrDefault("DENIED_CIDRS", nil),
+		DisableDefaultRoutes:    getBoolOrDefault("DISABLE_DEFAULT_ROUTES", false),
+		DisableKeepAlives:       getBoolOrDefault("DISABLE_KEEP_ALIVES", false),
+		H2CEnabled:              getBoolOrDefault("H2C_ENABLED", false),
+		HealthCheckTimeout:      getDurationOrDefault("HEALTH_CHECK_TIMEOUT", 0),
+		Host:                    host,
+		IdleTimeout:             getDurationOrDefault("IDLE_TIMEOUT", 0),
+		Log:                     baseLog,
+		LogBufferSize:           getIntOrDefault("LOG_BUFFER_SIZE", 1000),
+		LogExcludePaths:         getListOrDefault("LOG_EXCLUDE_PATHS", []string{"/healthz", "/metrics"}),
+		LogLevel:                level,
+		LogSampleRate:           getIntOrDefault("LOG_SAMPLE_RATE", 0),
+		LogRequests:             getBoolOrDefault("LOG_REQUESTS", true),
+		MaintenanceMode:         getBoolOrDefault("MAINTENANCE_MODE", false),
+		MaxConcurrentRequests:   getIntOrDefault("MAX_CONCURRENT_REQUESTS", 0),
+		MaxConnections:          getIntOrDefault("MAX_CONNECTIONS", 0),
+		MaxHeaderBytes:          getIntOrDefault("MAX_HEADER_BYTES", 0),
+		MaxRequestBodyBytes:     int64(getIntOrDefault("MAX_REQUEST_BODY_BYTES", 0)),
+		MemoryShutdownThreshold: int64(getIntOrDefault("MEMORY_SHUTDOWN_THRESHOLD", 0)),
+		MetricsEnabled:          getBoolOrDefault("METRICS_ENABLED", true),
+		PanicMode:               getStringOrDefault("PANIC_MODE", "recover"),
+		Port:                    port,
+		PprofEnabled:            getBoolOrDefault("PPROF_ENABLED", false),
+		PreStopDelay:            getDurationOrDefault("PRESTOP_DELAY", 0),
+		ProxyProtocol:           getBoolOrDefault("PROXY_PROTOCOL", false),
+		RateBurst:               getIntOrDefault("RATE_BURST", 0),
+		RateLimit:               getFloatOrDefault("RATE_LIMIT", 0),
+		RedirectHTTPToHTTPS:     getBoolOrDefault("REDIRECT_HTTP_TO_HTTPS", false),
+		Release:                 release,
+		SecurityHeaders: server.SecurityHeaders{
+			ContentSecurityPolicy: getStringOrDefault("CONTENT_SECURITY_POLICY", ""),
+			ReferrerPolicy:        getStringOrDefault("REFERRER_POLICY", ""),
+		},
+		SecurityHeadersEnabled: getBoolOrDefault("SECURITY_HEADERS", true),
+		ShowErrorDetails:       logEnv == "development",
+		ShutdownTimeout:        getDurationOrDefault("SHUTDOWN_TIMEOUT", 0),
+		SlowRequestThreshold:   getDurationOrDefault("SLOW_REQUEST_THRESHOLD", 0),
+		StreamDrainTimeout:     getDurationOrDefault("STREAM_DRAIN_TIMEOUT", 0),
+		ReadTimeout:            getDurationOrDefault("READ_TIMEOUT", 0),
+		RequestTimeout:         getDurationOrDefault("REQUEST_TIMEOUT", 0),
+		RouteConcurrencyLimits: getIntMapOrDefault("ROUTE_CONCURRENCY_LIMITS", nil),
+		TCPKeepAlive:           getDurationOrDefault("TCP_KEEP_ALIVE", 0),
+		TLSCertFile:            getStringOrDefault("TLS_CERT_FILE", ""),
+		TLSKeyFile:             getStringOrDefault("TLS_KEY_FILE", ""),
+		TrustedProxies:         getListOrDefault("TRUSTED_PROXIES", nil),
+		TrustedProxyHops:       getIntOrDefault("TRUSTED_PROXY_HOPS", 0),
+		UnixSocket:             getStringOrDefault("UNIX_SOCKET", ""),
+		WarmupTimeout:          getDurationOrDefault("WARMUP_TIMEOUT", 0),
+		WorkerPoolSize:         getIntOrDefault("WORKER_POOL_SIZE", 0),
+		WriteTimeout:           getDurationOrDefault("WRITE_TIMEOUT", 0),
+	}
+
+	if getBoolOrDefault("OTEL_ENABLED", false) {
+		tp, err := newTracerProvider(context.Background(), getStringOrDefault("OTEL_EXPORTER_OTLP_ENDPOINT", ""))
+		if err != nil {
+			log.Error("Error setting up tracing", zap.Error(err))
+			return 1
+		}
+		defer func() {
+			// Export the spans still buffered, but don't hold up exiting for long if the collector is unreachable.
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tp.Shutdown(ctx); err != nil {
+				log.Info("Error shutting down tracing", zap.Error(err))
+			}
+		}()
+		opts.TracerProvider = tp
+	}
+
+	if check {
+		return exitCode(checkConfig(context.Background(), opts, log))
+	}
+
+	ctx := context.Background()
+	shutdowns := make(chan os.Signal, 1)
+	signal.Notify(shutdowns, parseSignals(getListOrDefault("SHUTDOWN_SIGNALS", defaultShutdownSignals), log)...)
+	defer signal.Stop(shutdowns)
+
+	// With a maximum lifetime, running out of it shuts down the same way as a signal.
+	if lifetime := getDurationOrDefault("MAX_LIFETIME", 0); lifetime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, lifetime)
+		defer cancel()
+	}
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	certHangups := make(chan os.Signal, 1)
+	signal.Notify(certHangups, syscall.SIGHUP)
+	defer signal.Stop(certHangups)
+
+	dumps := make(chan os.Signal, 1)
+	signal.Notify(dumps, syscall.SIGUSR1)
+	defer signal.Stop(dumps)
+
+	rotations := make(chan os.Signal, 1)
+	signal.Notify(rotations, syscall.SIGUSR2)
+	defer signal.Stop(rotations)
+
+	quits := make(chan os.Signal, 1)
+	signal.Notify(quits, syscall.SIGQUIT)
+	defer signal.Stop(quits)
+
+	return exitCode(run(ctx, opts, log, runSignals{
+		certHangups: certHangups,
+		dumps:       dumps,
+		hangups:     hangups,
+		quits:       quits,
+		rotations:   rotations,
+		shutdowns:   shutdowns,
+	}))
+}
+
+// runSignals are the signals run reacts to while the server is running, besides the ones cancelling its context.
+// Nil channels never receive, so tests can leave out the ones they don't need.
+type runSignals struct {
+	// certHangups reload the TLS certificate.
+	certHangups <-chan os.Signal
+	// dumps log the stacks of all goroutines.
+	dumps <-chan os.Signal
+	// hangups reload the logger.
+	hangups <-chan os.Signal
+	// quits close the server immediately.
+	quits <-chan os.Signal
+	// rotations reopen the log files.
+	rotations <-chan os.Signal
+	// shutdowns stop the server gracefully the first time, and close it immediately after that.
+	shutdowns <-chan os.Signal
+}
+
+// run a server with opts until ctx is done or a shutdown signal arrives, which shuts it down gracefully,
+// or until it fails. It returns nil after a clean shutdown. Unlike start, it doesn't read the environment or subscribe to OS signals,
+// so it can be used from tests with a context they cancel.
+func run(ctx context.Context, opts server.Options, log *zap.Logger, signals runSignals) error {
+	// With socket activation, like from systemd or a previous process handing over, serve on the inherited socket.
+	l, err := inheritedListener()
+	if err != nil {
+		log.Error("Error inheriting listener", zap.Error(err))
+		return fmt.Errorf("error inheriting listener: %w", err)
+	}
+
+	var s *server.Server
+	if l != nil {
+		log.Info("Using inherited listener", zap.Stringer("address", l.Addr()))
+		s = server.NewWithListener(opts, l)
+	} else {
+		s = newServer(opts)
+	}
+
+	// Shutdown signals are handled outside of the errgroup, because a repeated one needs handling during the shutdown.
+	ctx, shutDown := context.WithCancel(ctx)
+	defer shutDown()
+	done := make(chan struct{})
+	forced := make(chan error, 1)
+	go func() {
+		forced <- shutdownOnSignals(done, signals.shutdowns, shutDown, s, log)
+	}()
+
+	eg, ctx := errgroup.WithContext(ctx)
+
+	eg.Go(func() error {
+		reloadLoggerOnHangup(ctx, signals.hangups, s, opts.LogLevel, log)
+		return nil
+	})
+
+	eg.Go(func() error {
+		reloadCertificateOnHangup(ctx, signals.certHangups, s, log)
+		return nil
+	})
+
+	eg.Go(func() error {
+		dumpStacksOnSignal(ctx, signals.dumps, log)
+		return nil
+	})
+
+	eg.Go(func() error {
+		reopenLogFilesOnSignal(ctx, signals.rotations, logFiles, log)
+		return nil
+	})
+
+	eg.Go(func() error {
+		return closeOnQuit(ctx, signals.quits, s, log)
+	})
+
+	eg.Go(func() error {
+		select {
+		case <-s.Ready():
+			log.Info("Server ready")
+		case <-ctx.Done():
+		}
+		return nil
+	})
+
+	// If Start fails, even before binding, the error cancels ctx, so the signal handlers above return as well.
+	eg.Go(func() error {
+		if err := s.Start(ctx); err != nil {
+			log.Info("Error running server", zap.Error(err))
+			return err
+		}
+		return nil
+	})
+
+	err = eg.Wait()
+	close(done)
+	if forcedErr := <-forced; forcedErr != nil {
+		return forcedErr
+	}
+	return err
+}
+
+// checkConfig validates opts and binds the ports briefly like starting a server would, but doesn't serve,
+// so a configuration can be checked before rolling it out. It logs whether the configuration is valid.
+func checkConfig(ctx context.Context, opts server.Options, log *zap.Logger) error {
+	if err := newServer(opts).Check(ctx); err != nil {
+		log.Error("Invalid configuration", zap.Error(err))
+		return err
+	}
+	log.Info("Configuration is valid", zap.String("host", opts.Host), zap.Int("port", opts.Port),
+		zap.Int("admin_port", opts.AdminPort), zap.Bool("tls", opts.TLSCertFile != ""))
+	return nil
+}
+
+// logConfigFallbacks summarizes the variables that fall back to their defaults, which would otherwise go unnoticed.
+// Invalid values are likely typos, so they're logged as warnings.
+func logConfigFallbacks(log *zap.Logger, fallbacks map[fallbackReason][]string) {
+	if invalid := fallbacks[fallbackInvalid]; len(invalid) > 0 {
+		log.Warn("Invalid environment variables, using defaults", zap.Strings("names", invalid))
+	}
+	if unset := fallbacks[fallbackUnset]; len(unset) > 0 {
+		log.Info("Unset environment variables, using defaults", zap.Strings("names", unset))
+	}
+}
+
+// defaultShutdownSignals are what orchestrators and Ctrl-C send.
+var defaultShutdownSignals = []string{"SIGTERM", "SIGINT"}
+
+// signalsByName are the signals that can be used for shutting down.
+var signalsByName = map[string]os.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+// parseSignals from names like SIGTERM, case-insensitive and with an optional SIG prefix.
+// Unknown names are logged as a warning and skipped. If no name is known, the defaultShutdownSignals are used,
+// so the process can always be shut down gracefully.
+func parseSignals(names []string, log *zap.Logger) []os.Signal {
+	var signals []os.Signal
+	for _, name := range names {
+		normalized := strings.ToUpper(strings.TrimSpace(name))
+		if !strings.HasPrefix(normalized, "SIG") {
+			normalized = "SIG" + normalized
+		}
+		sig, ok := signalsByName[normalized]
+		if !ok {
+			log.Warn("Unknown shutdown signal, skipping", zap.String("name", name))
+			continue
+		}
+		signals = append(signals, sig)
+	}
+	if len(signals) == 0 {
+		log.Warn("No known shutdown signals, using defaults", zap.Strings("names", defaultShutdownSignals))
+		return parseSignals(defaultShutdownSignals, log)
+	}
+	return signals
+}
+
+// newServer from options. It's a variable so tests can simulate failures during setup.
+var newServer = server.New
+
+// exitCode for the error the server stopped with:
+// 0 for a clean shutdown, 2 for invalid configuration, 3 for a forced shutdown, and 1 for anything else.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, server.ErrInvalidOptions):
+		return 2
+	case errors.Is(err, server.ErrForcedShutdown):
+		return 3
+	default:
+		return 1
+	}
+}
+
+// loggerSetter is something that can have its logger replaced, like a *server.Server.
+type loggerSetter interface {
+	SetLogger(log *zap.Logger)
+}
+
+// reloadLoggerOnHangup re-reads the logging configuration and gives s a freshly built logger each time a signal arrives on hangups,
+// until ctx is done. If building the new logger fails, s keeps its current one.
+// The log files of a logger it replaced are closed, unless the new one writes to them too.
+// The last one is left open when ctx is done, since s may still be logging while it stops.
+// The shared level is only reset if LOG_ENV or LOG_LEVEL changed, so a level set at runtime survives reloads otherwise.
+func reloadLoggerOnHangup(ctx context.Context, hangups <-chan os.Signal, s loggerSetter, level zap.AtomicLevel, log *zap.Logger) {
+	closePrevious := func() error { return nil }
+	configured := [2]string{getStringOrDefault("LOG_ENV", "development"), getStringOrDefault("LOG_LEVEL", "")}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			logEnv := getStringOrDefault("LOG_ENV", "development")
+			opts := loggerOptionsFromEnv()
+			reloaded := [2]string{logEnv, opts.Level}
+			opts.KeepLevel = reloaded == configured
+			newLog, closeLog, err := createLogger(logEnv, level, opts)
+			if err != nil {
+				log.Info("Error reloading logger", zap.Error(err))
+				continue
+			}
+			configured = reloaded
+			s.SetLogger(newLog)
+			if err := closePrevious(); err != nil {
+				log.Info("Error closing log files of the previous logger", zap.Error(err))
+			}
+			closePrevious = closeLog
+			log.Info("Reloaded logger", zap.String("env", logEnv))
+		}
+	}
+}
+
+// certificateReloader is something that can reload its TLS certificate, like a *server.Server.
+type certificateReloader interface {
+	ReloadCertificate() error
+}
+
+// reloadCertificateOnHangup reloads the TLS certificate of s each time a signal arrives on hangups, until ctx is done,
+// so rotated certificates are picked up without dropping connections. If reloading fails, s keeps its current certificate.
+func reloadCertificateOnHangup(ctx context.Context, hangups <-chan os.Signal, s certificateReloader, log *zap.Logger) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			if err := s.ReloadCertificate(); err != nil {
+				log.Warn("Error reloading TLS certificate", zap.Error(err))
+			}
+		}
+	}
+}
+
+// closer is something that can be closed immediately, like a *server.Server.
+type closer interface {
+	Close() error
+}
+
+// closeOnQuit closes s without a graceful shutdown when a signal arrives on quits, as a fast path for operators.
+// It then returns an error wrapping server.ErrForcedShutdown, which stops the rest of the process.
+// If ctx is done first, it returns nil. A graceful shutdown already in progress is cut short.
+func closeOnQuit(ctx context.Context, quits <-chan os.Signal, s closer, log *zap.Logger) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-quits:
+		log.Info("Closing immediately")
+		if err := s.Close(); err != nil {
+			log.Info("Error closing server", zap.Error(err))
+		}
+		return fmt.Errorf("closed on quit signal: %w", server.ErrForcedShutdown)
+	}
+}
+
+// shutdownOnSignals calls shutDown for a graceful shutdown when the first signal arrives on signals.
+// Operators who don't want to wait for it can signal again, which closes s immediately
+// and returns an error wrapping server.ErrForcedShutdown. Otherwise, it returns nil once done is closed.
+func shutdownOnSignals(done <-chan struct{}, signals <-chan os.Signal, shutDown context.CancelFunc, s closer, log *zap.Logger) error {
+	for received := 0; ; {
+		select {
+		case <-done:
+			return nil
+		case sig := <-signals:
+			received++
+			if received == 1 {
+				log.Info("Shutting down gracefully, signal again to close immediately", zap.Stringer("signal", sig))
+				shutDown()
+				continue
+			}
+
+			log.Info("Closing immediately", zap.Stringer("signal", sig))
+			if err := s.Close(); err != nil {
+				log.Info("Error closing server", zap.Error(err))
+			}
+			return fmt.Errorf("closed on repeated shutdown signal: %w", server.ErrForcedShutdown)
+		}
+	}
+}
+
+// buildLogger from a zap config. It's a variable so tests can simulate logger setup failures.
+var buildLogger = func(c zap.Config) (*zap.Logger, error) {
+	return c.Build()
+}
+
+// loggerOptions change the logger for an environment from its defaults.
+type loggerOptions struct {
+	// Format is "json" or "console". Defaults to JSON in production and console in development.
+	Format string
+	// KeepLevel leaves the shared level as it is, instead of setting it to the configured one.
+	KeepLevel bool
+	// Level is "debug", "info", "warn", or "error". Defaults to debug in development and info in production.
+	Level string
+	// Outputs are where logs are written, each "stdout", "stderr", or a file path.
+	// Defaults to stderr, or stdout when splitting streams. Files are reopened on SIGUSR2, for log rotation.
+	Outputs []string
+	// SplitStreams writes warnings and errors to stderr instead, and only the rest to Outputs.
+	SplitStreams bool
+}
+
+// loggerOptionsFromEnv reads LOG_FORMAT, LOG_LEVEL, the comma-separated LOG_OUTPUT, and LOG_SPLIT_STREAMS.
+func loggerOptionsFromEnv() loggerOptions {
+	return loggerOptions{
+		Format:       getStringOrDefault("LOG_FORMAT", ""),
+		Level:        getStringOrDefault("LOG_LEVEL", ""),
+		Outputs:      getListOrDefault("LOG_OUTPUT", nil),
+		SplitStreams: getBoolOrDefault("LOG_SPLIT_STREAMS", false),
+	}
+}
+
+// createLogger for the given environment. Only unknown environments get a no-op logger,
+// errors from building the development and production loggers are returned as-is.
+// The logger uses level, which is set to the configured level, so it can be changed later.
+// The returned function closes the log files the logger writes to, for when it's no longer used.
+func createLogger(env string, level zap.AtomicLevel, opts loggerOptions) (*zap.Logger, func() error, error) {
+	var c zap.Config
+	switch env {
+	case "production":
+		c = zap.NewProductionConfig()
+	case "development":
+		c = zap.NewDevelopmentConfig()
+	default:
+		return zap.NewNop(), func() error { return nil }, nil
+	}
+
+	switch opts.Format {
+	case "":
+	case "json", "console":
+		c.Encoding = opts.Format
+	default:
+		return nil, nil, fmt.Errorf("unknown log format %q, expected json or console", opts.Format)
+	}
+
+	files := logFiles
+	owner := files.newOwner()
+	closeFiles := func() error { return files.release(owner) }
+
+	if len(opts.Outputs) > 0 {
+		outputs, err := reopenableOutputs(opts.Outputs, owner)
+		if err != nil {
+			return nil, nil, err
+		}
+		c.OutputPaths = outputs
+	} else if opts.SplitStreams {
+		c.OutputPaths = []string{"stdout"}
+	}
+
+	configured := c.Level.Level()
+	if opts.Level != "" {
+		l, err := zapcore.ParseLevel(opts.Level)
+		if err != nil {
+			return nil, nil, err
+		}
+		configured = l
+	}
+	if !opts.KeepLevel {
+		level.SetLevel(configured)
+	}
+	c.Level = level
+
+	build := buildLogger
+	if opts.SplitStreams {
+		build = buildSplitLogger
+	}
+	log, err := build(c)
+	if err != nil {
+		_ = closeFiles()
+		return nil, nil, err
+	}
+	return log, closeFiles, nil
+}
+
+// buildSplitLogger from a zap config, with warnings and errors going to stderr and everything else to the config's outputs.
+func buildSplitLogger(c zap.Config) (*zap.Logger, error) {
+	log, err := buildLogger(c)
+	if err != nil {
+		return nil, err
+	}
+
+	c.OutputPaths = []string{"stderr"}
+	errorLog, err := buildLogger(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return log.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(
+			&levelFilterCore{Core: core, enabled: func(l zapcore.Level) bool { return l < zapcore.WarnLevel }},
+			&levelFilterCore{Core: errorLog.Core(), enabled: func(l zapcore.Level) bool { return l >= zapcore.WarnLevel }},
+		)
+	})), nil
+}
+
+// levelFilterCore only passes on entries with levels that are enabled, on top of the levels the wrapped core enables.
+type levelFilterCore struct {
+	zapcore.Core
+	enabled zap.LevelEnablerFunc
+}
+
+func (c *levelFilterCore) Enabled(l zapcore.Level) bool {
+	return c.enabled(l) && c.Core.Enabled(l)
+}
+
+func (c *levelFilterCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelFilterCore{Core: c.Core.With(fields), enabled: c.enabled}
+}
+
+func (c *levelFilterCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.enabled(e.Level) {
+		return ce
+	}
+	return c.Core.Check(e, ce)
 }
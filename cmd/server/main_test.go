@@ -0,0 +1,803 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"canvas/server"
+)
+
+func TestStart(t *testing.T) {
+	t.Run("returns 1 without panicking if the logger cannot be created", func(t *testing.T) {
+		original := buildLogger
+		defer func() {
+			buildLogger = original
+		}()
+		buildLogger = func(zap.Config) (*zap.Logger, error) {
+			return nil, errors.New("no logger for you")
+		}
+		t.Setenv("LOG_ENV", "development")
+
+		if code := start(); code != 1 {
+			t.Fatalf("expected exit code 1, got %v", code)
+		}
+	})
+}
+
+func TestStart_panic(t *testing.T) {
+	t.Run("logs and flushes panics during setup and returns 2", func(t *testing.T) {
+		original := newServer
+		defer func() {
+			newServer = original
+		}()
+		newServer = func(...server.Option) *server.Server {
+			panic("no server for you")
+		}
+		file := filepath.Join(t.TempDir(), "server.log")
+		t.Setenv("LOG_ENV", "production")
+		t.Setenv("LOG_OUTPUT", file)
+
+		if code := start(); code != 2 {
+			t.Fatalf("expected exit code 2, got %v", code)
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{"Panic while running", "no server for you", `"stack"`} {
+			if !strings.Contains(string(content), expected) {
+				t.Fatalf("expected %q in logs, got %q", expected, content)
+			}
+		}
+	})
+}
+
+func TestStart_config(t *testing.T) {
+	t.Run("returns 2 if the port is set but invalid", func(t *testing.T) {
+		t.Setenv("LOG_ENV", "none")
+		t.Setenv("PORT", "eighty")
+
+		if code := start(); code != 2 {
+			t.Fatalf("expected exit code 2, got %v", code)
+		}
+	})
+
+	t.Run("returns 2 if the server options are invalid", func(t *testing.T) {
+		t.Setenv("LOG_ENV", "none")
+		t.Setenv("PORT", "0")
+		t.Setenv("TLS_CERT_FILE", "cert.pem")
+
+		if code := start(); code != 2 {
+			t.Fatalf("expected exit code 2, got %v", code)
+		}
+	})
+}
+
+func TestStart_configCheck(t *testing.T) {
+	t.Run("returns 0 for a valid configuration without serving", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "server.log")
+		t.Setenv("LOG_ENV", "production")
+		t.Setenv("LOG_OUTPUT", file)
+		t.Setenv("CONFIG_CHECK", "true")
+		t.Setenv("PORT", "0")
+
+		if code := start(); code != 0 {
+			t.Fatalf("expected exit code 0, got %v", code)
+		}
+		logs := readFile(t, file)
+		if !strings.Contains(logs, "Configuration is valid") {
+			t.Fatalf("expected the configuration to be reported valid, got %q", logs)
+		}
+		if strings.Contains(logs, "Server listening") {
+			t.Fatalf("expected the server not to start, got %q", logs)
+		}
+	})
+
+	t.Run("returns 2 with the reason for invalid server options", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "server.log")
+		t.Setenv("LOG_ENV", "production")
+		t.Setenv("LOG_OUTPUT", file)
+		t.Setenv("CONFIG_CHECK", "true")
+		t.Setenv("PORT", "0")
+		t.Setenv("TLS_CERT_FILE", "cert.pem")
+
+		if code := start(); code != 2 {
+			t.Fatalf("expected exit code 2, got %v", code)
+		}
+		logs := readFile(t, file)
+		if !strings.Contains(logs, "Invalid configuration") || !strings.Contains(logs, "TLSCertFile and TLSKeyFile must be set together") {
+			t.Fatalf("expected the reason in the logs, got %q", logs)
+		}
+	})
+
+	t.Run("checks all environment variables strictly", func(t *testing.T) {
+		t.Setenv("LOG_ENV", "none")
+		t.Setenv("CONFIG_CHECK", "true")
+		t.Setenv("PORT", "0")
+		t.Setenv("SHUTDOWN_TIMEOUT", "soon")
+
+		if code := start(); code != 2 {
+			t.Fatalf("expected exit code 2, got %v", code)
+		}
+	})
+
+	t.Run("returns 1 if the port is already in use", func(t *testing.T) {
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = l.Close()
+		}()
+
+		t.Setenv("LOG_ENV", "none")
+		t.Setenv("CONFIG_CHECK", "true")
+		t.Setenv("HOST", "localhost")
+		t.Setenv("PORT", strconv.Itoa(l.Addr().(*net.TCPAddr).Port))
+
+		if code := start(); code != 1 {
+			t.Fatalf("expected exit code 1, got %v", code)
+		}
+	})
+}
+
+func TestStart_invalidTLS(t *testing.T) {
+	t.Run("returns 1 promptly if the certificate cannot be loaded", func(t *testing.T) {
+		dir := t.TempDir()
+		cert, key := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		for _, name := range []string{cert, key} {
+			if err := os.WriteFile(name, []byte("not a pem file"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Setenv("LOG_ENV", "none")
+		t.Setenv("PORT", "0")
+		t.Setenv("TLS_CERT_FILE", cert)
+		t.Setenv("TLS_KEY_FILE", key)
+
+		codes := make(chan int, 1)
+		go func() {
+			codes <- start()
+		}()
+
+		select {
+		case code := <-codes:
+			if code != 1 {
+				t.Fatalf("expected exit code 1, got %v", code)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("start did not return after the server failed")
+		}
+	})
+}
+
+func TestStart_maxLifetime(t *testing.T) {
+	t.Run("returns 0 after shutting down at the end of the lifetime", func(t *testing.T) {
+		t.Setenv("LOG_ENV", "none")
+		t.Setenv("PORT", "0")
+		t.Setenv("MAX_LIFETIME", "100ms")
+
+		if code := start(); code != 0 {
+			t.Fatalf("expected exit code 0, got %v", code)
+		}
+	})
+}
+
+func TestStart_bindError(t *testing.T) {
+	t.Run("returns 1 if the port is already in use", func(t *testing.T) {
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = l.Close()
+		}()
+
+		t.Setenv("LOG_ENV", "none")
+		t.Setenv("HOST", "localhost")
+		t.Setenv("PORT", strconv.Itoa(l.Addr().(*net.TCPAddr).Port))
+
+		if code := start(); code != 1 {
+			t.Fatalf("expected exit code 1, got %v", code)
+		}
+	})
+}
+
+func TestRun(t *testing.T) {
+	t.Run("shuts down cleanly when the context is cancelled", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- run(ctx, server.Options{Host: "localhost", LogLevel: zap.NewAtomicLevel()}, zap.New(core), runSignals{})
+		}()
+
+		for i := 0; logs.FilterMessage("Server ready").Len() == 0 && i < 100; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if logs.FilterMessage("Server ready").Len() != 1 {
+			t.Fatal("expected the server to become ready")
+		}
+		cancel()
+
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Fatalf("expected a clean shutdown, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("run did not return after cancelling the context")
+		}
+	})
+
+	t.Run("drains on the first shutdown signal and closes immediately on the second", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		log := zap.New(core)
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		port := l.Addr().(*net.TCPAddr).Port
+		_ = l.Close()
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		opts := server.Options{
+			Host: "localhost",
+			Port: port,
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				<-release
+			}),
+			Log:             log,
+			LogLevel:        zap.NewAtomicLevel(),
+			ShutdownTimeout: time.Minute,
+		}
+		shutdowns := make(chan os.Signal, 1)
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- run(context.Background(), opts, log, runSignals{shutdowns: shutdowns})
+		}()
+		for i := 0; logs.FilterMessage("Server ready").Len() == 0 && i < 100; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		go func() {
+			res, err := http.Get("http://" + net.JoinHostPort("localhost", strconv.Itoa(port)) + "/slow")
+			if err == nil {
+				_ = res.Body.Close()
+			}
+		}()
+		<-started
+
+		shutdowns <- syscall.SIGINT
+		select {
+		case err := <-errs:
+			t.Fatalf("expected run to wait for the slow request, got %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if logs.FilterMessage("Stopping").Len() != 1 {
+			t.Fatal("expected a graceful shutdown after the first signal")
+		}
+
+		shutdowns <- syscall.SIGINT
+		select {
+		case err := <-errs:
+			if !errors.Is(err, server.ErrForcedShutdown) {
+				t.Fatalf("expected ErrForcedShutdown, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("run did not return right after the second signal")
+		}
+		if logs.FilterMessage("Closing immediately").Len() != 1 {
+			t.Fatal("expected the server to be closed after the second signal")
+		}
+	})
+
+	t.Run("returns the error if the server fails", func(t *testing.T) {
+		err := run(context.Background(), server.Options{TLSCertFile: "cert.pem"}, zap.NewNop(), runSignals{})
+		if !errors.Is(err, server.ErrInvalidOptions) {
+			t.Fatalf("expected ErrInvalidOptions, got %v", err)
+		}
+	})
+}
+
+func TestLogConfigFallbacks(t *testing.T) {
+	t.Run("warns about invalid variables and summarizes unset ones", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		logConfigFallbacks(zap.New(core), map[fallbackReason][]string{
+			fallbackInvalid: {"READ_TIMEOUT"},
+			fallbackUnset:   {"PORT", "WRITE_TIMEOUT"},
+		})
+
+		invalid := logs.FilterMessage("Invalid environment variables, using defaults").All()
+		if len(invalid) != 1 || invalid[0].Level != zap.WarnLevel {
+			t.Fatalf("expected one warning about invalid variables, got %v", invalid)
+		}
+		if names := invalid[0].ContextMap()["names"]; !slices.Equal(names.([]any), []any{"READ_TIMEOUT"}) {
+			t.Fatalf("expected READ_TIMEOUT, got %v", names)
+		}
+		if logs.FilterMessage("Unset environment variables, using defaults").Len() != 1 {
+			t.Fatal("expected a summary of unset variables")
+		}
+	})
+
+	t.Run("logs nothing without fallbacks", func(t *testing.T) {
+		core, logs := observer.New(zap.DebugLevel)
+		logConfigFallbacks(zap.New(core), map[fallbackReason][]string{})
+
+		if logs.Len() != 0 {
+			t.Fatalf("expected no logs, got %v", logs.All())
+		}
+	})
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{name: "is 0 for a clean shutdown", err: nil, expected: 0},
+		{name: "is 1 for runtime errors", err: errors.New("oh no"), expected: 1},
+		{name: "is 2 for invalid options", err: fmt.Errorf("error: %w", server.ErrInvalidOptions), expected: 2},
+		{name: "is 3 for a forced shutdown", err: fmt.Errorf("error: %w", server.ErrForcedShutdown), expected: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if code := exitCode(test.err); code != test.expected {
+				t.Fatalf("expected exit code %v, got %v", test.expected, code)
+			}
+		})
+	}
+}
+
+func TestCreateLogger(t *testing.T) {
+	t.Run("uses the given format", func(t *testing.T) {
+		original := buildLogger
+		defer func() {
+			buildLogger = original
+		}()
+		var encoding string
+		buildLogger = func(c zap.Config) (*zap.Logger, error) {
+			encoding = c.Encoding
+			return original(c)
+		}
+
+		tests := []struct {
+			env, format, expected string
+		}{
+			{"production", "", "json"},
+			{"development", "", "console"},
+			{"development", "json", "json"},
+			{"production", "console", "console"},
+		}
+		for _, test := range tests {
+			if _, _, err := createLogger(test.env, zap.NewAtomicLevel(), loggerOptions{Format: test.format}); err != nil {
+				t.Fatal(err)
+			}
+			if encoding != test.expected {
+				t.Fatalf("expected %v encoding for %v with format %q, got %v", test.expected, test.env, test.format, encoding)
+			}
+		}
+	})
+
+	t.Run("errors on unknown formats", func(t *testing.T) {
+		if _, _, err := createLogger("production", zap.NewAtomicLevel(), loggerOptions{Format: "xml"}); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("writes to the given outputs", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "server.log")
+
+		log, closeLog, err := createLogger("production", zap.NewAtomicLevel(), loggerOptions{Outputs: []string{file}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = closeLog()
+		}()
+		log.Info("Hello from the test")
+		if err := log.Sync(); err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), "Hello from the test") {
+			t.Fatalf("expected log line in file, got %q", content)
+		}
+	})
+
+	t.Run("splits warnings and errors from other logs", func(t *testing.T) {
+		stdout := replaceStdFile(t, &os.Stdout)
+		stderr := replaceStdFile(t, &os.Stderr)
+
+		log, _, err := createLogger("production", zap.NewAtomicLevel(), loggerOptions{SplitStreams: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.Info("Hello from the test")
+		log.Error("Oh no from the test")
+		_ = log.Sync()
+
+		info, errs := readFile(t, stdout), readFile(t, stderr)
+		if !strings.Contains(info, "Hello from the test") || strings.Contains(info, "Oh no from the test") {
+			t.Fatalf("expected only the info line on stdout, got %q", info)
+		}
+		if !strings.Contains(errs, "Oh no from the test") || strings.Contains(errs, "Hello from the test") {
+			t.Fatalf("expected only the error line on stderr, got %q", errs)
+		}
+	})
+
+	t.Run("does not split streams by default", func(t *testing.T) {
+		stderr := replaceStdFile(t, &os.Stderr)
+
+		log, _, err := createLogger("production", zap.NewAtomicLevel(), loggerOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.Info("Hello from the test")
+		log.Error("Oh no from the test")
+		_ = log.Sync()
+
+		if logs := readFile(t, stderr); !strings.Contains(logs, "Hello from the test") || !strings.Contains(logs, "Oh no from the test") {
+			t.Fatalf("expected both lines on stderr, got %q", logs)
+		}
+	})
+
+	t.Run("uses the given level, which can be changed later", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "server.log")
+		level := zap.NewAtomicLevel()
+
+		log, closeLog, err := createLogger("development", level, loggerOptions{Level: "error", Outputs: []string{file}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = closeLog()
+		}()
+		log.Info("Suppressed")
+		log.Error("Not suppressed")
+		level.SetLevel(zap.InfoLevel)
+		log.Info("Now visible")
+		_ = log.Sync()
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(content), "Suppressed") {
+			t.Fatal("info log written at error level")
+		}
+		if !strings.Contains(string(content), "Not suppressed") || !strings.Contains(string(content), "Now visible") {
+			t.Fatalf("expected log lines in file, got %q", content)
+		}
+	})
+
+	t.Run("errors on unknown levels", func(t *testing.T) {
+		if _, _, err := createLogger("production", zap.NewAtomicLevel(), loggerOptions{Level: "loud"}); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("returns a no-op logger for unknown environments", func(t *testing.T) {
+		log, _, err := createLogger("test", zap.NewAtomicLevel(), loggerOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if log == nil {
+			t.Fatal("logger is nil")
+		}
+	})
+
+	t.Run("returns build errors for known environments", func(t *testing.T) {
+		original := buildLogger
+		defer func() {
+			buildLogger = original
+		}()
+		buildLogger = func(zap.Config) (*zap.Logger, error) {
+			return nil, errors.New("no logger for you")
+		}
+
+		for _, env := range []string{"development", "production"} {
+			if _, _, err := createLogger(env, zap.NewAtomicLevel(), loggerOptions{}); err == nil {
+				t.Fatalf("expected an error for %v", env)
+			}
+		}
+	})
+}
+
+type fakeLoggerSetter struct {
+	logs chan *zap.Logger
+}
+
+func (f *fakeLoggerSetter) SetLogger(log *zap.Logger) {
+	f.logs <- log
+}
+
+func TestReloadLoggerOnHangup(t *testing.T) {
+	t.Run("rebuilds the logger from LOG_ENV on each hangup", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		hangups := make(chan os.Signal, 1)
+		s := &fakeLoggerSetter{logs: make(chan *zap.Logger, 1)}
+
+		done := make(chan struct{})
+		go func() {
+			reloadLoggerOnHangup(ctx, hangups, s, zap.NewAtomicLevel(), zap.NewNop())
+			close(done)
+		}()
+
+		t.Setenv("LOG_ENV", "production")
+		hangups <- syscall.SIGHUP
+		if log := <-s.logs; log.Core().Enabled(zap.DebugLevel) {
+			t.Fatal("production logger has debug level enabled")
+		}
+
+		t.Setenv("LOG_ENV", "development")
+		hangups <- syscall.SIGHUP
+		if log := <-s.logs; !log.Core().Enabled(zap.DebugLevel) {
+			t.Fatal("development logger does not have debug level enabled")
+		}
+
+		cancel()
+		<-done
+	})
+
+	t.Run("keeps a level set at runtime unless LOG_LEVEL changes", func(t *testing.T) {
+		t.Setenv("LOG_ENV", "production")
+		t.Setenv("LOG_LEVEL", "info")
+		ctx, cancel := context.WithCancel(context.Background())
+		hangups := make(chan os.Signal, 1)
+		s := &fakeLoggerSetter{logs: make(chan *zap.Logger, 1)}
+		level := zap.NewAtomicLevelAt(zap.InfoLevel)
+
+		done := make(chan struct{})
+		go func() {
+			reloadLoggerOnHangup(ctx, hangups, s, level, zap.NewNop())
+			close(done)
+		}()
+
+		// Like PUT /admin/loglevel.
+		level.SetLevel(zap.ErrorLevel)
+		hangups <- syscall.SIGHUP
+		if log := <-s.logs; log.Core().Enabled(zap.WarnLevel) || level.Level() != zap.ErrorLevel {
+			t.Fatalf("expected the runtime level error to survive the reload, got %v", level.Level())
+		}
+
+		t.Setenv("LOG_LEVEL", "warn")
+		hangups <- syscall.SIGHUP
+		if log := <-s.logs; !log.Core().Enabled(zap.WarnLevel) || level.Level() != zap.WarnLevel {
+			t.Fatalf("expected the changed LOG_LEVEL warn, got %v", level.Level())
+		}
+
+		cancel()
+		<-done
+	})
+
+	t.Run("closes the log files of the replaced logger", func(t *testing.T) {
+		files := useLogFiles(t)
+		dir := t.TempDir()
+		ctx, cancel := context.WithCancel(context.Background())
+		hangups := make(chan os.Signal, 1)
+		s := &fakeLoggerSetter{logs: make(chan *zap.Logger, 1)}
+
+		done := make(chan struct{})
+		go func() {
+			reloadLoggerOnHangup(ctx, hangups, s, zap.NewAtomicLevel(), zap.NewNop())
+			close(done)
+		}()
+
+		t.Setenv("LOG_ENV", "production")
+		t.Setenv("LOG_OUTPUT", filepath.Join(dir, "old.log"))
+		hangups <- syscall.SIGHUP
+		<-s.logs
+
+		t.Setenv("LOG_OUTPUT", filepath.Join(dir, "new.log"))
+		hangups <- syscall.SIGHUP
+		<-s.logs
+
+		cancel()
+		<-done
+		if _, ok := files.files[filepath.Join(dir, "old.log")]; ok || len(files.files) != 1 {
+			t.Fatalf("expected only the new log file to be open, got %v", files.files)
+		}
+		for _, f := range files.files {
+			_ = f.close()
+		}
+	})
+}
+
+type fakeCertificateReloader struct {
+	err     error
+	reloads chan struct{}
+}
+
+func (f *fakeCertificateReloader) ReloadCertificate() error {
+	f.reloads <- struct{}{}
+	return f.err
+}
+
+func TestReloadCertificateOnHangup(t *testing.T) {
+	t.Run("reloads the certificate on each hangup and warns on errors", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		hangups := make(chan os.Signal, 1)
+		s := &fakeCertificateReloader{err: errors.New("oh no"), reloads: make(chan struct{})}
+		core, logs := observer.New(zap.InfoLevel)
+
+		done := make(chan struct{})
+		go func() {
+			reloadCertificateOnHangup(ctx, hangups, s, zap.New(core))
+			close(done)
+		}()
+
+		hangups <- syscall.SIGHUP
+		<-s.reloads
+		hangups <- syscall.SIGHUP
+		<-s.reloads
+
+		cancel()
+		<-done
+		if n := logs.FilterMessage("Error reloading TLS certificate").Len(); n != 2 {
+			t.Fatalf("expected 2 warnings, got %v", n)
+		}
+	})
+}
+
+func TestParseSignals(t *testing.T) {
+	tests := []struct {
+		name     string
+		names    []string
+		expected []os.Signal
+		warnings int
+	}{
+		{name: "parses the defaults", names: defaultShutdownSignals, expected: []os.Signal{syscall.SIGTERM, syscall.SIGINT}},
+		{name: "parses only SIGTERM", names: []string{"SIGTERM"}, expected: []os.Signal{syscall.SIGTERM}},
+		{name: "accepts names without prefix in any case", names: []string{"term", "Usr2"},
+			expected: []os.Signal{syscall.SIGTERM, syscall.SIGUSR2}},
+		{name: "skips unknown names with a warning", names: []string{"SIGTERM", "SIGNOPE"},
+			expected: []os.Signal{syscall.SIGTERM}, warnings: 1},
+		{name: "falls back to the defaults without known names", names: []string{"nope"},
+			expected: []os.Signal{syscall.SIGTERM, syscall.SIGINT}, warnings: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+			signals := parseSignals(test.names, zap.New(core))
+			if !slices.Equal(signals, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, signals)
+			}
+			if logs.Len() != test.warnings {
+				t.Fatalf("expected %v warnings, got %v", test.warnings, logs.Len())
+			}
+		})
+	}
+}
+
+type fakeCloser struct {
+	closed bool
+}
+
+func (f *fakeCloser) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestCloseOnQuit(t *testing.T) {
+	t.Run("closes immediately on a quit signal", func(t *testing.T) {
+		quits := make(chan os.Signal, 1)
+		quits <- syscall.SIGQUIT
+		s := &fakeCloser{}
+
+		err := closeOnQuit(context.Background(), quits, s, zap.NewNop())
+		if !errors.Is(err, server.ErrForcedShutdown) {
+			t.Fatalf("expected ErrForcedShutdown, got %v", err)
+		}
+		if !s.closed {
+			t.Fatal("not closed")
+		}
+		if code := exitCode(err); code != 3 {
+			t.Fatalf("expected exit code 3, got %v", code)
+		}
+	})
+
+	t.Run("does nothing when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		s := &fakeCloser{}
+
+		if err := closeOnQuit(ctx, make(chan os.Signal), s, zap.NewNop()); err != nil {
+			t.Fatal(err)
+		}
+		if s.closed {
+			t.Fatal("closed without a quit signal")
+		}
+	})
+}
+
+func TestShutdownOnSignals(t *testing.T) {
+	t.Run("shuts down gracefully on the first signal", func(t *testing.T) {
+		signals := make(chan os.Signal, 1)
+		signals <- syscall.SIGTERM
+		done := make(chan struct{})
+		shutDown := make(chan struct{})
+		s := &fakeCloser{}
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- shutdownOnSignals(done, signals, func() { close(shutDown) }, s, zap.NewNop())
+		}()
+		<-shutDown
+		close(done)
+
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		if s.closed {
+			t.Fatal("closed on the first signal")
+		}
+	})
+
+	t.Run("closes immediately on the second signal", func(t *testing.T) {
+		signals := make(chan os.Signal, 2)
+		signals <- syscall.SIGINT
+		signals <- syscall.SIGINT
+		s := &fakeCloser{}
+
+		err := shutdownOnSignals(make(chan struct{}), signals, func() {}, s, zap.NewNop())
+		if !errors.Is(err, server.ErrForcedShutdown) {
+			t.Fatalf("expected ErrForcedShutdown, got %v", err)
+		}
+		if !s.closed {
+			t.Fatal("not closed")
+		}
+	})
+}
+
+// replaceStdFile like os.Stdout with a temporary file for the duration of the test, and returns the file's name.
+func replaceStdFile(t *testing.T, f **os.File) string {
+	t.Helper()
+
+	tmp, err := os.Create(filepath.Join(t.TempDir(), "std.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *f
+	*f = tmp
+	t.Cleanup(func() {
+		*f = original
+		_ = tmp.Close()
+	})
+	return tmp.Name()
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"os"
+	"runtime"
+
+	"go.uber.org/zap"
+)
+
+// dumpStacksOnSignal logs the stacks of all goroutines each time a signal arrives on signals, until ctx is done.
+// It's for finding out what a hanging process is doing, without stopping it.
+func dumpStacksOnSignal(ctx context.Context, signals <-chan os.Signal, log *zap.Logger) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			log.Info("Goroutine stacks", zap.ByteString("stacks", allStacks()))
+		}
+	}
+}
+
+// allStacks returns the formatted stacks of all goroutines, growing the buffer until they fit.
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
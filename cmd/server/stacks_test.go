@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDumpStacksOnSignal(t *testing.T) {
+	t.Run("logs all goroutine stacks on SIGUSR1", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGUSR1)
+		defer signal.Stop(signals)
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		done := make(chan struct{})
+		go func() {
+			dumpStacksOnSignal(ctx, signals, zap.New(core))
+			close(done)
+		}()
+
+		if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; logs.Len() == 0 && i < 100; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		<-done
+
+		entries := logs.FilterMessage("Goroutine stacks").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 log entry, got %v", len(entries))
+		}
+		stacks, _ := entries[0].ContextMap()["stacks"].(string)
+		if !strings.Contains(stacks, "TestDumpStacksOnSignal") {
+			t.Fatalf("expected the test goroutine in the stacks, got %v", stacks)
+		}
+	})
+}
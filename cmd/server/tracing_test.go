@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestNewTracerProvider(t *testing.T) {
+	t.Run("sets up a global tracer provider exporting to the endpoint", func(t *testing.T) {
+		original := otel.GetTracerProvider()
+		defer otel.SetTracerProvider(original)
+
+		tp, err := newTracerProvider(context.Background(), "http://localhost:4318")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = tp.Shutdown(context.Background())
+		}()
+
+		if otel.GetTracerProvider() != tp {
+			t.Fatal("expected the tracer provider to be set globally")
+		}
+	})
+
+	t.Run("errors on invalid endpoints", func(t *testing.T) {
+		if _, err := newTracerProvider(context.Background(), "://nonsense"); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
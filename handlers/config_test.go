@@ -0,0 +1,32 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"canvas/handlers"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("responds with the config as JSON", func(t *testing.T) {
+		mux := chi.NewMux()
+		handlers.Config(mux, map[string]any{"Host": "localhost", "Port": 8080})
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+		if contentType := res.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Fatalf("expected content type application/json, got %v", contentType)
+		}
+		if body := strings.TrimSpace(res.Body.String()); body != `{"Host":"localhost","Port":8080}` {
+			t.Fatalf("expected body %v, got %v", `{"Host":"localhost","Port":8080}`, body)
+		}
+	})
+}
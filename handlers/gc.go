@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type heapStats struct {
+	HeapAlloc uint64 `json:"heap_alloc"`
+	NumGC     uint32 `json:"num_gc"`
+}
+
+type gcBody struct {
+	Before heapStats `json:"before"`
+	After  heapStats `json:"after"`
+}
+
+// GC runs a garbage collection on POST, and responds with the heap stats from before and after it as JSON,
+// like {"before":{"heap_alloc":123,"num_gc":4},"after":{"heap_alloc":45,"num_gc":5}}.
+// It blocks for the whole collection, so it's only for debugging memory use.
+func GC(mux chi.Router) {
+	mux.Post("/admin/gc", func(w http.ResponseWriter, r *http.Request) {
+		var body gcBody
+		body.Before = readHeapStats()
+		runtime.GC()
+		body.After = readHeapStats()
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	})
+}
+
+func readHeapStats() heapStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return heapStats{HeapAlloc: m.HeapAlloc, NumGC: m.NumGC}
+}
@@ -0,0 +1,61 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"canvas/handlers"
+)
+
+func TestGC(t *testing.T) {
+	t.Run("runs a garbage collection and responds with heap stats from before and after", func(t *testing.T) {
+		mux := chi.NewMux()
+		handlers.GC(mux)
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/admin/gc", nil))
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+		if contentType := res.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Fatalf("expected content type application/json, got %v", contentType)
+		}
+
+		var body struct {
+			Before struct {
+				HeapAlloc *uint64 `json:"heap_alloc"`
+				NumGC     uint32  `json:"num_gc"`
+			} `json:"before"`
+			After struct {
+				HeapAlloc *uint64 `json:"heap_alloc"`
+				NumGC     uint32  `json:"num_gc"`
+			} `json:"after"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Before.HeapAlloc == nil || body.After.HeapAlloc == nil {
+			t.Fatalf("expected heap_alloc before and after, got %+v", body)
+		}
+		if body.After.NumGC <= body.Before.NumGC {
+			t.Fatalf("expected num_gc to increase from %v, got %v", body.Before.NumGC, body.After.NumGC)
+		}
+	})
+
+	t.Run("only runs on POST", func(t *testing.T) {
+		mux := chi.NewMux()
+		handlers.GC(mux)
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/admin/gc", nil))
+
+		if res.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("expected status %v, got %v", http.StatusMethodNotAllowed, res.Code)
+		}
+	})
+}
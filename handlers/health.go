@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// defaultHealthCheckTimeout is used for HealthChecks without a Timeout.
+const defaultHealthCheckTimeout = time.Second
+
+// HealthCheck of a dependency, like a database, which must pass for the readiness probe to succeed.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+	// Timeout for Check, after which it counts as failed. Defaults to 1 second.
+	Timeout time.Duration
+}
+
+type healthBody struct {
+	Checks map[string]healthCheckResult `json:"checks"`
+}
+
+type healthCheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Health registers liveness and readiness probes on the mux.
+// The liveness probe always succeeds if it's reachable at all. The readiness probe
+// returns 503 Service Unavailable whenever ready reports false.
+// Otherwise, it runs the checks returned by checks concurrently, if any, and responds with the result of each as JSON,
+// with 503 Service Unavailable if one of them failed. checks may be nil.
+func Health(mux chi.Router, ready func() bool, checks func() []HealthCheck) {
+	mux.Get("/healthz/live", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux.Get("/healthz/ready", func(w http.ResponseWriter, r *http.Request) {
+		if !ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		if checks == nil {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		cs := checks()
+		if len(cs) == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		body, ok := runHealthChecks(r.Context(), cs)
+		w.Header().Set("Content-Type", "application/json")
+		if ok {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(body)
+	})
+}
+
+// runHealthChecks concurrently, and return their results and whether all of them passed.
+func runHealthChecks(ctx context.Context, checks []HealthCheck) (healthBody, bool) {
+	body := healthBody{Checks: make(map[string]healthCheckResult, len(checks))}
+	ok := true
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := healthCheckResult{Status: "ok"}
+			if err := runHealthCheck(ctx, check); err != nil {
+				result = healthCheckResult{Status: "failed", Error: err.Error()}
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+			body.Checks[check.Name] = result
+			if result.Error != "" {
+				ok = false
+			}
+		}()
+	}
+	wg.Wait()
+
+	return body, ok
+}
+
+// runHealthCheck within its timeout. It returns when the timeout is up even if the check doesn't respect its context.
+func runHealthCheck(ctx context.Context, check HealthCheck) error {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- check.Check(ctx)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,146 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"canvas/handlers"
+)
+
+func TestHealth(t *testing.T) {
+	t.Run("liveness probe returns 200", func(t *testing.T) {
+		mux := chi.NewMux()
+		handlers.Health(mux, func() bool { return false }, nil)
+
+		if code := makeGetRequest(mux, "/healthz/live"); code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, code)
+		}
+	})
+
+	t.Run("readiness probe follows the ready state", func(t *testing.T) {
+		var ready atomic.Bool
+		mux := chi.NewMux()
+		handlers.Health(mux, ready.Load, nil)
+
+		tests := []struct {
+			name  string
+			ready bool
+			code  int
+		}{
+			{"not ready before start", false, http.StatusServiceUnavailable},
+			{"ready after start", true, http.StatusOK},
+			{"not ready while shutting down", false, http.StatusServiceUnavailable},
+		}
+		for _, test := range tests {
+			ready.Store(test.ready)
+			if code := makeGetRequest(mux, "/healthz/ready"); code != test.code {
+				t.Fatalf("%v: expected status %v, got %v", test.name, test.code, code)
+			}
+		}
+	})
+
+	t.Run("readiness probe responds with passing checks", func(t *testing.T) {
+		mux := chi.NewMux()
+		handlers.Health(mux, func() bool { return true }, func() []handlers.HealthCheck {
+			return []handlers.HealthCheck{
+				{Name: "database", Check: func(context.Context) error { return nil }},
+				{Name: "cache", Check: func(context.Context) error { return nil }},
+			}
+		})
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz/ready", nil))
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+		expected := `{"checks":{"cache":{"status":"ok"},"database":{"status":"ok"}}}`
+		if body := strings.TrimSpace(res.Body.String()); body != expected {
+			t.Fatalf("expected body %v, got %v", expected, body)
+		}
+	})
+
+	t.Run("readiness probe fails with a failing check", func(t *testing.T) {
+		mux := chi.NewMux()
+		handlers.Health(mux, func() bool { return true }, func() []handlers.HealthCheck {
+			return []handlers.HealthCheck{
+				{Name: "database", Check: func(context.Context) error { return errors.New("connection refused") }},
+				{Name: "cache", Check: func(context.Context) error { return nil }},
+			}
+		})
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz/ready", nil))
+
+		if res.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v, got %v", http.StatusServiceUnavailable, res.Code)
+		}
+		if contentType := res.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Fatalf("expected content type application/json, got %v", contentType)
+		}
+		expected := `{"checks":{"cache":{"status":"ok"},"database":{"status":"failed","error":"connection refused"}}}`
+		if body := strings.TrimSpace(res.Body.String()); body != expected {
+			t.Fatalf("expected body %v, got %v", expected, body)
+		}
+	})
+
+	t.Run("readiness probe fails checks that time out", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+
+		mux := chi.NewMux()
+		handlers.Health(mux, func() bool { return true }, func() []handlers.HealthCheck {
+			return []handlers.HealthCheck{
+				{Name: "slow", Timeout: 10 * time.Millisecond, Check: func(context.Context) error {
+					<-block
+					return nil
+				}},
+			}
+		})
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz/ready", nil))
+
+		if res.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v, got %v", http.StatusServiceUnavailable, res.Code)
+		}
+		expected := `{"checks":{"slow":{"status":"failed","error":"context deadline exceeded"}}}`
+		if body := strings.TrimSpace(res.Body.String()); body != expected {
+			t.Fatalf("expected body %v, got %v", expected, body)
+		}
+	})
+
+	t.Run("readiness probe does not run checks when not ready", func(t *testing.T) {
+		var ran atomic.Bool
+		mux := chi.NewMux()
+		handlers.Health(mux, func() bool { return false }, func() []handlers.HealthCheck {
+			return []handlers.HealthCheck{{Name: "database", Check: func(context.Context) error {
+				ran.Store(true)
+				return nil
+			}}}
+		})
+
+		if code := makeGetRequest(mux, "/healthz/ready"); code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v, got %v", http.StatusServiceUnavailable, code)
+		}
+		if ran.Load() {
+			t.Fatal("expected the check not to run")
+		}
+	})
+}
+
+// makeGetRequest and return the status code.
+func makeGetRequest(handler http.Handler, target string) int {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	return res.Result().StatusCode
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type inFlightBody struct {
+	InFlight int64 `json:"in_flight"`
+}
+
+// InFlight responds with the number of requests in flight returned by count as JSON, like {"in_flight":3}.
+func InFlight(mux chi.Router, count func() int64) {
+	mux.Get("/admin/inflight", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(inFlightBody{InFlight: count()})
+	})
+}
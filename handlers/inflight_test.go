@@ -0,0 +1,29 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"canvas/handlers"
+)
+
+func TestInFlight(t *testing.T) {
+	t.Run("responds with the count as JSON", func(t *testing.T) {
+		mux := chi.NewMux()
+		handlers.InFlight(mux, func() int64 { return 3 })
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/admin/inflight", nil))
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+		if body := strings.TrimSpace(res.Body.String()); body != `{"in_flight":3}` {
+			t.Fatalf("expected body %v, got %v", `{"in_flight":3}`, body)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+// LogLevel responds with the current level as JSON on GET, and sets it from a JSON body like {"level":"debug"} on PUT.
+// It responds with 400 Bad Request if the body isn't valid JSON or the level isn't known.
+func LogLevel(mux chi.Router, level zap.AtomicLevel) {
+	mux.Get("/admin/loglevel", func(w http.ResponseWriter, r *http.Request) {
+		writeLogLevel(w, level.Level())
+	})
+
+	mux.Put("/admin/loglevel", func(w http.ResponseWriter, r *http.Request) {
+		var body logLevelBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+
+		l, err := zapcore.ParseLevel(body.Level)
+		if err != nil {
+			http.Error(w, "Unknown level, expected debug, info, warn, or error", http.StatusBadRequest)
+			return
+		}
+
+		level.SetLevel(l)
+		writeLogLevel(w, l)
+	})
+}
+
+func writeLogLevel(w http.ResponseWriter, l zapcore.Level) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(logLevelBody{Level: l.String()})
+}
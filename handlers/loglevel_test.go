@@ -0,0 +1,67 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"canvas/handlers"
+)
+
+func TestLogLevel(t *testing.T) {
+	t.Run("responds with the current level", func(t *testing.T) {
+		mux := chi.NewMux()
+		handlers.LogLevel(mux, zap.NewAtomicLevelAt(zap.WarnLevel))
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/admin/loglevel", nil))
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+		if body := strings.TrimSpace(res.Body.String()); body != `{"level":"warn"}` {
+			t.Fatalf("expected body %v, got %v", `{"level":"warn"}`, body)
+		}
+	})
+
+	t.Run("sets the level", func(t *testing.T) {
+		level := zap.NewAtomicLevel()
+		mux := chi.NewMux()
+		handlers.LogLevel(mux, level)
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"level":"debug"}`)))
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+		if level.Level() != zap.DebugLevel {
+			t.Fatalf("expected level %v, got %v", zap.DebugLevel, level.Level())
+		}
+		if body := strings.TrimSpace(res.Body.String()); body != `{"level":"debug"}` {
+			t.Fatalf("expected body %v, got %v", `{"level":"debug"}`, body)
+		}
+	})
+
+	t.Run("rejects invalid bodies and levels", func(t *testing.T) {
+		for _, body := range []string{`{"level":"loud"}`, `{"level":`, ``} {
+			level := zap.NewAtomicLevel()
+			mux := chi.NewMux()
+			handlers.LogLevel(mux, level)
+
+			res := httptest.NewRecorder()
+			mux.ServeHTTP(res, httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(body)))
+
+			if res.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %v for body %q, got %v", http.StatusBadRequest, body, res.Code)
+			}
+			if level.Level() != zap.InfoLevel {
+				t.Fatalf("expected level to stay %v, got %v", zap.InfoLevel, level.Level())
+			}
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// LogEntry is a single log line as served by Logs.
+type LogEntry struct {
+	Time    time.Time      `json:"time"`
+	Level   string         `json:"level"`
+	Logger  string         `json:"logger,omitempty"`
+	Message string         `json:"message"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// Logs responds with the entries returned by entries as a JSON array, in the order they're returned.
+func Logs(mux chi.Router, entries func() []LogEntry) {
+	mux.Get("/admin/logs", func(w http.ResponseWriter, r *http.Request) {
+		es := entries()
+		if es == nil {
+			es = []LogEntry{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(es)
+	})
+}
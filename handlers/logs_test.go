@@ -0,0 +1,54 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"canvas/handlers"
+)
+
+func TestLogs(t *testing.T) {
+	t.Run("responds with the entries as JSON", func(t *testing.T) {
+		entries := []handlers.LogEntry{
+			{Time: time.Unix(1, 0).UTC(), Level: "info", Message: "First"},
+			{Time: time.Unix(2, 0).UTC(), Level: "error", Message: "Second", Fields: map[string]any{"error": "oh no"}},
+		}
+		mux := chi.NewMux()
+		handlers.Logs(mux, func() []handlers.LogEntry { return entries })
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/admin/logs", nil))
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+		if contentType := res.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Fatalf("expected content type application/json, got %v", contentType)
+		}
+		var body []handlers.LogEntry
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body) != 2 || body[0].Message != "First" || body[1].Message != "Second" || body[1].Fields["error"] != "oh no" {
+			t.Fatalf("expected entries %v, got %v", entries, body)
+		}
+	})
+
+	t.Run("responds with an empty array without entries", func(t *testing.T) {
+		mux := chi.NewMux()
+		handlers.Logs(mux, func() []handlers.LogEntry { return nil })
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/admin/logs", nil))
+
+		if body := strings.TrimSpace(res.Body.String()); body != "[]" {
+			t.Fatalf("expected body [], got %v", body)
+		}
+	})
+}
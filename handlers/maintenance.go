@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type maintenanceBody struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// Maintenance responds with whether maintenance mode is enabled as JSON on GET,
+// and sets it from a JSON body like {"enabled":true} on PUT.
+// It responds with 400 Bad Request if the body isn't valid JSON or enabled is missing.
+func Maintenance(mux chi.Router, enabled func() bool, set func(enabled bool)) {
+	mux.Get("/admin/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		writeMaintenance(w, enabled())
+	})
+
+	mux.Put("/admin/maintenance", func(w http.ResponseWriter, r *http.Request) {
+		var body maintenanceBody
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if body.Enabled == nil {
+			http.Error(w, "Missing enabled, expected true or false", http.StatusBadRequest)
+			return
+		}
+
+		set(*body.Enabled)
+		writeMaintenance(w, *body.Enabled)
+	})
+}
+
+func writeMaintenance(w http.ResponseWriter, enabled bool) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(maintenanceBody{Enabled: &enabled})
+}
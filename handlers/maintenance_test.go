@@ -0,0 +1,54 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"canvas/handlers"
+)
+
+func TestMaintenance(t *testing.T) {
+	t.Run("responds with and sets whether maintenance mode is enabled", func(t *testing.T) {
+		var enabled atomic.Bool
+		mux := chi.NewMux()
+		handlers.Maintenance(mux, enabled.Load, enabled.Store)
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/admin/maintenance", nil))
+		if body := strings.TrimSpace(res.Body.String()); body != `{"enabled":false}` {
+			t.Fatalf("expected body %v, got %v", `{"enabled":false}`, body)
+		}
+
+		res = httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPut, "/admin/maintenance", strings.NewReader(`{"enabled":true}`)))
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+		if !enabled.Load() {
+			t.Fatal("expected maintenance mode to be enabled")
+		}
+		if body := strings.TrimSpace(res.Body.String()); body != `{"enabled":true}` {
+			t.Fatalf("expected body %v, got %v", `{"enabled":true}`, body)
+		}
+	})
+
+	t.Run("rejects invalid bodies", func(t *testing.T) {
+		for _, body := range []string{`{"enabled":"yes"}`, `{}`, `{"enabled":`, ``} {
+			var enabled atomic.Bool
+			mux := chi.NewMux()
+			handlers.Maintenance(mux, enabled.Load, enabled.Store)
+
+			res := httptest.NewRecorder()
+			mux.ServeHTTP(res, httptest.NewRequest(http.MethodPut, "/admin/maintenance", strings.NewReader(body)))
+
+			if res.Code != http.StatusBadRequest {
+				t.Fatalf("expected status %v for body %q, got %v", http.StatusBadRequest, body, res.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"canvas/handlers"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("serves registered metrics", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "things_total", Help: "Things."})
+		registry.MustRegister(counter)
+		counter.Inc()
+
+		mux := chi.NewMux()
+		handlers.Metrics(mux, registry)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+		if !strings.Contains(res.Body.String(), "things_total 1") {
+			t.Fatalf("metric missing from body: %v", res.Body.String())
+		}
+	})
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http/pprof"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Pprof serves the runtime profiling data from net/http/pprof under /debug/pprof/.
+// CPU profiles and traces are cut off by the server's WriteTimeout, so request them for a shorter duration.
+func Pprof(mux chi.Router) {
+	mux.HandleFunc("/debug/pprof/*", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,23 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"canvas/handlers"
+)
+
+func TestPprof(t *testing.T) {
+	t.Run("serves the index and named profiles", func(t *testing.T) {
+		mux := chi.NewMux()
+		handlers.Pprof(mux)
+
+		for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline"} {
+			if code := makeGetRequest(mux, path); code != http.StatusOK {
+				t.Fatalf("expected status %v for %v, got %v", http.StatusOK, path, code)
+			}
+		}
+	})
+}
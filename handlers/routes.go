@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Route is a registered route pattern, with the methods it's registered for.
+type Route struct {
+	Pattern string   `json:"pattern"`
+	Methods []string `json:"methods"`
+}
+
+type routesBody struct {
+	Routes []Route `json:"routes"`
+}
+
+// Routes responds with the routes registered on routes as JSON, sorted by pattern,
+// like {"routes":[{"pattern":"/users/{id}","methods":["GET","PUT"]}]}.
+// The routes are walked on every request, so routes added later are listed too.
+func Routes(mux chi.Router, routes chi.Routes) {
+	mux.Get("/admin/routes", func(w http.ResponseWriter, r *http.Request) {
+		methods := map[string][]string{}
+		// Walking only fails if the callback does.
+		_ = chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			methods[route] = append(methods[route], method)
+			return nil
+		})
+
+		body := routesBody{Routes: []Route{}}
+		for pattern, ms := range methods {
+			slices.Sort(ms)
+			body.Routes = append(body.Routes, Route{Pattern: pattern, Methods: slices.Compact(ms)})
+		}
+		slices.SortFunc(body.Routes, func(a, b Route) int {
+			return strings.Compare(a.Pattern, b.Pattern)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	})
+}
@@ -0,0 +1,56 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"canvas/handlers"
+)
+
+func TestRoutes(t *testing.T) {
+	t.Run("lists the registered routes with their methods", func(t *testing.T) {
+		routes := chi.NewMux()
+		noop := func(w http.ResponseWriter, r *http.Request) {}
+		routes.Get("/things", noop)
+		routes.Post("/things", noop)
+		routes.Route("/things/{id}", func(r chi.Router) {
+			r.Get("/", noop)
+			r.Delete("/", noop)
+		})
+
+		mux := chi.NewMux()
+		handlers.Routes(mux, routes)
+		routes.Put("/later", noop)
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/admin/routes", nil))
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+		if contentType := res.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Fatalf("expected content type application/json, got %v", contentType)
+		}
+		expected := `{"routes":[{"pattern":"/later","methods":["PUT"]},{"pattern":"/things","methods":["GET","POST"]},` +
+			`{"pattern":"/things/{id}/","methods":["DELETE","GET"]}]}`
+		if body := strings.TrimSpace(res.Body.String()); body != expected {
+			t.Fatalf("expected body %v, got %v", expected, body)
+		}
+	})
+
+	t.Run("responds with an empty list without routes", func(t *testing.T) {
+		mux := chi.NewMux()
+		handlers.Routes(mux, chi.NewMux())
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/admin/routes", nil))
+
+		if body := strings.TrimSpace(res.Body.String()); body != `{"routes":[]}` {
+			t.Fatalf("expected body %v, got %v", `{"routes":[]}`, body)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Static serves the files in files under prefix, like /app, for a single-page app.
+// Content types are set from the file extensions. Paths under prefix that aren't files get index.html instead,
+// so the app can handle its own routes in the browser. An empty prefix serves the files at the root.
+func Static(mux chi.Router, prefix string, files fs.FS) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	fileServer := http.StripPrefix(prefix, http.FileServerFS(files))
+
+	if prefix != "" {
+		mux.Get(prefix, func(w http.ResponseWriter, r *http.Request) {
+			// The original request target keeps a base path that was stripped from r.URL before the mux, if any.
+			target, query, _ := strings.Cut(r.RequestURI, "?")
+			if !strings.HasPrefix(target, "/") {
+				target, query = r.URL.Path, r.URL.RawQuery
+			}
+			target += "/"
+			if query != "" {
+				target += "?" + query
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		})
+	}
+
+	mux.Get(prefix+"/*", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		if name == "" || isFile(files, name) {
+			fileServer.ServeHTTP(w, r)
+			return
+		}
+		http.ServeFileFS(w, r, files, "index.html")
+	})
+}
+
+// isFile reports whether name is a regular file in files.
+func isFile(files fs.FS, name string) bool {
+	info, err := fs.Stat(files, name)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,75 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/go-chi/chi/v5"
+
+	"canvas/handlers"
+)
+
+func TestStatic(t *testing.T) {
+	files := fstest.MapFS{
+		"index.html":     {Data: []byte("<html>app</html>")},
+		"assets/app.js":  {Data: []byte("console.log('app')")},
+		"assets/app.css": {Data: []byte("body {}")},
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{name: "serves files with their content type", path: "/app/assets/app.js", status: http.StatusOK,
+			contentType: "text/javascript; charset=utf-8", body: "console.log('app')"},
+		{name: "serves index.html at the prefix", path: "/app/", status: http.StatusOK,
+			contentType: "text/html; charset=utf-8", body: "<html>app</html>"},
+		{name: "falls back to index.html for unknown paths", path: "/app/users/1", status: http.StatusOK,
+			contentType: "text/html; charset=utf-8", body: "<html>app</html>"},
+		{name: "falls back to index.html for directories", path: "/app/assets/", status: http.StatusOK,
+			contentType: "text/html; charset=utf-8", body: "<html>app</html>"},
+		{name: "redirects the prefix without a trailing slash", path: "/app", status: http.StatusMovedPermanently},
+		{name: "does not serve outside the prefix", path: "/assets/app.js", status: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mux := chi.NewMux()
+			handlers.Static(mux, "/app/", files)
+
+			res := httptest.NewRecorder()
+			mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			if res.Code != test.status {
+				t.Fatalf("expected status %v, got %v", test.status, res.Code)
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+			if contentType := res.Header().Get("Content-Type"); contentType != test.contentType {
+				t.Fatalf("expected content type %v, got %v", test.contentType, contentType)
+			}
+			if body := strings.TrimSpace(res.Body.String()); body != test.body {
+				t.Fatalf("expected body %v, got %v", test.body, body)
+			}
+		})
+	}
+
+	t.Run("serves at the root without a prefix", func(t *testing.T) {
+		mux := chi.NewMux()
+		handlers.Static(mux, "", files)
+
+		for path, expected := range map[string]string{"/assets/app.css": "body {}", "/": "<html>app</html>", "/about": "<html>app</html>"} {
+			res := httptest.NewRecorder()
+			mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+			if res.Code != http.StatusOK || res.Body.String() != expected {
+				t.Fatalf("expected %q for %v, got %v %q", expected, path, res.Code, res.Body.String())
+			}
+		}
+	})
+}
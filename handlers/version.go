@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Version responds with the release as JSON, or "dev" if the release is empty, as it is for local builds.
+func Version(mux chi.Router, release string) {
+	if release == "" {
+		release = "dev"
+	}
+
+	mux.Get("/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(struct {
+			Release string `json:"release"`
+		}{Release: release})
+	})
+}
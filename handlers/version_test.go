@@ -0,0 +1,42 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"canvas/handlers"
+)
+
+func TestVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		release  string
+		expected string
+	}{
+		{name: "responds with the release", release: "abc123", expected: `{"release":"abc123"}`},
+		{name: "responds with dev without a release", release: "", expected: `{"release":"dev"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mux := chi.NewMux()
+			handlers.Version(mux, test.release)
+
+			res := httptest.NewRecorder()
+			mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+			if res.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+			}
+			if contentType := res.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Fatalf("expected content type application/json, got %v", contentType)
+			}
+			if body := strings.TrimSpace(res.Body.String()); body != test.expected {
+				t.Fatalf("expected body %v, got %v", test.expected, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestServer_admin(t *testing.T) {
+	t.Run("serves operational endpoints on the admin port only", func(t *testing.T) {
+		s, address := startServer(t, adminOptions(t, Options{MetricsEnabled: true}))
+		for _, path := range []string{"/metrics", "/healthz/live", "/healthz/ready", "/version"} {
+			if code := getStatus(t, adminURL(s, path)); code != http.StatusOK {
+				t.Fatalf("expected status %v for %v on the admin port, got %v", http.StatusOK, path, code)
+			}
+			if code := getStatus(t, "http://"+address+path); code != http.StatusNotFound {
+				t.Fatalf("expected status %v for %v on the main port, got %v", http.StatusNotFound, path, code)
+			}
+		}
+	})
+
+	t.Run("serves health probes by the time the main listener accepts requests", func(t *testing.T) {
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses := make(chan int, 1)
+		var s *Server
+		s = NewWithListener(adminOptions(t, Options{}), &firstAcceptListener{Listener: l, onAccept: func() {
+			res, err := http.Get(adminURL(s, "/healthz/live"))
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			_ = res.Body.Close()
+			statuses <- res.StatusCode
+		}})
+		startTestServer(t, s)
+
+		if status := <-statuses; status != http.StatusOK {
+			t.Fatalf("expected status %v from the admin port when the main listener first accepted, got %v", http.StatusOK, status)
+		}
+	})
+
+	t.Run("serves the log level on the admin port only", func(t *testing.T) {
+		s, address := startServer(t, adminOptions(t, Options{}))
+
+		if code := getStatus(t, adminURL(s, "/admin/loglevel")); code != http.StatusOK {
+			t.Fatalf("expected status %v on the admin port, got %v", http.StatusOK, code)
+		}
+		if code := getStatus(t, "http://"+address+"/admin/loglevel"); code != http.StatusNotFound {
+			t.Fatalf("expected status %v on the main port, got %v", http.StatusNotFound, code)
+		}
+	})
+
+	t.Run("serves recent logs on the admin port only", func(t *testing.T) {
+		s, address := startServer(t, adminOptions(t, Options{LogBufferSize: 10}))
+
+		if code := getStatus(t, adminURL(s, "/admin/logs")); code != http.StatusOK {
+			t.Fatalf("expected status %v on the admin port, got %v", http.StatusOK, code)
+		}
+		if code := getStatus(t, "http://"+address+"/admin/logs"); code != http.StatusNotFound {
+			t.Fatalf("expected status %v on the main port, got %v", http.StatusNotFound, code)
+		}
+		if entries := s.logBuffer.Entries(); len(entries) == 0 || entries[0].Message != "Server listening" {
+			t.Fatalf("expected the listening log in the buffer, got %v", entries)
+		}
+
+		s, _ = startServer(t, adminOptions(t, Options{}))
+		if code := getStatus(t, adminURL(s, "/admin/logs")); code != http.StatusNotFound {
+			t.Fatalf("expected status %v without a buffer size, got %v", http.StatusNotFound, code)
+		}
+	})
+
+	t.Run("serves the routes of the main port on the admin port only", func(t *testing.T) {
+		r := chi.NewRouter()
+		r.Get("/things/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		s := New(adminOptions(t, Options{Host: "localhost", Handler: r}))
+		address := startTestServer(t, s)
+
+		res, err := http.Get(adminURL(s, "/admin/routes"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = res.Body.Close()
+		}()
+		var body struct {
+			Routes []struct {
+				Pattern string
+			}
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Routes) != 1 || body.Routes[0].Pattern != "/things/{id}" {
+			t.Fatalf("expected only the main route, got %v", body.Routes)
+		}
+
+		if code := getStatus(t, "http://"+address+"/admin/routes"); code != http.StatusNotFound {
+			t.Fatalf("expected status %v on the main port, got %v", http.StatusNotFound, code)
+		}
+	})
+
+	t.Run("is not served without an admin port", func(t *testing.T) {
+		_, address := startServer(t, Options{})
+
+		if code := getStatus(t, "http://"+address+"/admin/loglevel"); code != http.StatusNotFound {
+			t.Fatalf("expected status %v, got %v", http.StatusNotFound, code)
+		}
+	})
+
+	t.Run("serves profiling on the admin port only when enabled", func(t *testing.T) {
+		s, address := startServer(t, adminOptions(t, Options{PprofEnabled: true}))
+
+		if code := getStatus(t, adminURL(s, "/debug/pprof/")); code != http.StatusOK {
+			t.Fatalf("expected status %v on the admin port, got %v", http.StatusOK, code)
+		}
+		if code := getStatus(t, "http://"+address+"/debug/pprof/"); code != http.StatusNotFound {
+			t.Fatalf("expected status %v on the main port, got %v", http.StatusNotFound, code)
+		}
+
+		s, _ = startServer(t, adminOptions(t, Options{}))
+		if code := getStatus(t, adminURL(s, "/debug/pprof/")); code != http.StatusNotFound {
+			t.Fatalf("expected status %v when disabled, got %v", http.StatusNotFound, code)
+		}
+	})
+
+	t.Run("does not serve profiling without an admin port", func(t *testing.T) {
+		_, address := startServer(t, Options{PprofEnabled: true})
+
+		if code := getStatus(t, "http://"+address+"/debug/pprof/"); code != http.StatusNotFound {
+			t.Fatalf("expected status %v, got %v", http.StatusNotFound, code)
+		}
+	})
+
+	t.Run("serves garbage collection on the admin port only when enabled", func(t *testing.T) {
+		s, address := startServer(t, adminOptions(t, Options{DebugGC: true}))
+
+		if code := postStatus(t, adminURL(s, "/admin/gc")); code != http.StatusOK {
+			t.Fatalf("expected status %v on the admin port, got %v", http.StatusOK, code)
+		}
+		if code := postStatus(t, "http://"+address+"/admin/gc"); code != http.StatusNotFound {
+			t.Fatalf("expected status %v on the main port, got %v", http.StatusNotFound, code)
+		}
+
+		s, _ = startServer(t, adminOptions(t, Options{}))
+		if code := postStatus(t, adminURL(s, "/admin/gc")); code != http.StatusNotFound {
+			t.Fatalf("expected status %v when disabled, got %v", http.StatusNotFound, code)
+		}
+	})
+
+	t.Run("stops the admin server together with the main server", func(t *testing.T) {
+		s, _ := startServer(t, adminOptions(t, Options{}))
+		liveURL := adminURL(s, "/healthz/live")
+
+		if err := s.stopWithTimeout(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := http.Get(liveURL); err == nil {
+			t.Fatal("admin server still responding after stop")
+		}
+	})
+}
+
+const (
+	testAdminUser     = "admin"
+	testAdminPassword = "secret"
+)
+
+// adminOptions returns opts with an AdminPort and admin credentials.
+func adminOptions(t *testing.T, opts Options) Options {
+	t.Helper()
+
+	opts.AdminPort = freePort(t)
+	opts.AdminUser = testAdminUser
+	opts.AdminPassword = testAdminPassword
+	return opts
+}
+
+// postStatus does a POST request without a body to url and returns the status code.
+func postStatus(t *testing.T, url string) int {
+	t.Helper()
+
+	res, err := http.Post(url, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	return res.StatusCode
+}
+
+// firstAcceptListener calls onAccept before the first Accept, which is when a server starts serving on it.
+type firstAcceptListener struct {
+	net.Listener
+	once     sync.Once
+	onAccept func()
+}
+
+func (l *firstAcceptListener) Accept() (net.Conn, error) {
+	l.once.Do(l.onAccept)
+	return l.Listener.Accept()
+}
+
+// adminURL for path on the admin server of s, including the credentials.
+func adminURL(s *Server, path string) string {
+	return "http://" + testAdminUser + ":" + testAdminPassword + "@" + s.AdminAddr().String() + path
+}
+
+// getStatus does a GET request to url and returns the status code.
+func getStatus(t *testing.T, url string) int {
+	t.Helper()
+
+	res, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	return res.StatusCode
+}
+
+func TestServer_adminShutdownOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		first bool
+	}{
+		{name: "keeps the admin server responding while the main server drains"},
+		{name: "shuts down the admin server first with AdminShutdownFirst", first: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := New(adminOptions(t, Options{AdminShutdownFirst: test.first, Host: "localhost"}))
+			started := make(chan struct{})
+			release := make(chan struct{})
+			s.mux.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				<-release
+			})
+			address := startTestServer(t, s)
+			liveURL := adminURL(s, "/healthz/live")
+
+			go func() {
+				res, err := http.Get("http://" + address + "/slow")
+				if err == nil {
+					_ = res.Body.Close()
+				}
+			}()
+			<-started
+
+			stopped := make(chan error, 1)
+			go func() {
+				stopped <- s.Stop(context.Background())
+			}()
+			// Give Stop time to get to waiting for the slow request.
+			time.Sleep(50 * time.Millisecond)
+
+			res, err := http.Get(liveURL)
+			if test.first {
+				if err == nil {
+					_ = res.Body.Close()
+					t.Fatal("admin server still responding while the main server drains")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				_ = res.Body.Close()
+				if res.StatusCode != http.StatusOK {
+					t.Fatalf("expected status %v, got %v", http.StatusOK, res.StatusCode)
+				}
+			}
+
+			close(release)
+			if err := <-stopped; err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+// requireBasicAuth is middleware that only lets through requests with the given HTTP Basic Auth credentials,
+// and responds with 401 Unauthorized otherwise. Credentials are compared in constant time.
+func requireBasicAuth(user, password string) func(http.Handler) http.Handler {
+	// Comparing hashes keeps the comparison constant-time even if the lengths differ.
+	userHash := sha256.Sum256([]byte(user))
+	passwordHash := sha256.Sum256([]byte(password))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, p, ok := r.BasicAuth()
+			givenUserHash := sha256.Sum256([]byte(u))
+			givenPasswordHash := sha256.Sum256([]byte(p))
+
+			userMatch := subtle.ConstantTimeCompare(userHash[:], givenUserHash[:])
+			passwordMatch := subtle.ConstantTimeCompare(passwordHash[:], givenPasswordHash[:])
+			if !ok || userMatch&passwordMatch != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
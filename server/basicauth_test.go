@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireBasicAuth(t *testing.T) {
+	h := requireBasicAuth("admin", "secret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name           string
+		user, password string
+		noAuth         bool
+		expected       int
+	}{
+		{name: "lets through correct credentials", user: "admin", password: "secret", expected: http.StatusOK},
+		{name: "rejects a wrong password", user: "admin", password: "guess", expected: http.StatusUnauthorized},
+		{name: "rejects a wrong user", user: "root", password: "secret", expected: http.StatusUnauthorized},
+		{name: "rejects requests without credentials", noAuth: true, expected: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if !test.noAuth {
+				req.SetBasicAuth(test.user, test.password)
+			}
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, req)
+
+			if res.Code != test.expected {
+				t.Fatalf("expected status %v, got %v", test.expected, res.Code)
+			}
+			if test.expected == http.StatusUnauthorized && res.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("expected a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestServer_adminAuth(t *testing.T) {
+	t.Run("refuses to start the admin server without credentials", func(t *testing.T) {
+		for _, opts := range []Options{{AdminPort: freePort(t)}, {AdminPort: freePort(t), AdminUser: "admin"}} {
+			s := New(opts)
+			if err := s.Start(t.Context()); !errors.Is(err, ErrInvalidOptions) {
+				t.Fatalf("expected ErrInvalidOptions, got %v", err)
+			}
+		}
+	})
+
+	t.Run("protects the admin endpoints, metrics, and pprof", func(t *testing.T) {
+		s, _ := startServer(t, adminOptions(t, Options{MetricsEnabled: true, PprofEnabled: true}))
+
+		for _, path := range []string{"/admin/config", "/metrics", "/debug/pprof/"} {
+			if code := getStatus(t, "http://"+s.AdminAddr().String()+path); code != http.StatusUnauthorized {
+				t.Fatalf("expected status %v without credentials for %v, got %v", http.StatusUnauthorized, path, code)
+			}
+			if code := getStatus(t, adminURL(s, path)); code != http.StatusOK {
+				t.Fatalf("expected status %v with credentials for %v, got %v", http.StatusOK, path, code)
+			}
+		}
+	})
+
+	t.Run("leaves the health probes open", func(t *testing.T) {
+		s, _ := startServer(t, adminOptions(t, Options{}))
+
+		for _, path := range []string{"/healthz/live", "/healthz/ready"} {
+			if code := getStatus(t, "http://"+s.AdminAddr().String()+path); code != http.StatusOK {
+				t.Fatalf("expected status %v without credentials for %v, got %v", http.StatusOK, path, code)
+			}
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// defaultMaxRequestBodyBytes is 1 MB.
+const defaultMaxRequestBodyBytes = 1 << 20
+
+// limitBody is middleware that limits request bodies to limit bytes.
+// Reading more than that fails with an *http.MaxBytesError, and the response becomes 413 Request Entity Too Large,
+// unless the handler already started writing it. Use MaxBodyBytes to change the limit for specific routes.
+func limitBody(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := &limitedBody{ReadCloser: r.Body, contentLength: r.ContentLength, limit: limit}
+			lw := &bodyLimitWriter{ResponseWriter: w, body: body}
+			r.Body = body
+
+			next.ServeHTTP(lw, r)
+
+			if body.exceeded && !lw.wroteHeader {
+				lw.WriteHeader(http.StatusRequestEntityTooLarge)
+			}
+		})
+	}
+}
+
+// MaxBodyBytes is middleware that changes the request body limit for the routes it's used on,
+// for example to raise it for an upload endpoint:
+//
+//	mux.With(server.MaxBodyBytes(50 << 20)).Post("/upload", upload)
+//
+// It must run before the body is read.
+func MaxBodyBytes(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if body, ok := r.Body.(*limitedBody); ok {
+				body.limit = limit
+				next.ServeHTTP(w, r)
+				return
+			}
+			limitBody(limit)(next).ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitedBody is like the reader returned by http.MaxBytesReader, except that the limit can be changed before reading.
+type limitedBody struct {
+	io.ReadCloser
+	contentLength int64
+	exceeded      bool
+	limit         int64
+	read          int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded || b.contentLength > b.limit {
+		b.exceeded = true
+		return 0, &http.MaxBytesError{Limit: b.limit}
+	}
+
+	// Read one byte more than allowed, to know whether the limit is exceeded.
+	if remaining := b.limit - b.read + 1; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > b.limit {
+		b.exceeded = true
+		return n - int(b.read-b.limit), &http.MaxBytesError{Limit: b.limit}
+	}
+	return n, err
+}
+
+// bodyLimitWriter replaces the response with 413 Request Entity Too Large if the request body limit was exceeded
+// before the response was started.
+type bodyLimitWriter struct {
+	http.ResponseWriter
+	body        *limitedBody
+	replaced    bool
+	wroteHeader bool
+}
+
+func (w *bodyLimitWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	if w.body.exceeded {
+		w.replaced = true
+		w.Header().Del("Content-Length")
+		w.Header().Set("Connection", "close")
+		http.Error(w.ResponseWriter, fmt.Sprintf("Request body too large, the limit is %v bytes", w.body.limit),
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *bodyLimitWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.replaced {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush the underlying http.ResponseWriter, after starting the response like Write.
+func (w *bodyLimitWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.replaced {
+		_ = http.NewResponseController(w.ResponseWriter).Flush()
+	}
+}
+
+// Hijack the connection of the underlying http.ResponseWriter, if it supports that.
+func (w *bodyLimitWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap the underlying http.ResponseWriter, so http.ResponseController can reach it.
+func (w *bodyLimitWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
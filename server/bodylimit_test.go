@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestLimitBody(t *testing.T) {
+	readBody := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name    string
+		size    int
+		chunked bool
+		code    int
+	}{
+		{name: "accepts a body just under the limit", size: 9, code: http.StatusOK},
+		{name: "accepts a body at the limit", size: 10, code: http.StatusOK},
+		{name: "rejects a body just over the limit", size: 11, code: http.StatusRequestEntityTooLarge},
+		{name: "rejects a chunked body just over the limit", size: 11, chunked: true, code: http.StatusRequestEntityTooLarge},
+		{name: "accepts a chunked body at the limit", size: 10, chunked: true, code: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := limitBody(10)(readBody)
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", test.size)))
+			if test.chunked {
+				req.ContentLength = -1
+			}
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, req)
+
+			if res.Code != test.code {
+				t.Fatalf("expected status %v, got %v", test.code, res.Code)
+			}
+			if test.code == http.StatusRequestEntityTooLarge && !strings.Contains(res.Body.String(), "limit is 10 bytes") {
+				t.Fatalf("expected a clear message, got %q", res.Body.String())
+			}
+		})
+	}
+
+	t.Run("returns an http.MaxBytesError to the handler", func(t *testing.T) {
+		var err error
+		h := limitBody(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err = io.ReadAll(r.Body)
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", 11))))
+
+		var maxBytesErr *http.MaxBytesError
+		if !errors.As(err, &maxBytesErr) {
+			t.Fatalf("expected an http.MaxBytesError, got %v", err)
+		}
+	})
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	t.Run("raises the limit for a route", func(t *testing.T) {
+		mux := chi.NewMux()
+		mux.Use(limitBody(10))
+		readBody := func(w http.ResponseWriter, r *http.Request) {
+			if _, err := io.ReadAll(r.Body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+		}
+		mux.With(MaxBodyBytes(100)).Post("/upload", readBody)
+		mux.Post("/other", readBody)
+
+		body := strings.Repeat("a", 50)
+
+		res := httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(body)))
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v on the raised route, got %v", http.StatusOK, res.Code)
+		}
+
+		res = httptest.NewRecorder()
+		mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/other", strings.NewReader(body)))
+		if res.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("expected status %v on other routes, got %v", http.StatusRequestEntityTooLarge, res.Code)
+		}
+	})
+}
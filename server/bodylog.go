@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// defaultDebugBodiesMaxBytes is how much of each body is logged by default.
+const defaultDebugBodiesMaxBytes = 4 << 10
+
+// defaultDebugBodiesRedact are the header and field names redacted by default.
+var defaultDebugBodiesRedact = []string{"Authorization", "Cookie", "Set-Cookie", "password", "secret", "token"}
+
+type bodyLogOptions struct {
+	// MaxBytes of each body that are logged. Longer bodies are truncated.
+	MaxBytes int
+	// Redact are the names of headers, JSON fields, and form fields whose values are replaced, case-insensitively.
+	Redact []string
+}
+
+// logBodies is middleware that logs the headers and bodies of requests and their responses, for debugging.
+// Bodies are logged up to the maximum size, and the values of redacted headers and fields are replaced.
+// The part of the request body read for logging is put back in front of the rest, so handlers still read all of it.
+func logBodies(log func() *zap.Logger, opts bodyLogOptions) func(http.Handler) http.Handler {
+	redactor := newBodyRedactor(opts.Redact)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Read one byte more than is logged, to know whether the body is truncated.
+			requestBody, err := io.ReadAll(io.LimitReader(r.Body, int64(opts.MaxBytes)+1))
+			if err != nil {
+				log().Info("Error reading request body for logging", zap.Error(err))
+			}
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(requestBody), r.Body), r.Body}
+
+			bw := &bodyLogWriter{ResponseWriter: w, max: opts.MaxBytes}
+			next.ServeHTTP(bw, r)
+
+			requestTruncated := len(requestBody) > opts.MaxBytes
+			if requestTruncated {
+				requestBody = requestBody[:opts.MaxBytes]
+			}
+			status := bw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			log().Info("Request and response bodies",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("request_id", RequestIDFromContext(r.Context())),
+				zap.Any("request_headers", redactor.headers(r.Header)),
+				zap.String("request_body", redactor.body(requestBody)),
+				zap.Bool("request_body_truncated", requestTruncated),
+				zap.Int("status", status),
+				zap.Any("response_headers", redactor.headers(bw.Header())),
+				zap.String("response_body", redactor.body(bw.body.Bytes())),
+				zap.Bool("response_body_truncated", bw.truncated),
+			)
+		})
+	}
+}
+
+// bodyLogWriter keeps the status and up to max bytes of the body written to it.
+type bodyLogWriter struct {
+	http.ResponseWriter
+	body      bytes.Buffer
+	max       int
+	status    int
+	truncated bool
+}
+
+func (w *bodyLogWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if left := w.max - w.body.Len(); left < len(b) {
+		w.body.Write(b[:max(left, 0)])
+		w.truncated = true
+	} else {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap the underlying http.ResponseWriter, so http.ResponseController can reach it.
+func (w *bodyLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// bodyRedactor replaces the values of headers and fields with certain names.
+type bodyRedactor struct {
+	names        map[string]bool
+	jsonPatterns []*regexp.Regexp
+	formPatterns []*regexp.Regexp
+}
+
+func newBodyRedactor(names []string) *bodyRedactor {
+	r := &bodyRedactor{names: map[string]bool{}}
+	for _, name := range names {
+		r.names[strings.ToLower(name)] = true
+		quoted := regexp.QuoteMeta(name)
+		// JSON fields with string or other values, including strings cut off by truncation.
+		r.jsonPatterns = append(r.jsonPatterns, regexp.MustCompile(`(?i)("`+quoted+`"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`))
+		r.formPatterns = append(r.formPatterns, regexp.MustCompile(`(?i)((?:^|&)`+quoted+`=)[^&]*`))
+	}
+	return r
+}
+
+func (r *bodyRedactor) headers(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+	for name, values := range h {
+		if r.names[strings.ToLower(name)] {
+			headers[name] = redacted
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+func (r *bodyRedactor) body(b []byte) string {
+	for _, p := range r.jsonPatterns {
+		b = p.ReplaceAll(b, []byte(`${1}"`+redacted+`"`))
+	}
+	for _, p := range r.formPatterns {
+		b = p.ReplaceAll(b, []byte(`${1}`+redacted))
+	}
+	return string(b)
+}
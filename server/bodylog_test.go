@@ -0,0 +1,110 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogBodies(t *testing.T) {
+	// serve a request with body through logBodies, with a handler echoing the request body, and return the log entry.
+	serve := func(t *testing.T, opts bodyLogOptions, req *http.Request) (observer.LoggedEntry, *httptest.ResponseRecorder) {
+		t.Helper()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		log := zap.New(core)
+		h := logBodies(func() *zap.Logger { return log }, opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Error(err)
+			}
+			w.Header().Set("Set-Cookie", "session=abc")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write(body)
+		}))
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+
+		entries := logs.FilterMessage("Request and response bodies").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected one log entry, got %v", len(entries))
+		}
+		return entries[0], res
+	}
+
+	t.Run("logs bodies and lets the handler read the whole request body", func(t *testing.T) {
+		body := `{"name":"Canvas"}`
+		entry, res := serve(t, bodyLogOptions{MaxBytes: 100}, httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(body)))
+
+		if res.Body.String() != body {
+			t.Fatalf("expected the handler to read %v, got %v", body, res.Body.String())
+		}
+		fields := entry.ContextMap()
+		if fields["request_body"] != body || fields["response_body"] != body {
+			t.Fatalf("expected both bodies to be %v, got %v and %v", body, fields["request_body"], fields["response_body"])
+		}
+		if fields["status"] != int64(http.StatusCreated) {
+			t.Fatalf("expected status %v, got %v", http.StatusCreated, fields["status"])
+		}
+	})
+
+	t.Run("truncates bodies over the limit", func(t *testing.T) {
+		body := strings.Repeat("a", 20)
+		entry, res := serve(t, bodyLogOptions{MaxBytes: 8}, httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(body)))
+
+		if res.Body.String() != body {
+			t.Fatalf("expected the handler to read the whole body, got %v", res.Body.String())
+		}
+		fields := entry.ContextMap()
+		if fields["request_body"] != "aaaaaaaa" || fields["request_body_truncated"] != true {
+			t.Fatalf("expected the truncated request body, got %v", fields["request_body"])
+		}
+		if fields["response_body"] != "aaaaaaaa" || fields["response_body_truncated"] != true {
+			t.Fatalf("expected the truncated response body, got %v", fields["response_body"])
+		}
+	})
+
+	t.Run("redacts headers and fields", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"user":"me","password":"hunter2","Token": 12345}`))
+		req.Header.Set("Authorization", "Bearer sesame")
+		entry, _ := serve(t, bodyLogOptions{MaxBytes: 100, Redact: defaultDebugBodiesRedact}, req)
+
+		fields := entry.ContextMap()
+		for _, name := range []string{"request_body", "response_body"} {
+			body := fields[name].(string)
+			if strings.Contains(body, "hunter2") || strings.Contains(body, "12345") {
+				t.Fatalf("expected redacted fields in %v, got %v", name, body)
+			}
+			if !strings.Contains(body, `"user":"me"`) || !strings.Contains(body, `"password":"[redacted]"`) {
+				t.Fatalf("expected other fields to be kept in %v, got %v", name, body)
+			}
+		}
+		if headers := fields["request_headers"].(map[string]string); headers["Authorization"] != redacted {
+			t.Fatalf("expected the Authorization header to be redacted, got %v", headers["Authorization"])
+		}
+		if headers := fields["response_headers"].(map[string]string); headers["Set-Cookie"] != redacted {
+			t.Fatalf("expected the Set-Cookie header to be redacted, got %v", headers["Set-Cookie"])
+		}
+	})
+
+	t.Run("redacts form fields and fields cut off by truncation", func(t *testing.T) {
+		entry, _ := serve(t, bodyLogOptions{MaxBytes: 24, Redact: []string{"password"}},
+			httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`user=me&password=hunter2&remember=1`)))
+		if body := entry.ContextMap()["request_body"]; body != "user=me&password=[redacted]" {
+			t.Fatalf("expected the form field to be redacted, got %v", body)
+		}
+
+		entry, _ = serve(t, bodyLogOptions{MaxBytes: 20, Redact: []string{"password"}},
+			httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"password":"hunter2hunter2"}`)))
+		if body := entry.ContextMap()["request_body"].(string); strings.Contains(body, "hunter") {
+			t.Fatalf("expected the truncated field to be redacted, got %v", body)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+)
+
+// BufferedResponseWriter is an http.ResponseWriter that keeps the status, header, and body written to it,
+// instead of sending them. Middleware can pass one to the next handler, inspect and change the response afterwards,
+// and then Send it. Flushing does nothing while buffering, and hijacking the connection isn't supported.
+// For example:
+//
+//	bw := server.NewBufferedResponseWriter(w)
+//	next.ServeHTTP(bw, r)
+//	bw.Header().Set("X-Body-Length", strconv.Itoa(bw.Body.Len()))
+//	bw.Send()
+type BufferedResponseWriter struct {
+	// Body written so far.
+	Body bytes.Buffer
+	// Status written, or zero if there was none yet. Send uses 200 OK then.
+	Status int
+	header http.Header
+	w      http.ResponseWriter
+}
+
+// NewBufferedResponseWriter for sending to w. The header starts out as a copy of the header of w.
+func NewBufferedResponseWriter(w http.ResponseWriter) *BufferedResponseWriter {
+	return &BufferedResponseWriter{header: w.Header().Clone(), w: w}
+}
+
+func (b *BufferedResponseWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *BufferedResponseWriter) WriteHeader(code int) {
+	if b.Status == 0 {
+		b.Status = code
+	}
+}
+
+func (b *BufferedResponseWriter) Write(p []byte) (int, error) {
+	if b.Status == 0 {
+		b.Status = http.StatusOK
+	}
+	return b.Body.Write(p)
+}
+
+// Flush does nothing, because the response is only sent with Send.
+// It's there so handlers that flush keep working, with the response arriving at once.
+func (b *BufferedResponseWriter) Flush() {}
+
+// Hijack returns an error wrapping http.ErrNotSupported, because the buffered response would be lost.
+func (b *BufferedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, fmt.Errorf("%w: can't hijack a buffered response", http.ErrNotSupported)
+}
+
+// Send the buffered response. The header replaces the header of the underlying ResponseWriter.
+func (b *BufferedResponseWriter) Send() error {
+	h := b.w.Header()
+	clear(h)
+	maps.Copy(h, b.header)
+
+	status := b.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	b.w.WriteHeader(status)
+	if b.Body.Len() == 0 {
+		return nil
+	}
+	_, err := b.w.Write(b.Body.Bytes())
+	return err
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBufferedResponseWriter(t *testing.T) {
+	t.Run("lets middleware change the response before sending it", func(t *testing.T) {
+		upper := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				bw := NewBufferedResponseWriter(w)
+				next.ServeHTTP(bw, r)
+
+				if bw.Status != http.StatusTeapot {
+					t.Errorf("expected status %v, got %v", http.StatusTeapot, bw.Status)
+				}
+				body := bytes.ToUpper(bw.Body.Bytes())
+				bw.Body.Reset()
+				bw.Body.Write(body)
+				bw.Status = http.StatusOK
+				bw.Header().Set("X-Transformed", "true")
+				bw.Header().Del("X-Internal")
+				if err := bw.Send(); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+		h := upper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("X-Internal", "secret")
+			w.WriteHeader(http.StatusTeapot)
+			_, _ = w.Write([]byte("hello"))
+		}))
+
+		res := httptest.NewRecorder()
+		res.Header().Set(requestIDHeader, "abc")
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if res.Code != http.StatusOK || res.Body.String() != "HELLO" {
+			t.Fatalf("expected the transformed response, got %v %q", res.Code, res.Body.String())
+		}
+		expected := map[string]string{"Content-Type": "text/plain", "X-Transformed": "true", "X-Internal": "", requestIDHeader: "abc"}
+		for name, value := range expected {
+			if got := res.Header().Get(name); got != value {
+				t.Fatalf("expected header %v to be %q, got %q", name, value, got)
+			}
+		}
+	})
+
+	t.Run("sends nothing until Send, even when flushed", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		bw := NewBufferedResponseWriter(res)
+
+		_, _ = bw.Write([]byte("hello"))
+		if err := http.NewResponseController(bw).Flush(); err != nil {
+			t.Fatalf("expected flushing to succeed, got %v", err)
+		}
+		if res.Flushed || res.Body.Len() != 0 {
+			t.Fatalf("expected nothing sent, got %q", res.Body.String())
+		}
+
+		if err := bw.Send(); err != nil {
+			t.Fatal(err)
+		}
+		if res.Code != http.StatusOK || res.Body.String() != "hello" {
+			t.Fatalf("expected the buffered response, got %v %q", res.Code, res.Body.String())
+		}
+	})
+
+	t.Run("does not support hijacking", func(t *testing.T) {
+		bw := NewBufferedResponseWriter(httptest.NewRecorder())
+
+		if _, _, err := http.NewResponseController(bw).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+			t.Fatalf("expected http.ErrNotSupported, got %v", err)
+		}
+	})
+
+	t.Run("responds with 200 OK if nothing was written", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		if err := NewBufferedResponseWriter(res).Send(); err != nil {
+			t.Fatal(err)
+		}
+		if res.Code != http.StatusOK || res.Body.Len() != 0 {
+			t.Fatalf("expected an empty 200 OK, got %v %q", res.Code, res.Body.String())
+		}
+	})
+
+	t.Run("leaves responses alone in middleware not buffering them", func(t *testing.T) {
+		h := ETag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hello"))
+			if err := http.NewResponseController(w).Flush(); err != nil {
+				t.Error(err)
+			}
+		}))
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/", nil))
+
+		if !res.Flushed || res.Body.String() != "hello" || res.Header().Get("ETag") != "" {
+			t.Fatalf("expected the response to be passed through and flushed, got %q %v", res.Body.String(), res.Header())
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is middleware that keeps successful GET responses in memory for ttl, and serves them from there
+// without calling the handler again. Responses are keyed by method, path, and query, and at most maxEntries
+// are kept, evicting the least recently used. Responses setting a cookie or with Cache-Control: no-store are never cached.
+// Only use it on routes whose responses are the same for every client, for example:
+//
+//	mux.With(server.Cache(time.Minute, 100)).Get("/reports/{id}", report)
+func Cache(ttl time.Duration, maxEntries int) func(http.Handler) http.Handler {
+	return newResponseCache(ttl, maxEntries).middleware
+}
+
+// responseCache is an LRU cache of responses, with entries expiring after a TTL.
+type responseCache struct {
+	entries    map[string]*list.Element
+	lock       sync.Mutex
+	lru        *list.List
+	maxEntries int
+	// now is the current time, which tests can replace.
+	now func() time.Time
+	ttl time.Duration
+}
+
+// cachedResponse is what's stored in a responseCache.
+type cachedResponse struct {
+	body    []byte
+	expires time.Time
+	header  http.Header
+	key     string
+	status  int
+}
+
+func newResponseCache(ttl time.Duration, maxEntries int) *responseCache {
+	return &responseCache{
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		maxEntries: max(maxEntries, 1),
+		now:        time.Now,
+		ttl:        ttl,
+	}
+}
+
+func (c *responseCache) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery
+		if res, ok := c.get(key); ok {
+			res.write(w)
+			return
+		}
+
+		// Headers set by middleware before the handler, like the request ID, belong to this request only.
+		before := w.Header().Clone()
+		cw := &cacheWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		if cw.status == http.StatusOK && cacheable(w.Header()) {
+			c.add(&cachedResponse{body: cw.body.Bytes(), header: changedHeader(before, cw.sentHeader()), key: key, status: cw.status})
+		}
+	})
+}
+
+// write the stored response to w.
+func (res *cachedResponse) write(w http.ResponseWriter) {
+	h := w.Header()
+	for name, values := range res.header {
+		h[name] = values
+	}
+	w.WriteHeader(res.status)
+	_, _ = w.Write(res.body)
+}
+
+// cacheable reports whether a response with header h may be stored.
+func cacheable(h http.Header) bool {
+	if h.Get("Set-Cookie") != "" {
+		return false
+	}
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+			return false
+		}
+	}
+	return true
+}
+
+// changedHeader returns the fields of after that are new or different compared to before.
+func changedHeader(before, after http.Header) http.Header {
+	changed := http.Header{}
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			changed[name] = slices.Clone(values)
+		}
+	}
+	return changed
+}
+
+// get the response for key, if it's there and not expired.
+func (c *responseCache) get(key string) (*cachedResponse, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	res := e.Value.(*cachedResponse)
+	if !c.now().Before(res.expires) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return res, true
+}
+
+// add res, evicting the least recently used response if the cache is full.
+func (c *responseCache) add(res *cachedResponse) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	res.expires = c.now().Add(c.ttl)
+	if e, ok := c.entries[res.key]; ok {
+		e.Value = res
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	c.entries[res.key] = c.lru.PushFront(res)
+	if c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// cacheWriter writes the response through to the ResponseWriter, and keeps a copy of the status, header, and body.
+// The header is copied when the response starts, before middleware further out changes it for the body it encodes,
+// like compress setting Content-Encoding, since the copy of the body is from before that encoding too.
+type cacheWriter struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	header http.Header
+	status int
+}
+
+func (w *cacheWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+		w.header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+		w.header = w.Header().Clone()
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// sentHeader returns the header as it was when the response started, or as it is now if nothing was written.
+func (w *cacheWriter) sentHeader() http.Header {
+	if w.header == nil {
+		return w.Header()
+	}
+	return w.header
+}
+
+// Unwrap the underlying http.ResponseWriter, so http.ResponseController can reach it.
+func (w *cacheWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
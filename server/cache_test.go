@@ -0,0 +1,161 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	t.Run("serves cached responses without calling the handler again", func(t *testing.T) {
+		c := newResponseCache(time.Minute, 10)
+		calls := 0
+		h := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("report " + r.URL.Query().Get("id")))
+		}))
+
+		for i := 0; i < 3; i++ {
+			res := serveCached(h, "/report?id=1")
+			if res.Code != http.StatusOK || res.Body.String() != "report 1" || res.Header().Get("Content-Type") != "text/plain" {
+				t.Fatalf("unexpected response %v %q %v", res.Code, res.Body.String(), res.Header())
+			}
+		}
+		if calls != 1 {
+			t.Fatalf("expected 1 handler call, got %v", calls)
+		}
+
+		if res := serveCached(h, "/report?id=2"); res.Body.String() != "report 2" {
+			t.Fatalf("expected a separate entry for another query, got %q", res.Body.String())
+		}
+		if calls != 2 {
+			t.Fatalf("expected 2 handler calls, got %v", calls)
+		}
+	})
+
+	t.Run("expires entries after the TTL", func(t *testing.T) {
+		c := newResponseCache(time.Minute, 10)
+		now := time.Now()
+		c.now = func() time.Time { return now }
+		calls := 0
+		h := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			_, _ = w.Write([]byte(strconv.Itoa(calls)))
+		}))
+
+		serveCached(h, "/")
+		now = now.Add(59 * time.Second)
+		if res := serveCached(h, "/"); res.Body.String() != "1" {
+			t.Fatalf("expected cached response before the TTL, got %q", res.Body.String())
+		}
+		now = now.Add(time.Second)
+		if res := serveCached(h, "/"); res.Body.String() != "2" {
+			t.Fatalf("expected a fresh response after the TTL, got %q", res.Body.String())
+		}
+	})
+
+	t.Run("evicts the least recently used entry when full", func(t *testing.T) {
+		c := newResponseCache(time.Minute, 2)
+		calls := map[string]int{}
+		h := c.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls[r.URL.Path]++
+		}))
+
+		serveCached(h, "/a")
+		serveCached(h, "/b")
+		serveCached(h, "/a")
+		serveCached(h, "/c")
+		serveCached(h, "/a")
+		serveCached(h, "/b")
+
+		if calls["/a"] != 1 || calls["/b"] != 2 || calls["/c"] != 1 {
+			t.Fatalf("expected /b to be evicted, got calls %v", calls)
+		}
+	})
+
+	t.Run("does not cache uncacheable responses", func(t *testing.T) {
+		tests := map[string]http.HandlerFunc{
+			"Set-Cookie": func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Set-Cookie", "session=abc")
+			},
+			"Cache-Control: no-store": func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", "private, no-store")
+			},
+			"errors": func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+		}
+		for name, handler := range tests {
+			calls := 0
+			h := newResponseCache(time.Minute, 10).middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				handler(w, r)
+			}))
+			serveCached(h, "/")
+			serveCached(h, "/")
+			if calls != 2 {
+				t.Fatalf("expected response with %v not to be cached, got %v handler calls", name, calls)
+			}
+		}
+	})
+
+	t.Run("serves cached responses encoded for each client behind compression", func(t *testing.T) {
+		calls := 0
+		h := compress(newResponseCache(time.Minute, 10).middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			_, _ = w.Write([]byte("report"))
+		})))
+
+		for _, acceptEncoding := range []string{"gzip", "gzip", ""} {
+			res := makeCompressionRequest(h, acceptEncoding)
+			encoding := res.Header().Get("Content-Encoding")
+			if acceptEncoding == "" {
+				if encoding != "" || res.Body.String() != "report" {
+					t.Fatalf("expected a plain response, got %q encoded %q", res.Body.String(), encoding)
+				}
+				continue
+			}
+			if encoding != "gzip" {
+				t.Fatalf("expected gzip content encoding, got %q", encoding)
+			}
+			if decoded := gunzip(t, res.Body); decoded != "report" {
+				t.Fatalf("expected decoded body report, got %q", decoded)
+			}
+		}
+		if calls != 1 {
+			t.Fatalf("expected 1 handler call, got %v", calls)
+		}
+	})
+
+	t.Run("does not cache other methods or headers set before the handler", func(t *testing.T) {
+		calls := 0
+		h := newResponseCache(time.Minute, 10).middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+		}))
+
+		for _, method := range []string{http.MethodPost, http.MethodPost} {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/", nil))
+		}
+		if calls != 2 {
+			t.Fatalf("expected POST not to be cached, got %v handler calls", calls)
+		}
+
+		for _, id := range []string{"first", "second"} {
+			res := httptest.NewRecorder()
+			res.Header().Set(requestIDHeader, id)
+			h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+			if got := res.Header().Get(requestIDHeader); got != id {
+				t.Fatalf("expected request ID %v, got %v", id, got)
+			}
+		}
+	})
+}
+
+func serveCached(h http.Handler, target string) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, target, nil))
+	return res
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// certificate holds the TLS certificate presented in new handshakes.
+// It can be swapped while serving, and connections that are already established keep the certificate they were made with.
+type certificate struct {
+	current atomic.Pointer[tls.Certificate]
+}
+
+// load the certificate and key from PEM files, keeping the current certificate if that fails.
+func (c *certificate) load(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	c.current.Store(&cert)
+	return nil
+}
+
+// get is a tls.Config.GetCertificate callback returning the current certificate.
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert := c.current.Load()
+	if cert == nil {
+		return nil, errors.New("no TLS certificate loaded")
+	}
+	return cert, nil
+}
+
+// ReloadCertificate reads the TLS certificate and key files again, and uses them for new connections.
+// Open connections are not affected. If reading fails, an error is returned and the previous certificate stays in use.
+// It does nothing if the Server doesn't serve HTTPS.
+func (s *Server) ReloadCertificate() error {
+	if s.tlsCert == "" || s.tlsKey == "" {
+		return nil
+	}
+	if err := s.certificate.load(s.tlsCert, s.tlsKey); err != nil {
+		return err
+	}
+	s.logger().Info("Reloaded TLS certificate", zap.String("cert", s.tlsCert))
+	return nil
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// Check the Server's configuration the way Start would, without serving: the Options must make sense together,
+// the TLS certificate must load, and the ports must be free to bind, which they're only briefly.
+// A Unix socket or a listener given to NewWithListener isn't bound, because that could disturb a running server.
+// It's for checking a configuration before rolling it out. Errors with the Options wrap ErrInvalidOptions.
+func (s *Server) Check(ctx context.Context) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+
+	if s.tlsCert != "" {
+		var c certificate
+		if err := c.load(s.tlsCert, s.tlsKey); err != nil {
+			return fmt.Errorf("error loading TLS certificate: %w", err)
+		}
+	}
+
+	var ls []net.Listener
+	defer func() {
+		closeAll(ls)
+	}()
+	if s.adminServer != nil {
+		l, err := s.listen(ctx, splitHosts(s.host)[0], s.adminPort)
+		if err != nil {
+			return err
+		}
+		ls = append(ls, l)
+	}
+	if s.listener == nil && s.unixSocket == "" {
+		mainLs, err := s.listenAll(ctx, splitHosts(s.host), s.port)
+		if err != nil {
+			return err
+		}
+		ls = append(ls, mainLs...)
+	}
+	return nil
+}
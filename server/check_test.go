@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestServer_Check(t *testing.T) {
+	t.Run("passes a valid configuration and releases the ports", func(t *testing.T) {
+		certFile, keyFile, _ := writeTestCert(t)
+		port := freePort(t)
+		s := New(Options{Host: "localhost", Port: port, TLSCertFile: certFile, TLSKeyFile: keyFile})
+
+		if err := s.Check(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if s.State() != StateNew {
+			t.Fatalf("expected state %v, got %v", StateNew, s.State())
+		}
+
+		l, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)))
+		if err != nil {
+			t.Fatalf("expected the port to be free again, got %v", err)
+		}
+		_ = l.Close()
+	})
+
+	t.Run("fails on invalid options", func(t *testing.T) {
+		s := New(Options{Host: "localhost", TLSCertFile: "cert.pem"})
+		if err := s.Check(context.Background()); !errors.Is(err, ErrInvalidOptions) {
+			t.Fatalf("expected ErrInvalidOptions, got %v", err)
+		}
+	})
+
+	t.Run("fails if the certificate doesn't load", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		for _, name := range []string{certFile, keyFile} {
+			if err := os.WriteFile(name, []byte("not a pem file"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s := New(Options{Host: "localhost", TLSCertFile: certFile, TLSKeyFile: keyFile})
+		if err := s.Check(context.Background()); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("fails if a port is in use", func(t *testing.T) {
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = l.Close()
+		}()
+
+		s := New(Options{Host: "localhost", Port: l.Addr().(*net.TCPAddr).Port})
+		if err := s.Check(context.Background()); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	clientIPContextKey     = contextKey("clientIP")
+	trustedProxyContextKey = contextKey("trustedProxy")
+)
+
+// ClientIP returns the IP of the client that made r. The X-Forwarded-For and X-Real-IP headers are only used
+// if the request came from one of the Server's TrustedProxies, so clients can't spoof their IP.
+// Otherwise, and outside of a Server, it's the IP from RemoteAddr. It returns nil if there's no valid IP.
+func ClientIP(r *http.Request) net.IP {
+	if ip, ok := r.Context().Value(clientIPContextKey).(net.IP); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// fromTrustedProxy reports whether r came directly from one of the Server's TrustedProxies.
+func fromTrustedProxy(r *http.Request) bool {
+	trusted, _ := r.Context().Value(trustedProxyContextKey).(bool)
+	return trusted
+}
+
+// withClientIP is middleware that resolves the client IP for ClientIP, trusting headers only from trusted proxies.
+// It also remembers whether the request came from a trusted proxy, for ExternalURL.
+func withClientIP(trusted []*net.IPNet, hops int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), clientIPContextKey, resolveClientIP(r, trusted, hops))
+			ip := remoteIP(r)
+			ctx = context.WithValue(ctx, trustedProxyContextKey, ip != nil && containsIP(trusted, ip))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// resolveClientIP of r. If the request came from a trusted proxy, X-Forwarded-For is walked from the right,
+// skipping more trusted proxies, until the first address that isn't one. That's the client,
+// because everything to the left of it may be spoofed. With hops, that many addresses are skipped from the right instead,
+// for proxies whose addresses aren't known, and the one before them is the client. Without X-Forwarded-For, X-Real-IP is used.
+func resolveClientIP(r *http.Request, trusted []*net.IPNet, hops int) net.IP {
+	ip := remoteIP(r)
+	if ip == nil || !containsIP(trusted, ip) {
+		return ip
+	}
+
+	var addresses []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		addresses = append(addresses, strings.Split(header, ",")...)
+	}
+	if len(addresses) == 0 {
+		if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+			return realIP
+		}
+		return ip
+	}
+
+	if hops > 0 {
+		// With fewer addresses than hops, the chain is shorter than expected, and the leftmost is the client.
+		i := max(len(addresses)-1-hops, 0)
+		if forwarded := net.ParseIP(strings.TrimSpace(addresses[i])); forwarded != nil {
+			return forwarded
+		}
+		return ip
+	}
+
+	for i := len(addresses) - 1; i >= 0; i-- {
+		forwarded := net.ParseIP(strings.TrimSpace(addresses[i]))
+		if forwarded == nil {
+			// The proxy that added this isn't making sense, so stick with the last one we trust.
+			return ip
+		}
+		ip = forwarded
+		if !containsIP(trusted, ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+// remoteIP of r from its RemoteAddr, or nil if it's not a valid IP.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseCIDRs([]string{"192.0.2.0/24", "198.51.100.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		hops       int
+		expected   string
+	}{
+		{name: "uses the remote address of direct connections", remoteAddr: "203.0.113.1:1234", expected: "203.0.113.1"},
+		{name: "ignores headers from untrusted sources", remoteAddr: "203.0.113.1:1234", forwarded: []string{"10.1.2.3"}, realIP: "10.1.2.4", expected: "203.0.113.1"},
+		{name: "uses X-Forwarded-For from a trusted proxy", remoteAddr: "192.0.2.1:1234", forwarded: []string{"10.1.2.3"}, expected: "10.1.2.3"},
+		{name: "skips trusted proxies in a chain", remoteAddr: "192.0.2.1:1234", forwarded: []string{"10.1.2.3, 198.51.100.7"}, expected: "10.1.2.3"},
+		{name: "stops at the first untrusted address", remoteAddr: "192.0.2.1:1234", forwarded: []string{"10.9.9.9, 203.0.113.5, 198.51.100.7"}, expected: "203.0.113.5"},
+		{name: "reads multiple X-Forwarded-For headers", remoteAddr: "192.0.2.1:1234", forwarded: []string{"10.1.2.3", "198.51.100.7"}, expected: "10.1.2.3"},
+		{name: "stops at malformed addresses", remoteAddr: "192.0.2.1:1234", forwarded: []string{"10.1.2.3, nonsense, 198.51.100.7"}, expected: "198.51.100.7"},
+		{name: "uses X-Real-IP from a trusted proxy without X-Forwarded-For", remoteAddr: "192.0.2.1:1234", realIP: "10.1.2.4", expected: "10.1.2.4"},
+		{name: "uses the proxy if it forwards nothing", remoteAddr: "192.0.2.1:1234", expected: "192.0.2.1"},
+		{name: "skips a number of hops from the right", remoteAddr: "192.0.2.1:1234", forwarded: []string{"10.9.9.9, 10.1.2.3, 203.0.113.5"}, hops: 1, expected: "10.1.2.3"},
+		{name: "skips hops regardless of trusted proxies", remoteAddr: "192.0.2.1:1234", forwarded: []string{"10.9.9.9, 10.1.2.3, 203.0.113.5, 198.51.100.7"}, hops: 2, expected: "10.1.2.3"},
+		{name: "uses the leftmost address with fewer addresses than hops", remoteAddr: "192.0.2.1:1234", forwarded: []string{"10.1.2.3, 203.0.113.5"}, hops: 3, expected: "10.1.2.3"},
+		{name: "counts hops across multiple X-Forwarded-For headers", remoteAddr: "192.0.2.1:1234", forwarded: []string{"10.1.2.3", "203.0.113.5"}, hops: 1, expected: "10.1.2.3"},
+		{name: "ignores hops from untrusted sources", remoteAddr: "203.0.113.1:1234", forwarded: []string{"10.1.2.3, 203.0.113.5"}, hops: 1, expected: "203.0.113.1"},
+		{name: "stops at a malformed address after skipping hops", remoteAddr: "192.0.2.1:1234", forwarded: []string{"nonsense, 203.0.113.5"}, hops: 1, expected: "192.0.2.1"},
+		{name: "supports IPv6", remoteAddr: "[2001:db8::1]:1234", expected: "2001:db8::1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			for _, forwarded := range test.forwarded {
+				req.Header.Add("X-Forwarded-For", forwarded)
+			}
+			if test.realIP != "" {
+				req.Header.Set("X-Real-IP", test.realIP)
+			}
+
+			var ip net.IP
+			withClientIP(trusted, test.hops)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ip = ClientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			if ip.String() != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, ip)
+			}
+		})
+	}
+
+	t.Run("uses the remote address outside of a Server", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", "10.1.2.3")
+
+		if ip := ClientIP(req); ip.String() != "192.0.2.1" {
+			t.Fatalf("expected 192.0.2.1, got %v", ip)
+		}
+	})
+
+	t.Run("refuses to start with malformed trusted proxies", func(t *testing.T) {
+		s := New(Options{TrustedProxies: []string{"192.0.2.1"}})
+		if err := s.Start(t.Context()); !errors.Is(err, ErrInvalidOptions) {
+			t.Fatalf("expected ErrInvalidOptions, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("Hello, compressed world! ", 100)
+
+	t.Run("compresses responses when gzip is accepted", func(t *testing.T) {
+		h := compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		}))
+
+		res := makeCompressionRequest(h, "gzip, deflate")
+
+		if encoding := res.Header().Get("Content-Encoding"); encoding != "gzip" {
+			t.Fatalf("expected gzip content encoding, got %q", encoding)
+		}
+		if vary := res.Header().Get("Vary"); vary != "Accept-Encoding" {
+			t.Fatalf("expected Vary: Accept-Encoding, got %q", vary)
+		}
+		if contentType := res.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+			t.Fatalf("expected content type sniffed from the uncompressed body, got %q", contentType)
+		}
+		if decoded := gunzip(t, res.Body); decoded != body {
+			t.Fatalf("expected decoded body to match, got %q", decoded)
+		}
+	})
+
+	t.Run("properly closes the stream for short responses", func(t *testing.T) {
+		h := compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hi"))
+		}))
+
+		res := makeCompressionRequest(h, "gzip")
+
+		if decoded := gunzip(t, res.Body); decoded != "hi" {
+			t.Fatalf("expected decoded body hi, got %q", decoded)
+		}
+	})
+
+	t.Run("does not compress when gzip is not accepted", func(t *testing.T) {
+		h := compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(body))
+		}))
+
+		for _, acceptEncoding := range []string{"", "br", "gzip;q=0"} {
+			res := makeCompressionRequest(h, acceptEncoding)
+
+			if encoding := res.Header().Get("Content-Encoding"); encoding != "" {
+				t.Fatalf("expected no content encoding for %q, got %q", acceptEncoding, encoding)
+			}
+			if res.Body.String() != body {
+				t.Fatalf("expected plain body for %q", acceptEncoding)
+			}
+		}
+	})
+
+	t.Run("does not compress already-compressed content types", func(t *testing.T) {
+		h := compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte(body))
+		}))
+
+		res := makeCompressionRequest(h, "gzip")
+
+		if encoding := res.Header().Get("Content-Encoding"); encoding != "" {
+			t.Fatalf("expected no content encoding, got %q", encoding)
+		}
+		if res.Body.String() != body {
+			t.Fatal("expected plain body")
+		}
+	})
+}
+
+func makeCompressionRequest(h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res
+}
+
+func gunzip(t *testing.T, r io.Reader) string {
+	t.Helper()
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// operationalPaths are served even when the Server sheds load or is in maintenance mode,
+// so probes and monitoring keep working.
+var operationalPaths = map[string]bool{
+	"/healthz/live":  true,
+	"/healthz/ready": true,
+	"/metrics":       true,
+	"/version":       true,
+}
+
+// limitConcurrency is middleware that handles at most max requests at the same time.
+// Further requests are shed right away with 503 Service Unavailable and a Retry-After header instead of waiting,
+// except for exempt ones.
+func limitConcurrency(max int, exempt func(r *http.Request) bool) func(http.Handler) http.Handler {
+	sem := make(chan struct{}, max)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			select {
+			case sem <- struct{}{}:
+				defer func() {
+					<-sem
+				}()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// limitRouteConcurrency is middleware that handles at most as many requests at the same time per route
+// as given in limits, by route pattern in routes, like /reports/{id}, including the routes of a mounted chi router.
+// Requests for other routes aren't limited.
+// Like with limitConcurrency, further requests for a saturated route are shed right away with 503 Service Unavailable.
+func limitRouteConcurrency(routes chi.Routes, limits map[string]int) func(http.Handler) http.Handler {
+	sems := map[string]chan struct{}{}
+	for route, max := range limits {
+		sems[route] = make(chan struct{}, max)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sem, ok := sems[routes.Find(chi.NewRouteContext(), r.Method, r.URL.Path)]
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			select {
+			case sem <- struct{}{}:
+				defer func() {
+					<-sem
+				}()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// isOperational reports whether r is for one of the built-in operational endpoints on Port.
+func (s *Server) isOperational(r *http.Request) bool {
+	return s.adminMux == nil && !s.disableDefaultRoutes && operationalPaths[r.URL.Path]
+}
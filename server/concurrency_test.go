@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestServer_maxConcurrentRequests(t *testing.T) {
+	t.Run("sheds requests over the limit while operational endpoints still succeed", func(t *testing.T) {
+		s := New(Options{Host: "localhost", MaxConcurrentRequests: 1, MetricsEnabled: true})
+		started := make(chan struct{})
+		release := make(chan struct{})
+		s.mux.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})
+		s.mux.Get("/fast", func(w http.ResponseWriter, r *http.Request) {})
+		address := startTestServer(t, s)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			res, err := http.Get("http://" + address + "/slow")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = res.Body.Close()
+		}()
+		<-started
+
+		res, err := http.Get("http://" + address + "/fast")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v, got %v", http.StatusServiceUnavailable, res.StatusCode)
+		}
+		if retryAfter := res.Header.Get("Retry-After"); retryAfter != "1" {
+			t.Fatalf("expected Retry-After 1, got %q", retryAfter)
+		}
+
+		for _, path := range []string{"/healthz/live", "/healthz/ready", "/metrics", "/version"} {
+			if code := getStatus(t, "http://"+address+path); code != http.StatusOK {
+				t.Fatalf("expected status %v for %v, got %v", http.StatusOK, path, code)
+			}
+		}
+
+		close(release)
+		<-done
+		if code := getStatus(t, "http://"+address+"/fast"); code != http.StatusOK {
+			t.Fatalf("expected status %v after the slow request, got %v", http.StatusOK, code)
+		}
+	})
+
+	t.Run("does not limit the admin port", func(t *testing.T) {
+		s := New(adminOptions(t, Options{Host: "localhost", MaxConcurrentRequests: 1}))
+		started := make(chan struct{})
+		release := make(chan struct{})
+		s.mux.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})
+		address := startTestServer(t, s)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			res, err := http.Get("http://" + address + "/slow")
+			if err == nil {
+				_ = res.Body.Close()
+			}
+		}()
+		<-started
+		defer func() {
+			close(release)
+			<-done
+		}()
+
+		if code := getStatus(t, adminURL(s, "/admin/loglevel")); code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, code)
+		}
+	})
+}
+
+func TestServer_routeConcurrencyLimits(t *testing.T) {
+	t.Run("sheds requests for a saturated route while other routes still succeed", func(t *testing.T) {
+		s := New(Options{Host: "localhost", RouteConcurrencyLimits: map[string]int{"/slow/{id}": 1}})
+		started := make(chan struct{})
+		release := make(chan struct{})
+		s.mux.Get("/slow/{id}", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})
+		s.mux.Get("/fast", func(w http.ResponseWriter, r *http.Request) {})
+		address := startTestServer(t, s)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			res, err := http.Get("http://" + address + "/slow/1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = res.Body.Close()
+		}()
+		<-started
+
+		if code := getStatus(t, "http://"+address+"/slow/2"); code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v for the saturated route, got %v", http.StatusServiceUnavailable, code)
+		}
+		for _, path := range []string{"/fast", "/healthz/live"} {
+			if code := getStatus(t, "http://"+address+path); code != http.StatusOK {
+				t.Fatalf("expected status %v for %v, got %v", http.StatusOK, path, code)
+			}
+		}
+
+		close(release)
+		<-done
+	})
+
+	t.Run("limits routes of a chi router given as the handler", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		r := chi.NewRouter()
+		r.Get("/slow/{id}", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})
+		r.Get("/fast", func(w http.ResponseWriter, r *http.Request) {})
+		s := New(Options{Host: "localhost", Handler: r, RouteConcurrencyLimits: map[string]int{"/slow/{id}": 1}})
+		address := startTestServer(t, s)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			res, err := http.Get("http://" + address + "/slow/1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = res.Body.Close()
+		}()
+		<-started
+
+		if code := getStatus(t, "http://"+address+"/slow/2"); code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v for the saturated route, got %v", http.StatusServiceUnavailable, code)
+		}
+		if code := getStatus(t, "http://"+address+"/fast"); code != http.StatusOK {
+			t.Fatalf("expected status %v for another route, got %v", http.StatusOK, code)
+		}
+
+		close(release)
+		<-done
+	})
+
+	t.Run("rejects limits below one", func(t *testing.T) {
+		s := New(Options{RouteConcurrencyLimits: map[string]int{"/slow": 0}})
+		if err := s.Start(context.Background()); !errors.Is(err, ErrInvalidOptions) {
+			t.Fatalf("expected ErrInvalidOptions, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"reflect"
+	"time"
+)
+
+// redactedOptions are the Options left out of the effective configuration, because they're secrets.
+var redactedOptions = map[string]bool{
+	"AdminPassword": true,
+	"TLSKeyFile":    true,
+}
+
+const redacted = "[redacted]"
+
+// effectiveConfig of the Server from opts, with defaults applied, for reporting at /admin/config.
+// Only plain values like strings, numbers, durations, and lists of them are included, keyed by option name.
+// Secrets are replaced with a placeholder if set.
+func effectiveConfig(opts Options) map[string]any {
+	return configValues(reflect.ValueOf(opts))
+}
+
+func configValues(v reflect.Value) map[string]any {
+	config := map[string]any{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if redactedOptions[field.Name] {
+			if v.Field(i).IsZero() {
+				config[field.Name] = ""
+			} else {
+				config[field.Name] = redacted
+			}
+			continue
+		}
+
+		if value, ok := configValue(v.Field(i)); ok {
+			config[field.Name] = value
+		}
+	}
+	return config
+}
+
+// configValue of v, and whether it's one that can be reported.
+func configValue(v reflect.Value) (any, bool) {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String(), true
+	}
+
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64, reflect.String:
+		return v.Interface(), true
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil, false
+		}
+		return v.Interface(), true
+	case reflect.Struct:
+		if config := configValues(v); len(config) > 0 {
+			return config, true
+		}
+	}
+	return nil, false
+}
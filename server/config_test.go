@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestServer_config(t *testing.T) {
+	t.Run("serves the effective configuration with secrets redacted", func(t *testing.T) {
+		s, _ := startServer(t, adminOptions(t, Options{
+			CORSAllowedOrigins: []string{"https://example.com"},
+			MetricsEnabled:     true,
+			SecurityHeaders:    SecurityHeaders{ReferrerPolicy: "no-referrer"},
+		}))
+
+		res, err := http.Get(adminURL(s, "/admin/config"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = res.Body.Close()
+		}()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.StatusCode)
+		}
+
+		var config map[string]any
+		if err := json.NewDecoder(res.Body).Decode(&config); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string]any{
+			"AdminPassword":  redacted,
+			"AdminUser":      testAdminUser,
+			"Host":           "localhost",
+			"MetricsEnabled": true,
+			"ReadTimeout":    "5s",
+			"TLSKeyFile":     "",
+		}
+		for name, value := range expected {
+			if config[name] != value {
+				t.Fatalf("expected %v to be %v, got %v", name, value, config[name])
+			}
+		}
+		if origins, ok := config["CORSAllowedOrigins"].([]any); !ok || len(origins) != 1 || origins[0] != "https://example.com" {
+			t.Fatalf("expected the allowed origins, got %v", config["CORSAllowedOrigins"])
+		}
+		if headers, ok := config["SecurityHeaders"].(map[string]any); !ok || headers["ReferrerPolicy"] != "no-referrer" {
+			t.Fatalf("expected the security headers, got %v", config["SecurityHeaders"])
+		}
+		for _, name := range []string{"Log", "Handler", "ErrorReporter", "LogLevel", "Middlewares"} {
+			if _, ok := config[name]; ok {
+				t.Fatalf("expected %v not to be included", name)
+			}
+		}
+	})
+
+	t.Run("redacts set secrets", func(t *testing.T) {
+		config := effectiveConfig(Options{TLSKeyFile: "/etc/tls/key.pem", TLSCertFile: "/etc/tls/cert.pem"})
+
+		if config["TLSKeyFile"] != redacted {
+			t.Fatalf("expected the key file to be redacted, got %v", config["TLSKeyFile"])
+		}
+		if config["TLSCertFile"] != "/etc/tls/cert.pem" {
+			t.Fatalf("expected the cert file, got %v", config["TLSCertFile"])
+		}
+	})
+
+	t.Run("is not served on the main port", func(t *testing.T) {
+		_, address := startServer(t, adminOptions(t, Options{}))
+
+		if code := getStatus(t, "http://"+address+"/admin/config"); code != http.StatusNotFound {
+			t.Fatalf("expected status %v, got %v", http.StatusNotFound, code)
+		}
+	})
+}
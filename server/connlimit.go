@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/netutil"
+)
+
+// limitConnections wraps l so that at most max connections are open at the same time.
+// Further connections wait to be accepted until an open one closes. Reaching the limit is logged.
+func limitConnections(l net.Listener, max int, log func() *zap.Logger) net.Listener {
+	return netutil.LimitListener(&countingListener{Listener: l, log: log, max: int64(max)}, max)
+}
+
+// countingListener keeps track of its open connections, to log when there are max of them.
+type countingListener struct {
+	net.Listener
+	log  func() *zap.Logger
+	max  int64
+	open atomic.Int64
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if l.open.Add(1) == l.max {
+		l.log().Warn("Connection limit reached, waiting for connections to close", zap.Int64("max", l.max))
+	}
+	return &countedConn{Conn: c, open: &l.open}, nil
+}
+
+// countedConn decrements its listener's count of open connections once when closed.
+type countedConn struct {
+	net.Conn
+	closeOnce sync.Once
+	open      *atomic.Int64
+}
+
+func (c *countedConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.open.Add(-1)
+	})
+	return c.Conn.Close()
+}
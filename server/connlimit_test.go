@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestServer_maxConnections(t *testing.T) {
+	t.Run("makes connections over the limit wait until one is closed", func(t *testing.T) {
+		core, logs := observer.New(zapcore.InfoLevel)
+		_, address := startServer(t, Options{Log: zap.New(core), MaxConnections: 1})
+
+		c, err := net.Dial("tcp", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = c.Close()
+		}()
+
+		codes := make(chan int, 1)
+		go func() {
+			client := &http.Client{Transport: &http.Transport{}}
+			res, err := client.Get("http://" + address + "/healthz/live")
+			if err != nil {
+				t.Error(err)
+				codes <- 0
+				return
+			}
+			_ = res.Body.Close()
+			codes <- res.StatusCode
+		}()
+
+		select {
+		case code := <-codes:
+			t.Fatalf("expected request over the limit to wait, got status %v", code)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		if logs.FilterMessage("Connection limit reached, waiting for connections to close").Len() != 1 {
+			t.Fatal("expected the limit to be logged")
+		}
+
+		_ = c.Close()
+		select {
+		case code := <-codes:
+			if code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v", http.StatusOK, code)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("request did not complete after a connection was freed")
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// connStates keeps track of the state of open connections, through http.Server.ConnState.
+type connStates struct {
+	lock   sync.Mutex
+	states map[net.Conn]http.ConnState
+}
+
+func newConnStates() *connStates {
+	return &connStates{states: map[net.Conn]http.ConnState{}}
+}
+
+// track is an http.Server.ConnState callback.
+func (c *connStates) track(conn net.Conn, state http.ConnState) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(c.states, conn)
+	default:
+		c.states[conn] = state
+	}
+}
+
+// count the open connections that are idle between requests, and the ones that are new or handling one.
+func (c *connStates) count() (idle, active int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, state := range c.states {
+		if state == http.StateIdle {
+			idle++
+		} else {
+			active++
+		}
+	}
+	return idle, active
+}
+
+// list the open connections.
+func (c *connStates) list() []net.Conn {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return slices.Collect(maps.Keys(c.states))
+}
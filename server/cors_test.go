@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	t.Run("echoes an allowed origin", func(t *testing.T) {
+		h := cors([]string{"https://example.com", "https://app.example.com"})(next)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusTeapot {
+			t.Fatalf("expected status %v, got %v", http.StatusTeapot, res.Code)
+		}
+		if origin := res.Header().Get("Access-Control-Allow-Origin"); origin != "https://app.example.com" {
+			t.Fatalf("expected allowed origin to be echoed, got %q", origin)
+		}
+	})
+
+	t.Run("does not allow a disallowed origin", func(t *testing.T) {
+		h := cors([]string{"https://example.com"})(next)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusTeapot {
+			t.Fatalf("expected status %v, got %v", http.StatusTeapot, res.Code)
+		}
+		if origin := res.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+			t.Fatalf("expected no allowed origin, got %q", origin)
+		}
+	})
+
+	t.Run("allows all origins with a wildcard", func(t *testing.T) {
+		h := cors([]string{"*"})(next)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", "https://anything.example.com")
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+
+		if origin := res.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+			t.Fatalf("expected wildcard origin, got %q", origin)
+		}
+	})
+
+	t.Run("answers preflight requests from allowed origins", func(t *testing.T) {
+		h := cors([]string{"https://example.com"})(next)
+
+		req := httptest.NewRequest(http.MethodOptions, "/", nil)
+		req.Header.Set("Origin", "https://example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusNoContent {
+			t.Fatalf("expected status %v, got %v", http.StatusNoContent, res.Code)
+		}
+		if methods := res.Header().Get("Access-Control-Allow-Methods"); methods == "" {
+			t.Fatal("expected allowed methods")
+		}
+		if headers := res.Header().Get("Access-Control-Allow-Headers"); headers != "Content-Type" {
+			t.Fatalf("expected allowed headers Content-Type, got %q", headers)
+		}
+	})
+
+	t.Run("passes on preflight requests from disallowed origins", func(t *testing.T) {
+		h := cors([]string{"https://example.com"})(next)
+
+		req := httptest.NewRequest(http.MethodOptions, "/", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusTeapot {
+			t.Fatalf("expected status %v, got %v", http.StatusTeapot, res.Code)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedMediaType is returned by DecodeJSON for requests without a JSON Content-Type.
+	ErrUnsupportedMediaType = errors.New("content type must be application/json")
+	// ErrMalformedJSON is returned by DecodeJSON for request bodies that aren't a single valid JSON value.
+	ErrMalformedJSON = errors.New("malformed JSON")
+	// ErrUnknownField is returned by DecodeJSON for request bodies with fields the destination doesn't have.
+	ErrUnknownField = errors.New("unknown field")
+	// ErrBodyTooLarge is returned by DecodeJSON for request bodies over the limit.
+	ErrBodyTooLarge = errors.New("request body too large")
+)
+
+// DecodeError is returned by DecodeJSON, with the status to respond with, which WriteError uses for it:
+//
+//	if err := server.DecodeJSON(r, &body); err != nil {
+//		server.WriteError(w, r, http.StatusBadRequest, err)
+//		return
+//	}
+type DecodeError struct {
+	// Status is 415 Unsupported Media Type, 400 Bad Request, or 413 Request Entity Too Large.
+	Status int
+	err    error
+}
+
+func (e *DecodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.err
+}
+
+// DecodeJSON from the body of r into dst, strictly: the Content-Type must be JSON, the body must be a single JSON value,
+// and it must not have fields dst doesn't have. Within a Server, the body is limited to the MaxRequestBodyBytes,
+// and to 1 MB otherwise. Errors are a *DecodeError wrapping ErrUnsupportedMediaType, ErrMalformedJSON, ErrUnknownField,
+// or ErrBodyTooLarge.
+func DecodeJSON(r *http.Request, dst any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return &DecodeError{Status: http.StatusUnsupportedMediaType, err: ErrUnsupportedMediaType}
+	}
+
+	body := r.Body
+	if _, ok := body.(*limitedBody); !ok {
+		body = &limitedBody{ReadCloser: r.Body, contentLength: r.ContentLength, limit: defaultMaxRequestBodyBytes}
+	}
+
+	d := json.NewDecoder(body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := d.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		if err == nil {
+			return &DecodeError{Status: http.StatusBadRequest, err: fmt.Errorf("%w: more than one value", ErrMalformedJSON)}
+		}
+		return decodeError(err)
+	}
+	return nil
+}
+
+// decodeError for an error from decoding JSON.
+func decodeError(err error) *DecodeError {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &DecodeError{Status: http.StatusRequestEntityTooLarge, err: fmt.Errorf("%w: limit is %v bytes", ErrBodyTooLarge, maxBytesErr.Limit)}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// The JSON package has no error type for unknown fields.
+		return &DecodeError{Status: http.StatusBadRequest, err: fmt.Errorf("%w %v", ErrUnknownField, strings.TrimPrefix(err.Error(), "json: unknown field "))}
+	default:
+		return &DecodeError{Status: http.StatusBadRequest, err: fmt.Errorf("%w: %w", ErrMalformedJSON, err)}
+	}
+}
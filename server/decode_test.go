@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	type thing struct {
+		Name string `json:"name"`
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    error
+		status      int
+	}{
+		{name: "rejects missing content types", body: `{"name":"canvas"}`, expected: ErrUnsupportedMediaType, status: http.StatusUnsupportedMediaType},
+		{name: "rejects other content types", contentType: "text/plain", body: `{"name":"canvas"}`, expected: ErrUnsupportedMediaType, status: http.StatusUnsupportedMediaType},
+		{name: "rejects malformed JSON", contentType: "application/json", body: `{"name":`, expected: ErrMalformedJSON, status: http.StatusBadRequest},
+		{name: "rejects empty bodies", contentType: "application/json", expected: ErrMalformedJSON, status: http.StatusBadRequest},
+		{name: "rejects wrong types", contentType: "application/json", body: `{"name":1}`, expected: ErrMalformedJSON, status: http.StatusBadRequest},
+		{name: "rejects more than one value", contentType: "application/json", body: `{"name":"canvas"}{}`, expected: ErrMalformedJSON, status: http.StatusBadRequest},
+		{name: "rejects unknown fields", contentType: "application/json", body: `{"name":"canvas","color":"red"}`, expected: ErrUnknownField, status: http.StatusBadRequest},
+		{name: "rejects bodies over the limit", contentType: "application/json", body: `{"name":"` + strings.Repeat("a", defaultMaxRequestBodyBytes) + `"}`, expected: ErrBodyTooLarge, status: http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+
+			var dst thing
+			err := DecodeJSON(req, &dst)
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, err)
+			}
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) || decodeErr.Status != test.status {
+				t.Fatalf("expected a DecodeError with status %v, got %v", test.status, err)
+			}
+		})
+	}
+
+	t.Run("decodes valid bodies", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"canvas"}`))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+		var dst thing
+		if err := DecodeJSON(req, &dst); err != nil {
+			t.Fatal(err)
+		}
+		if dst.Name != "canvas" {
+			t.Fatalf("expected name canvas, got %v", dst.Name)
+		}
+	})
+
+	t.Run("uses the body limit of the Server", func(t *testing.T) {
+		s := New(Options{MaxRequestBodyBytes: 10})
+		s.mux.Post("/things", func(w http.ResponseWriter, r *http.Request) {
+			var decodeErr *DecodeError
+			if err := DecodeJSON(r, &thing{}); errors.As(err, &decodeErr) {
+				WriteError(w, r, decodeErr.Status, err)
+			}
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(`{"name":"canvas"}`))
+		req.Header.Set("Content-Type", "application/json")
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, req)
+
+		if res.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("expected status %v, got %v", http.StatusRequestEntityTooLarge, res.Code)
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// statusClientClosedRequest is the non-standard status, borrowed from nginx, for requests the client gave up on.
+const statusClientClosedRequest = 499
+
+// clientClosed reports whether the client of r disconnected or cancelled the request.
+func clientClosed(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.Canceled)
+}
+
+// logClientDisconnects is middleware that logs requests the client disconnected from before the handler returned.
+// That's expected to happen now and then, so it's logged at debug level, not as an error.
+// Handlers can observe the disconnect through the request context being done.
+func logClientDisconnects(log func() *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+
+			if clientClosed(r) {
+				log().Debug("Client closed request",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.String("request_id", RequestIDFromContext(r.Context())))
+			}
+		})
+	}
+}
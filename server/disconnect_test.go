@@ -0,0 +1,50 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogClientDisconnects(t *testing.T) {
+	t.Run("logs a cancelled request at debug level", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		log := zap.New(core)
+		h := logClientDisconnects(func() *zap.Logger { return log })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest(http.MethodGet, "/things", nil).WithContext(ctx)
+		go cancel()
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		entries := logs.FilterMessage("Client closed request").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 log entry, got %v", len(entries))
+		}
+		if entries[0].Level != zapcore.DebugLevel {
+			t.Fatalf("expected debug level, got %v", entries[0].Level)
+		}
+		if path := entries[0].ContextMap()["path"]; path != "/things" {
+			t.Fatalf("expected path /things, got %v", path)
+		}
+	})
+
+	t.Run("does not log completed requests", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		log := zap.New(core)
+		h := logClientDisconnects(func() *zap.Logger { return log })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if logs.Len() != 0 {
+			t.Fatalf("expected no log entries, got %v", logs.Len())
+		}
+	})
+}
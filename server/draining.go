@@ -0,0 +1,28 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+const drainingMessage = "The server is shutting down, please try again."
+
+// rejectWhileDraining is middleware that responds to new requests with 503 Service Unavailable
+// and closes their connection while draining is set, except for exempt requests.
+// Requests already being handled when draining starts aren't affected.
+// Unlike the readiness probe, which tells load balancers to stop sending requests,
+// this turns away the ones that still arrive, so clients can retry elsewhere right away.
+func rejectWhileDraining(draining *atomic.Bool, exempt func(r *http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !draining.Load() || exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", "1")
+			writeErrorMessage(w, r, http.StatusServiceUnavailable, drainingMessage)
+		})
+	}
+}
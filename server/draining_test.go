@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServer_rejectWhileDraining(t *testing.T) {
+	t.Run("rejects new requests once stopping while finishing the ones in flight", func(t *testing.T) {
+		s := New(Options{Host: "localhost"})
+		started := make(chan struct{})
+		release := make(chan struct{})
+		s.mux.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusOK)
+		})
+		address := startTestServer(t, s)
+
+		slow := make(chan int, 1)
+		go func() {
+			res, err := http.Get("http://" + address + "/slow")
+			if err != nil {
+				slow <- 0
+				return
+			}
+			_ = res.Body.Close()
+			slow <- res.StatusCode
+		}()
+		<-started
+
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- s.Stop(context.Background())
+		}()
+		for !s.draining.Load() {
+			time.Sleep(time.Millisecond)
+		}
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		if res.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v while draining, got %v", http.StatusServiceUnavailable, res.Code)
+		}
+		if connection := res.Header().Get("Connection"); connection != "close" {
+			t.Fatalf("expected Connection: close, got %v", connection)
+		}
+
+		res = httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz/live", nil))
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v for the health check, got %v", http.StatusOK, res.Code)
+		}
+
+		close(release)
+		if code := <-slow; code != http.StatusOK {
+			t.Fatalf("expected the slow request to complete with %v, got %v", http.StatusOK, code)
+		}
+		if err := <-stopped; err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("keeps serving during the pre-stop delay", func(t *testing.T) {
+		s := New(Options{Host: "localhost", PreStopDelay: 100 * time.Millisecond})
+		s.mux.Get("/things", func(w http.ResponseWriter, r *http.Request) {})
+		startTestServer(t, s)
+
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- s.Stop(context.Background())
+		}()
+		for s.State() != StateDraining {
+			time.Sleep(time.Millisecond)
+		}
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/things", nil))
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v during the delay, got %v", http.StatusOK, res.Code)
+		}
+		if err := <-stopped; err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const errorWritingContextKey = contextKey("errorWriting")
+
+// errorWriting is what WriteError needs from the Server handling the request.
+type errorWriting struct {
+	details bool
+	log     func() *zap.Logger
+}
+
+// WriteError responds with status and err, as JSON like {"error":"..."} if the client accepts it,
+// and as plain text otherwise. The error is logged, at error level for server errors, which are also recorded on the span
+// of the request, if it's traced, and reported with ReportError.
+// For server errors, the client only gets the status text, unless the Server shows error details.
+// A ValidationError is written with its field errors and status 422 Unprocessable Entity instead,
+// and a *DecodeError with its Status.
+// A 500 Internal Server Error wrapping context.DeadlineExceeded, like from a downstream call with the request context,
+// is written with status 504 Gateway Timeout instead. Other server errors keep their status.
+// Without an error, the status text is written.
+func WriteError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if err == nil {
+		err = errors.New(http.StatusText(status))
+	}
+	ew, ok := r.Context().Value(errorWritingContextKey).(errorWriting)
+	if !ok {
+		ew = errorWriting{log: zap.NewNop}
+	}
+
+	var validationErr ValidationError
+	if errors.As(err, &validationErr) {
+		status = http.StatusUnprocessableEntity
+	}
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		status = decodeErr.Status
+	}
+	if status == http.StatusInternalServerError && errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+
+	message := err.Error()
+	fields := []zap.Field{
+		zap.Int("status", status),
+		zap.String("path", r.URL.Path),
+		zap.String("request_id", RequestIDFromContext(r.Context())),
+		zap.Error(err),
+	}
+	if status >= http.StatusInternalServerError {
+		ew.log().Error("Error handling request", fields...)
+		trace.SpanFromContext(r.Context()).RecordError(err)
+		ReportError(r.Context(), err)
+		if !ew.details {
+			message = http.StatusText(status)
+		}
+	} else {
+		ew.log().Info("Error handling request", fields...)
+	}
+
+	if validationErr != nil {
+		writeValidationError(w, r, validationErr)
+		return
+	}
+	writeErrorMessage(w, r, status, message)
+}
+
+// writeErrorMessage responds with status and message, as JSON if the client accepts it, and as plain text otherwise.
+func writeErrorMessage(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if acceptsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(struct {
+			Error string `json:"error"`
+		}{Error: message})
+		return
+	}
+	http.Error(w, message, status)
+}
+
+// acceptsJSON reports whether the Accept header of r lists a JSON media type.
+func acceptsJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+			return true
+		}
+	}
+	return false
+}
+
+// withErrorWriting is middleware that makes the logger and whether to show error details available to WriteError.
+func withErrorWriting(log func() *zap.Logger, details bool) func(http.Handler) http.Handler {
+	ew := errorWriting{details: details, log: log}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), errorWritingContextKey, ew)))
+		})
+	}
+}
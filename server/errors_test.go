@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		status      int
+		details     bool
+		contentType string
+		body        string
+	}{
+		{name: "writes JSON if accepted", accept: "application/json", status: http.StatusBadRequest,
+			contentType: "application/json", body: `{"error":"invalid thing"}`},
+		{name: "writes JSON for JSON media types among others", accept: "text/html, application/problem+json;q=0.9", status: http.StatusBadRequest,
+			contentType: "application/json", body: `{"error":"invalid thing"}`},
+		{name: "writes plain text otherwise", accept: "text/html", status: http.StatusBadRequest,
+			contentType: "text/plain; charset=utf-8", body: "invalid thing"},
+		{name: "writes plain text without an Accept header", status: http.StatusBadRequest,
+			contentType: "text/plain; charset=utf-8", body: "invalid thing"},
+		{name: "hides details of server errors", accept: "application/json", status: http.StatusInternalServerError,
+			contentType: "application/json", body: `{"error":"Internal Server Error"}`},
+		{name: "shows details of server errors if enabled", accept: "application/json", status: http.StatusInternalServerError, details: true,
+			contentType: "application/json", body: `{"error":"invalid thing"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := zap.NewNop()
+			h := withErrorWriting(func() *zap.Logger { return log }, test.details)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				WriteError(w, r, test.status, errors.New("invalid thing"))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, req)
+
+			if res.Code != test.status {
+				t.Fatalf("expected status %v, got %v", test.status, res.Code)
+			}
+			if contentType := res.Header().Get("Content-Type"); contentType != test.contentType {
+				t.Fatalf("expected content type %v, got %v", test.contentType, contentType)
+			}
+			if body := strings.TrimSpace(res.Body.String()); body != test.body {
+				t.Fatalf("expected body %v, got %v", test.body, body)
+			}
+		})
+	}
+
+	t.Run("writes downstream deadlines as gateway timeouts", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			err      error
+			expected int
+		}{
+			{name: "deadline exceeded", err: fmt.Errorf("error calling upstream: %w", context.DeadlineExceeded), expected: http.StatusGatewayTimeout},
+			{name: "other error", err: errors.New("oh no"), expected: http.StatusInternalServerError},
+		}
+		for _, test := range tests {
+			h := withErrorWriting(zap.NewNop, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				WriteError(w, r, http.StatusInternalServerError, test.err)
+			}))
+
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if res.Code != test.expected {
+				t.Fatalf("expected status %v for %v, got %v", test.expected, test.name, res.Code)
+			}
+			if body := strings.TrimSpace(res.Body.String()); body != http.StatusText(test.expected) {
+				t.Fatalf("expected body %v for %v, got %v", http.StatusText(test.expected), test.name, body)
+			}
+		}
+	})
+
+	t.Run("writes decode errors with their status", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+		req.Header.Set("Content-Type", "text/plain")
+		res := httptest.NewRecorder()
+
+		err := DecodeJSON(req, &struct{}{})
+		WriteError(res, req, http.StatusBadRequest, fmt.Errorf("error decoding thing: %w", err))
+
+		if res.Code != http.StatusUnsupportedMediaType {
+			t.Fatalf("expected status %v, got %v", http.StatusUnsupportedMediaType, res.Code)
+		}
+	})
+
+	t.Run("logs server errors at error level and others at info level", func(t *testing.T) {
+		core, logs := observer.New(zapcore.InfoLevel)
+		log := zap.New(core)
+		h := withErrorWriting(func() *zap.Logger { return log }, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, http.StatusNotFound, errors.New("no thing"))
+			WriteError(httptest.NewRecorder(), r, http.StatusBadGateway, errors.New("upstream down"))
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		entries := logs.All()
+		if len(entries) != 2 {
+			t.Fatalf("expected 2 log entries, got %v", len(entries))
+		}
+		if entries[0].Level != zapcore.InfoLevel || entries[1].Level != zapcore.ErrorLevel {
+			t.Fatalf("expected info and error levels, got %v and %v", entries[0].Level, entries[1].Level)
+		}
+		if err := entries[1].ContextMap()["error"]; err != "upstream down" {
+			t.Fatalf("expected the full error in the log, got %v", err)
+		}
+	})
+
+	t.Run("writes the status text without an error", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", "application/json")
+		WriteError(res, req, http.StatusConflict, nil)
+
+		if res.Code != http.StatusConflict {
+			t.Fatalf("expected status %v, got %v", http.StatusConflict, res.Code)
+		}
+		if body := strings.TrimSpace(res.Body.String()); body != `{"error":"Conflict"}` {
+			t.Fatalf("expected the status text, got %v", body)
+		}
+	})
+
+	t.Run("hides details outside of a Server", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		WriteError(res, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusInternalServerError, errors.New("secret"))
+
+		if strings.Contains(res.Body.String(), "secret") {
+			t.Fatalf("expected details to be hidden, got %v", res.Body.String())
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// ETag is middleware that buffers successful GET and HEAD responses, sets a weak ETag computed from the body,
+// and responds with 304 Not Modified without a body if the request's If-None-Match header matches it.
+// An ETag set by the handler is used as-is. Because of the buffering, only use it on routes with small responses:
+//
+//	mux.With(server.ETag).Get("/config", config)
+func ETag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bw := NewBufferedResponseWriter(w)
+		next.ServeHTTP(bw, r)
+
+		if bw.Status != 0 && bw.Status != http.StatusOK {
+			_ = bw.Send()
+			return
+		}
+
+		etag := bw.Header().Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(bw.Body.Bytes())
+			etag = `W/"` + hex.EncodeToString(sum[:16]) + `"`
+			bw.Header().Set("ETag", etag)
+		}
+
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			h := bw.Header()
+			h.Del("Content-Type")
+			h.Del("Content-Length")
+			bw.Status = http.StatusNotModified
+			bw.Body.Reset()
+		}
+		_ = bw.Send()
+	})
+}
+
+// etagMatches reports whether the If-None-Match header value matches etag, using the weak comparison.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
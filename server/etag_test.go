@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	h := ETag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("Hello"))
+	}))
+
+	t.Run("sets an ETag and responds with 304 when it matches", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if res.Code != http.StatusOK || res.Body.String() != "Hello" {
+			t.Fatalf("expected status %v with body, got %v and %q", http.StatusOK, res.Code, res.Body.String())
+		}
+		etag := res.Header().Get("ETag")
+		if !strings.HasPrefix(etag, `W/"`) {
+			t.Fatalf("expected a weak ETag, got %q", etag)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("If-None-Match", etag)
+		res = httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusNotModified {
+			t.Fatalf("expected status %v, got %v", http.StatusNotModified, res.Code)
+		}
+		if res.Body.Len() != 0 {
+			t.Fatalf("expected no body, got %q", res.Body.String())
+		}
+		if res.Header().Get("ETag") != etag {
+			t.Fatalf("expected ETag %v, got %v", etag, res.Header().Get("ETag"))
+		}
+	})
+
+	t.Run("responds normally when the ETag does not match", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("If-None-Match", `W/"other", "another"`)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK || res.Body.String() != "Hello" {
+			t.Fatalf("expected status %v with body, got %v and %q", http.StatusOK, res.Code, res.Body.String())
+		}
+	})
+
+	t.Run("uses an ETag set by the handler", func(t *testing.T) {
+		h := ETag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = w.Write([]byte("Hello"))
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("If-None-Match", `W/"v1"`)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+
+		if res.Code != http.StatusNotModified {
+			t.Fatalf("expected status %v, got %v", http.StatusNotModified, res.Code)
+		}
+	})
+
+	t.Run("leaves unsuccessful responses and other methods alone", func(t *testing.T) {
+		h := ETag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+		if res.Code != http.StatusNotFound || res.Header().Get("ETag") != "" {
+			t.Fatalf("expected status %v without ETag, got %v", http.StatusNotFound, res.Code)
+		}
+
+		res = httptest.NewRecorder()
+		ETag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/", nil))
+		if res.Header().Get("ETag") != "" {
+			t.Fatal("expected no ETag for POST")
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ExternalURL returns the base URL the client used to reach the Server, with only the scheme and host set,
+// for building absolute URLs like in Location headers:
+//
+//	u := server.ExternalURL(r)
+//	u.Path = "/things/" + id
+//	w.Header().Set("Location", u.String())
+//
+// For requests from one of the Server's TrustedProxies, the X-Forwarded-Proto, X-Forwarded-Host,
+// and X-Forwarded-Port headers are used. Otherwise, and outside of a Server, it's the Host header
+// and https for TLS connections or http.
+func ExternalURL(r *http.Request) *url.URL {
+	u := &url.URL{Scheme: "http", Host: r.Host}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if !fromTrustedProxy(r) {
+		return u
+	}
+
+	if proto := strings.ToLower(firstForwarded(r, "X-Forwarded-Proto")); proto == "http" || proto == "https" {
+		u.Scheme = proto
+	}
+	if host := firstForwarded(r, "X-Forwarded-Host"); host != "" {
+		u.Host = host
+	}
+	if port := firstForwarded(r, "X-Forwarded-Port"); port != "" {
+		hostname := u.Hostname()
+		if strings.Contains(hostname, ":") {
+			hostname = "[" + hostname + "]"
+		}
+		if isDefaultPort(u.Scheme, port) {
+			u.Host = hostname
+		} else {
+			u.Host = net.JoinHostPort(u.Hostname(), port)
+		}
+	}
+	return u
+}
+
+// firstForwarded value of the header named name. With several proxies, the first one saw what the client sent.
+func firstForwarded(r *http.Request, name string) string {
+	value, _, _ := strings.Cut(r.Header.Get(name), ",")
+	return strings.TrimSpace(value)
+}
+
+func isDefaultPort(scheme, port string) bool {
+	return (scheme == "http" && port == "80") || (scheme == "https" && port == "443")
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestExternalURL(t *testing.T) {
+	trusted, err := parseCIDRs([]string{"192.0.2.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		host       string
+		tls        bool
+		headers    map[string]string
+		expected   string
+	}{
+		{name: "uses the host of direct requests", remoteAddr: "203.0.113.1:1234", host: "example.com:8080",
+			expected: "http://example.com:8080"},
+		{name: "uses https for direct TLS requests", remoteAddr: "203.0.113.1:1234", host: "example.com", tls: true,
+			expected: "https://example.com"},
+		{name: "ignores forwarded headers from untrusted sources", remoteAddr: "203.0.113.1:1234", host: "internal:8080",
+			headers: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "example.com"}, expected: "http://internal:8080"},
+		{name: "uses forwarded proto and host from a trusted proxy", remoteAddr: "192.0.2.1:1234", host: "internal:8080",
+			headers: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "example.com"}, expected: "https://example.com"},
+		{name: "uses the first forwarded values from a chain of proxies", remoteAddr: "192.0.2.1:1234", host: "internal:8080",
+			headers:  map[string]string{"X-Forwarded-Proto": "https, http", "X-Forwarded-Host": "example.com, lb.internal"},
+			expected: "https://example.com"},
+		{name: "uses a forwarded port", remoteAddr: "192.0.2.1:1234", host: "internal:8080",
+			headers:  map[string]string{"X-Forwarded-Host": "example.com", "X-Forwarded-Port": "8443", "X-Forwarded-Proto": "https"},
+			expected: "https://example.com:8443"},
+		{name: "leaves out default forwarded ports", remoteAddr: "192.0.2.1:1234", host: "example.com:8080",
+			headers: map[string]string{"X-Forwarded-Port": "443", "X-Forwarded-Proto": "https"}, expected: "https://example.com"},
+		{name: "supports IPv6 hosts with a forwarded port", remoteAddr: "192.0.2.1:1234", host: "[2001:db8::1]:8080",
+			headers: map[string]string{"X-Forwarded-Port": "80"}, expected: "http://[2001:db8::1]"},
+		{name: "ignores unknown forwarded protocols", remoteAddr: "192.0.2.1:1234", host: "example.com",
+			headers: map[string]string{"X-Forwarded-Proto": "gopher"}, expected: "http://example.com"},
+		{name: "uses the host from a trusted proxy without forwarded headers", remoteAddr: "192.0.2.1:1234", host: "example.com",
+			expected: "http://example.com"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/things", nil)
+			req.RemoteAddr = test.remoteAddr
+			req.Host = test.host
+			req.TLS = nil
+			if test.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+
+			var u *url.URL
+			withClientIP(trusted, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				u = ExternalURL(r)
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			if u.String() != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, u)
+			}
+		})
+	}
+
+	t.Run("ignores forwarded headers outside of a Server", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://internal/things", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-Host", "example.com")
+
+		if u := ExternalURL(req); u.String() != "http://internal" {
+			t.Fatalf("expected http://internal, got %v", u)
+		}
+	})
+}
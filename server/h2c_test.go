@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestServer_h2c(t *testing.T) {
+	t.Run("serves HTTP/2 without TLS when enabled", func(t *testing.T) {
+		_, address := startServer(t, Options{H2CEnabled: true})
+
+		protocols := new(http.Protocols)
+		protocols.SetUnencryptedHTTP2(true)
+		client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+
+		res, err := client.Get("http://" + address + "/healthz/live")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.StatusCode)
+		}
+		if res.ProtoMajor != 2 {
+			t.Fatalf("expected HTTP/2, got %v", res.Proto)
+		}
+	})
+
+	t.Run("still serves HTTP/1 when enabled", func(t *testing.T) {
+		_, address := startServer(t, Options{H2CEnabled: true})
+
+		res, err := http.Get("http://" + address + "/healthz/live")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.ProtoMajor != 1 {
+			t.Fatalf("expected HTTP/1, got %v", res.Proto)
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// answerHead is middleware that routes HEAD requests to the GET handler for paths without a HEAD route.
+// Headers and status are kept as the GET handler sets them, but the body is discarded.
+func answerHead(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rctx := chi.RouteContext(r.Context())
+		if r.Method != http.MethodHead || rctx == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		path := rctx.RoutePath
+		if path == "" {
+			path = r.URL.RawPath
+		}
+		if path == "" {
+			path = r.URL.Path
+		}
+		if rctx.Routes.Match(chi.NewRouteContext(), http.MethodHead, path) ||
+			!rctx.Routes.Match(chi.NewRouteContext(), http.MethodGet, path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rctx.RouteMethod = http.MethodGet
+		rctx.RoutePath = path
+		next.ServeHTTP(&headResponseWriter{ResponseWriter: w}, r)
+	})
+}
+
+// headResponseWriter discards everything written to it, except for headers and the status.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
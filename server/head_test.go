@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_answerHead(t *testing.T) {
+	newTestServer := func() *Server {
+		s := New(Options{})
+		s.setupRoutes()
+		s.mux.Get("/thing", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Thing", "yes")
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte("hello"))
+		})
+		return s
+	}
+
+	t.Run("answers HEAD like GET, without the body", func(t *testing.T) {
+		s := newTestServer()
+
+		get := httptest.NewRecorder()
+		s.mux.ServeHTTP(get, httptest.NewRequest(http.MethodGet, "/thing", nil))
+		head := httptest.NewRecorder()
+		s.mux.ServeHTTP(head, httptest.NewRequest(http.MethodHead, "/thing", nil))
+
+		if head.Code != get.Code || head.Code != http.StatusAccepted {
+			t.Fatalf("expected status %v for both, got %v for GET and %v for HEAD", http.StatusAccepted, get.Code, head.Code)
+		}
+		for _, name := range []string{"X-Thing", "Content-Type"} {
+			if head.Header().Get(name) != get.Header().Get(name) {
+				t.Fatalf("expected header %v to be %q, got %q", name, get.Header().Get(name), head.Header().Get(name))
+			}
+		}
+		if get.Body.String() != "hello" {
+			t.Fatalf("expected GET body hello, got %q", get.Body.String())
+		}
+		if head.Body.Len() != 0 {
+			t.Fatalf("expected an empty HEAD body, got %q", head.Body.String())
+		}
+	})
+
+	t.Run("uses HEAD routes where they exist", func(t *testing.T) {
+		s := newTestServer()
+		s.mux.Head("/thing", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodHead, "/thing", nil))
+		if res.Code != http.StatusNoContent {
+			t.Fatalf("expected status %v, got %v", http.StatusNoContent, res.Code)
+		}
+	})
+
+	t.Run("responds with 404 for unknown paths", func(t *testing.T) {
+		s := newTestServer()
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodHead, "/nothing", nil))
+		if res.Code != http.StatusNotFound {
+			t.Fatalf("expected status %v, got %v", http.StatusNotFound, res.Code)
+		}
+	})
+
+	t.Run("answers HEAD on the admin port", func(t *testing.T) {
+		s, _ := startServer(t, adminOptions(t, Options{}))
+
+		res, err := http.Head(adminURL(s, "/healthz/live"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.StatusCode)
+		}
+	})
+}
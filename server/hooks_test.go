@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestServer_hooks(t *testing.T) {
+	t.Run("calls each hook once in order", func(t *testing.T) {
+		var lock sync.Mutex
+		var calls []string
+		record := func(name string) func(context.Context) error {
+			return func(context.Context) error {
+				lock.Lock()
+				defer lock.Unlock()
+				calls = append(calls, name)
+				return nil
+			}
+		}
+
+		s, address := startServer(t, Options{
+			OnShutdown: record("shutdown"),
+			OnStart:    record("start"),
+			OnStopped:  record("stopped"),
+		})
+		if code := getStatus(t, "http://"+address+"/healthz/live"); code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, code)
+		}
+		for range 2 {
+			if err := s.Stop(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		if expected := []string{"start", "shutdown", "stopped"}; !slices.Equal(calls, expected) {
+			t.Fatalf("expected calls %v, got %v", expected, calls)
+		}
+	})
+
+	t.Run("aborts the start on an error from the start hook", func(t *testing.T) {
+		hookErr := errors.New("no registration for you")
+		s := New(Options{Host: "localhost", OnStart: func(context.Context) error { return hookErr }})
+
+		if err := s.Start(context.Background()); !errors.Is(err, hookErr) {
+			t.Fatalf("expected the hook error, got %v", err)
+		}
+		if state := s.State(); state != StateStopped {
+			t.Fatalf("expected state %v, got %v", StateStopped, state)
+		}
+		if _, err := http.Get("http://" + s.Addr().String() + "/healthz/live"); err == nil {
+			t.Fatal("expected the server not to serve")
+		}
+	})
+
+	t.Run("returns errors from the shutdown and stopped hooks", func(t *testing.T) {
+		shutdownErr := errors.New("could not deregister")
+		s, _ := startServer(t, Options{OnShutdown: func(context.Context) error { return shutdownErr }})
+		if err := s.Stop(context.Background()); !errors.Is(err, shutdownErr) {
+			t.Fatalf("expected the shutdown hook error, got %v", err)
+		}
+
+		stoppedErr := errors.New("could not flush")
+		s, _ = startServer(t, Options{OnStopped: func(context.Context) error { return stoppedErr }})
+		if err := s.Stop(context.Background()); !errors.Is(err, stoppedErr) {
+			t.Fatalf("expected the stopped hook error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// redirectToHTTPS is middleware that redirects plain HTTP requests to the same host, path, and query over HTTPS,
+// with 301 Moved Permanently. A request counts as HTTPS if it came over TLS, or if it came from a trusted proxy
+// with an X-Forwarded-Proto header of https. Exempt requests are never redirected.
+func redirectToHTTPS(trusted []*net.IPNet, exempt func(r *http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isHTTPS(r, trusted) || exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The original request target includes the BasePath, which is stripped from r.URL before the mux.
+			target := r.RequestURI
+			if !strings.HasPrefix(target, "/") {
+				target = r.URL.RequestURI()
+			}
+			http.Redirect(w, r, "https://"+r.Host+target, http.StatusMovedPermanently)
+		})
+	}
+}
+
+// isHTTPS reports whether r reached the Server or the trusted proxy in front of it over HTTPS.
+func isHTTPS(r *http.Request, trusted []*net.IPNet) bool {
+	if r.TLS != nil {
+		return true
+	}
+	ip := remoteIP(r)
+	if ip == nil || !containsIP(trusted, ip) {
+		return false
+	}
+	// With several proxies, the first one saw the client's scheme.
+	proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectToHTTPS(t *testing.T) {
+	trusted, err := parseCIDRs([]string{"192.0.2.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		remoteAddr string
+		proto      string
+		tls        bool
+		location   string
+	}{
+		{name: "redirects plain HTTP preserving host, path, and query", target: "http://example.com/things?id=1&sort=asc",
+			remoteAddr: "203.0.113.1:1234", location: "https://example.com/things?id=1&sort=asc"},
+		{name: "keeps the port in the host", target: "http://example.com:8080/", remoteAddr: "203.0.113.1:1234",
+			location: "https://example.com:8080/"},
+		{name: "passes through TLS requests", target: "https://example.com/", remoteAddr: "203.0.113.1:1234", tls: true},
+		{name: "passes through HTTPS from a trusted proxy", target: "http://example.com/", remoteAddr: "192.0.2.1:1234",
+			proto: "https"},
+		{name: "uses the first X-Forwarded-Proto from a trusted proxy", target: "http://example.com/", remoteAddr: "192.0.2.1:1234",
+			proto: "https, http"},
+		{name: "redirects HTTP from a trusted proxy", target: "http://example.com/", remoteAddr: "192.0.2.1:1234",
+			proto: "http", location: "https://example.com/"},
+		{name: "ignores X-Forwarded-Proto from untrusted sources", target: "http://example.com/", remoteAddr: "203.0.113.1:1234",
+			proto: "https", location: "https://example.com/"},
+		{name: "passes through exempt requests", target: "http://example.com/healthz/live", remoteAddr: "203.0.113.1:1234"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, test.target, nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", test.proto)
+			}
+			if test.tls {
+				req.TLS = &tls.ConnectionState{}
+			} else {
+				req.TLS = nil
+			}
+
+			exempt := func(r *http.Request) bool {
+				return r.URL.Path == "/healthz/live"
+			}
+			rec := httptest.NewRecorder()
+			redirectToHTTPS(trusted, exempt)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})).ServeHTTP(rec, req)
+
+			if test.location == "" {
+				if rec.Code != http.StatusNoContent {
+					t.Fatalf("expected status %v, got %v", http.StatusNoContent, rec.Code)
+				}
+				return
+			}
+			if rec.Code != http.StatusMovedPermanently {
+				t.Fatalf("expected status %v, got %v", http.StatusMovedPermanently, rec.Code)
+			}
+			if location := rec.Header().Get("Location"); location != test.location {
+				t.Fatalf("expected location %v, got %v", test.location, location)
+			}
+		})
+	}
+}
+
+func TestServer_redirectHTTPToHTTPS(t *testing.T) {
+	t.Run("redirects requests but not health probes", func(t *testing.T) {
+		s := New(Options{Host: "localhost", RedirectHTTPToHTTPS: true})
+		s.mux.Get("/things", func(w http.ResponseWriter, r *http.Request) {})
+		address := startTestServer(t, s)
+
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		res, err := client.Get("http://" + address + "/things?a=b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusMovedPermanently {
+			t.Fatalf("expected status %v, got %v", http.StatusMovedPermanently, res.StatusCode)
+		}
+		if location, expected := res.Header.Get("Location"), "https://"+address+"/things?a=b"; location != expected {
+			t.Fatalf("expected location %v, got %v", expected, location)
+		}
+
+		if code := getStatus(t, "http://"+address+"/healthz/live"); code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, code)
+		}
+	})
+
+	t.Run("keeps the base path in the redirect", func(t *testing.T) {
+		s := New(Options{BasePath: "/api/v1", Host: "localhost", RedirectHTTPToHTTPS: true})
+		s.mux.Get("/things", func(w http.ResponseWriter, r *http.Request) {})
+		address := startTestServer(t, s)
+
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		res, err := client.Get("http://" + address + "/api/v1/things?a=b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if location, expected := res.Header.Get("Location"), "https://"+address+"/api/v1/things?a=b"; location != expected {
+			t.Fatalf("expected location %v, got %v", expected, location)
+		}
+	})
+}
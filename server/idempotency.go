@@ -0,0 +1,83 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// Idempotency is middleware that makes requests with an Idempotency-Key header safe to retry.
+// The first request with a key is handled, and its response is kept in memory for ttl. Later requests with the same
+// method, path, and key get that response without calling the handler again. At most maxKeys responses are kept,
+// evicting the least recently used. Server errors aren't kept, so retrying after one handles the request again.
+// A request with a key that's still being handled gets 409 Conflict. Requests without a key are handled as usual.
+// For example:
+//
+//	mux.With(server.Idempotency(24*time.Hour, 10000)).Post("/payments", pay)
+func Idempotency(ttl time.Duration, maxKeys int) func(http.Handler) http.Handler {
+	i := &idempotency{handling: map[string]bool{}, responses: newResponseCache(ttl, maxKeys)}
+	return i.middleware
+}
+
+type idempotency struct {
+	// handling are the keys of requests being handled, protected by lock.
+	handling  map[string]bool
+	lock      sync.Mutex
+	responses *responseCache
+}
+
+func (i *idempotency) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key = r.Method + " " + r.URL.Path + " " + key
+
+		if !i.begin(key) {
+			WriteError(w, r, http.StatusConflict, errors.New("a request with this idempotency key is in progress"))
+			return
+		}
+		defer i.end(key)
+
+		// Looking the key up after beginning makes sure a request finishing at the same time has stored its response.
+		if res, ok := i.responses.get(key); ok {
+			res.write(w)
+			return
+		}
+
+		// Headers set by middleware before the handler, like the request ID, belong to this request only.
+		before := w.Header().Clone()
+		cw := &cacheWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		if cw.status < http.StatusInternalServerError {
+			i.responses.add(&cachedResponse{body: cw.body.Bytes(), header: changedHeader(before, cw.sentHeader()), key: key, status: cw.status})
+		}
+	})
+}
+
+// begin handling the request with key, unless one is already being handled.
+func (i *idempotency) begin(key string) bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.handling[key] {
+		return false
+	}
+	i.handling[key] = true
+	return true
+}
+
+func (i *idempotency) end(key string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	delete(i.handling, key)
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIdempotency(t *testing.T) {
+	t.Run("responds to duplicate keys with the original response", func(t *testing.T) {
+		calls := 0
+		h := Idempotency(time.Minute, 10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Location", "/payments/"+strconv.Itoa(calls))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("payment " + strconv.Itoa(calls)))
+		}))
+
+		for i := 0; i < 3; i++ {
+			res := serveIdempotent(h, "/payments", "abc")
+			if res.Code != http.StatusCreated || res.Body.String() != "payment 1" || res.Header().Get("Location") != "/payments/1" {
+				t.Fatalf("expected the original response, got %v %q %v", res.Code, res.Body.String(), res.Header())
+			}
+		}
+		if calls != 1 {
+			t.Fatalf("expected 1 handler call, got %v", calls)
+		}
+	})
+
+	t.Run("replays responses encoded for each client behind compression", func(t *testing.T) {
+		calls := 0
+		h := compress(Idempotency(time.Minute, 10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("payment"))
+		})))
+
+		for _, acceptEncoding := range []string{"gzip", "gzip", ""} {
+			req := httptest.NewRequest(http.MethodPost, "/payments", nil)
+			req.Header.Set(idempotencyKeyHeader, "abc")
+			if acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", acceptEncoding)
+			}
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, req)
+
+			encoding := res.Header().Get("Content-Encoding")
+			if acceptEncoding == "" {
+				if encoding != "" || res.Body.String() != "payment" {
+					t.Fatalf("expected a plain replay, got %q encoded %q", res.Body.String(), encoding)
+				}
+				continue
+			}
+			if encoding != "gzip" {
+				t.Fatalf("expected gzip content encoding, got %q", encoding)
+			}
+			if decoded := gunzip(t, res.Body); decoded != "payment" {
+				t.Fatalf("expected decoded body payment, got %q", decoded)
+			}
+		}
+		if calls != 1 {
+			t.Fatalf("expected 1 handler call, got %v", calls)
+		}
+	})
+
+	t.Run("handles different keys and paths independently", func(t *testing.T) {
+		calls := 0
+		h := Idempotency(time.Minute, 10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			_, _ = w.Write([]byte(strconv.Itoa(calls)))
+		}))
+
+		if res := serveIdempotent(h, "/payments", "abc"); res.Body.String() != "1" {
+			t.Fatalf("expected a new response, got %q", res.Body.String())
+		}
+		if res := serveIdempotent(h, "/payments", "def"); res.Body.String() != "2" {
+			t.Fatalf("expected a new response for another key, got %q", res.Body.String())
+		}
+		if res := serveIdempotent(h, "/refunds", "abc"); res.Body.String() != "3" {
+			t.Fatalf("expected a new response for another path, got %q", res.Body.String())
+		}
+		if res := serveIdempotent(h, "/payments", ""); res.Body.String() != "4" {
+			t.Fatalf("expected a new response without a key, got %q", res.Body.String())
+		}
+		if res := serveIdempotent(h, "/payments", ""); res.Body.String() != "5" {
+			t.Fatalf("expected a new response without a key, got %q", res.Body.String())
+		}
+	})
+
+	t.Run("handles the request again after a server error", func(t *testing.T) {
+		calls := 0
+		h := Idempotency(time.Minute, 10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+
+		if res := serveIdempotent(h, "/payments", "abc"); res.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v, got %v", http.StatusServiceUnavailable, res.Code)
+		}
+		if res := serveIdempotent(h, "/payments", "abc"); res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+		if calls != 2 {
+			t.Fatalf("expected 2 handler calls, got %v", calls)
+		}
+	})
+
+	t.Run("responds with 409 while the key is being handled", func(t *testing.T) {
+		handling := make(chan struct{})
+		done := make(chan struct{})
+		h := Idempotency(time.Minute, 10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(handling)
+			<-done
+		}))
+
+		first := make(chan *httptest.ResponseRecorder)
+		go func() {
+			first <- serveIdempotent(h, "/payments", "abc")
+		}()
+		<-handling
+
+		if res := serveIdempotent(h, "/payments", "abc"); res.Code != http.StatusConflict {
+			t.Fatalf("expected status %v, got %v", http.StatusConflict, res.Code)
+		}
+		close(done)
+		if res := <-first; res.Code != http.StatusOK {
+			t.Fatalf("expected status %v for the first request, got %v", http.StatusOK, res.Code)
+		}
+	})
+}
+
+func serveIdempotent(h http.Handler, path, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// inFlightLogInterval is how often Stop logs the number of requests still in flight while draining.
+// It's a variable so tests can shorten it.
+var inFlightLogInterval = time.Second
+
+// countInFlight is middleware that keeps count of the requests currently being handled in n.
+func countInFlight(n *atomic.Int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n.Add(1)
+			defer n.Add(-1)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// logInFlight requests every inFlightLogInterval while any are left, until ctx is done.
+func (s *Server) logInFlight(ctx context.Context) {
+	ticker := time.NewTicker(inFlightLogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := s.inFlight.Load(); n > 0 {
+				s.logger().Info("Waiting for requests to finish", zap.Int64("in_flight", n))
+			}
+		}
+	}
+}
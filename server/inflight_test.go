@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestServer_inFlight(t *testing.T) {
+	t.Run("counts requests in flight on the admin port", func(t *testing.T) {
+		s := New(adminOptions(t, Options{Host: "localhost"}))
+		started := make(chan struct{})
+		release := make(chan struct{})
+		s.mux.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})
+		address := startTestServer(t, s)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			res, err := http.Get("http://" + address + "/slow")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = res.Body.Close()
+		}()
+		<-started
+
+		if body := getInFlight(t, s); body != `{"in_flight":1}` {
+			t.Fatalf("expected one request in flight, got %v", body)
+		}
+
+		close(release)
+		<-done
+		if body := getInFlight(t, s); body != `{"in_flight":0}` {
+			t.Fatalf("expected no requests in flight, got %v", body)
+		}
+	})
+
+	t.Run("logs requests in flight while stopping", func(t *testing.T) {
+		original := inFlightLogInterval
+		defer func() {
+			inFlightLogInterval = original
+		}()
+		inFlightLogInterval = 10 * time.Millisecond
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		s := New(Options{Host: "localhost", Log: zap.New(core)})
+		started := make(chan struct{})
+		s.mux.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+		})
+		address := startTestServer(t, s)
+
+		go func() {
+			res, err := http.Get("http://" + address + "/slow")
+			if err == nil {
+				_ = res.Body.Close()
+			}
+		}()
+		<-started
+
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if entries := logs.FilterMessage("Shutting down").FilterField(zap.Int64("in_flight", 1)).Len(); entries != 1 {
+			t.Fatalf("expected the shutdown log with one request in flight, got %v", entries)
+		}
+		if logs.FilterMessage("Waiting for requests to finish").Len() == 0 {
+			t.Fatal("expected logs while waiting for requests to finish")
+		}
+	})
+}
+
+// getInFlight requests from the admin port of s and returns the body.
+func getInFlight(t *testing.T, s *Server) string {
+	t.Helper()
+
+	res, err := http.Get(adminURL(s, "/admin/inflight"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ipFilter allows or denies requests by the client IP.
+type ipFilter struct {
+	allowed []*net.IPNet
+	denied  []*net.IPNet
+}
+
+// newIPFilter from allowed and denied CIDR ranges like 10.0.0.0/8, returning an error for malformed ones.
+func newIPFilter(allowed, denied []string) (*ipFilter, error) {
+	f := &ipFilter{}
+	var err error
+	if f.allowed, err = parseCIDRs(allowed); err != nil {
+		return nil, err
+	}
+	if f.denied, err = parseCIDRs(denied); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q: %w", cidr, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// middleware responds with 403 Forbidden to clients in a denied range,
+// and to clients not in an allowed range if there are any.
+func (f *ipFilter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.allows(ClientIP(r)) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allows reports whether ip passes the filter. A nil ip only passes without any ranges.
+func (f *ipFilter) allows(ip net.IP) bool {
+	if ip == nil {
+		return len(f.allowed) == 0 && len(f.denied) == 0
+	}
+	if containsIP(f.denied, ip) {
+		return false
+	}
+	return len(f.allowed) == 0 || containsIP(f.allowed, ip)
+}
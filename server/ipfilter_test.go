@@ -0,0 +1,77 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPFilter(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name       string
+		allowed    []string
+		denied     []string
+		remoteAddr string
+		expected   int
+	}{
+		{name: "allows clients in an allowed range", allowed: []string{"10.0.0.0/8"}, remoteAddr: "10.1.2.3:1234", expected: http.StatusOK},
+		{name: "forbids clients outside the allowed ranges", allowed: []string{"10.0.0.0/8", "192.168.0.0/16"}, remoteAddr: "192.0.2.1:1234", expected: http.StatusForbidden},
+		{name: "forbids clients in a denied range", denied: []string{"192.0.2.0/24"}, remoteAddr: "192.0.2.1:1234", expected: http.StatusForbidden},
+		{name: "allows clients outside the denied ranges", denied: []string{"192.0.2.0/24"}, remoteAddr: "198.51.100.1:1234", expected: http.StatusOK},
+		{name: "denies before allowing", allowed: []string{"10.0.0.0/8"}, denied: []string{"10.0.0.0/16"}, remoteAddr: "10.0.1.1:1234", expected: http.StatusForbidden},
+		{name: "supports IPv6", allowed: []string{"2001:db8::/32"}, remoteAddr: "[2001:db8::1]:1234", expected: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := newIPFilter(test.allowed, test.denied)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			res := httptest.NewRecorder()
+			f.middleware(next).ServeHTTP(res, req)
+
+			if res.Code != test.expected {
+				t.Fatalf("expected status %v, got %v", test.expected, res.Code)
+			}
+		})
+	}
+
+	t.Run("rejects malformed ranges", func(t *testing.T) {
+		if _, err := newIPFilter([]string{"10.0.0.0/33"}, nil); err == nil {
+			t.Fatal("expected an error")
+		}
+		if _, err := newIPFilter(nil, []string{"not an ip"}); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestServer_ipFilter(t *testing.T) {
+	t.Run("uses the client IP forwarded by a trusted proxy", func(t *testing.T) {
+		s := New(Options{AllowedCIDRs: []string{"10.0.0.0/8"}, TrustedProxies: []string{"192.0.2.0/24"}})
+		s.setupRoutes()
+
+		req := httptest.NewRequest(http.MethodGet, "/healthz/live", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", "10.1.2.3")
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, req)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+	})
+
+	t.Run("refuses to start with malformed ranges", func(t *testing.T) {
+		s := New(Options{AllowedCIDRs: []string{"10.0.0.0"}})
+		if err := s.Start(t.Context()); !errors.Is(err, ErrInvalidOptions) {
+			t.Fatalf("expected ErrInvalidOptions, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net"
+	"time"
+)
+
+// keepAliveConn is a connection with TCP keep-alive settings, like *net.TCPConn.
+type keepAliveConn interface {
+	SetKeepAlive(keepalive bool) error
+	SetKeepAlivePeriod(d time.Duration) error
+}
+
+// keepAliveListener sets the TCP keep-alive period on accepted connections that support it.
+// Like net/http, errors from the settings are ignored, since the connection works without keep-alives.
+type keepAliveListener struct {
+	net.Listener
+	period time.Duration
+}
+
+func (l *keepAliveListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	if kc, ok := c.(keepAliveConn); ok {
+		_ = kc.SetKeepAlive(true)
+		_ = kc.SetKeepAlivePeriod(l.period)
+	}
+	return c, nil
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type fakeKeepAliveConn struct {
+	net.Conn
+	err       error
+	keepAlive bool
+	period    time.Duration
+}
+
+func (c *fakeKeepAliveConn) SetKeepAlive(keepalive bool) error {
+	c.keepAlive = keepalive
+	return c.err
+}
+
+func (c *fakeKeepAliveConn) SetKeepAlivePeriod(d time.Duration) error {
+	c.period = d
+	return c.err
+}
+
+type fakeListener struct {
+	net.Listener
+	conn net.Conn
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	return l.conn, nil
+}
+
+func TestKeepAliveListener(t *testing.T) {
+	t.Run("sets the keep-alive period on accepted connections", func(t *testing.T) {
+		c := &fakeKeepAliveConn{}
+		l := &keepAliveListener{Listener: &fakeListener{conn: c}, period: 42 * time.Second}
+
+		if _, err := l.Accept(); err != nil {
+			t.Fatal(err)
+		}
+		if !c.keepAlive {
+			t.Fatal("expected keep-alive to be enabled")
+		}
+		if c.period != 42*time.Second {
+			t.Fatalf("expected period %v, got %v", 42*time.Second, c.period)
+		}
+	})
+
+	t.Run("accepts connections when the keep-alive settings fail", func(t *testing.T) {
+		c := &fakeKeepAliveConn{err: errors.New("oh no")}
+		l := &keepAliveListener{Listener: &fakeListener{conn: c}, period: time.Second}
+
+		if accepted, err := l.Accept(); err != nil || accepted != c {
+			t.Fatalf("expected the connection despite the error, got %v, %v", accepted, err)
+		}
+	})
+
+	t.Run("accepts connections without keep-alive settings", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer func() {
+			_ = server.Close()
+			_ = client.Close()
+		}()
+		l := &keepAliveListener{Listener: &fakeListener{conn: server}, period: time.Second}
+
+		if c, err := l.Accept(); err != nil || c != server {
+			t.Fatalf("expected the connection unchanged, got %v, %v", c, err)
+		}
+	})
+
+	t.Run("serves requests with a keep-alive period", func(t *testing.T) {
+		_, address := startServer(t, Options{TCPKeepAlive: time.Second})
+
+		if code := getStatus(t, "http://"+address+"/healthz/live"); code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, code)
+		}
+	})
+}
+
+func TestServer_disableKeepAlives(t *testing.T) {
+	t.Run("closes connections after each response when set", func(t *testing.T) {
+		_, address := startServer(t, Options{DisableKeepAlives: true})
+
+		res, err := http.Get("http://" + address + "/healthz/live")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if !res.Close {
+			t.Fatal("expected the connection to be closed")
+		}
+	})
+
+	t.Run("keeps connections alive by default", func(t *testing.T) {
+		_, address := startServer(t, Options{})
+
+		res, err := http.Get("http://" + address + "/healthz/live")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.Close {
+			t.Fatal("expected the connection to be kept alive")
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"canvas/handlers"
+)
+
+// logBuffer keeps the most recent log entries in memory, so they can be served on the admin port.
+type logBuffer struct {
+	entries []handlers.LogEntry
+	lock    sync.Mutex
+	// next is the index the next entry is written to.
+	next int
+	// full is whether entries have wrapped around at least once.
+	full bool
+}
+
+func newLogBuffer(size int) *logBuffer {
+	return &logBuffer{entries: make([]handlers.LogEntry, size)}
+}
+
+func (b *logBuffer) add(e handlers.LogEntry) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.entries[b.next] = e
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Entries in the buffer, oldest first.
+func (b *logBuffer) Entries() []handlers.LogEntry {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.full {
+		return append([]handlers.LogEntry(nil), b.entries[:b.next]...)
+	}
+	return append(append([]handlers.LogEntry(nil), b.entries[b.next:]...), b.entries[:b.next]...)
+}
+
+// tee log so it also writes to the buffer, with entries enabled by level.
+func (b *logBuffer) tee(log *zap.Logger, level zapcore.LevelEnabler) *zap.Logger {
+	return log.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(core, &logBufferCore{LevelEnabler: level, buffer: b})
+	}))
+}
+
+// logBufferCore is a zapcore.Core adding entries to a logBuffer.
+type logBufferCore struct {
+	zapcore.LevelEnabler
+	buffer *logBuffer
+	fields []zapcore.Field
+}
+
+func (c *logBufferCore) With(fields []zapcore.Field) zapcore.Core {
+	return &logBufferCore{
+		LevelEnabler: c.LevelEnabler,
+		buffer:       c.buffer,
+		fields:       append(append([]zapcore.Field(nil), c.fields...), fields...),
+	}
+}
+
+func (c *logBufferCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+func (c *logBufferCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	entry := handlers.LogEntry{
+		Time:    e.Time,
+		Level:   e.Level.String(),
+		Logger:  e.LoggerName,
+		Message: e.Message,
+	}
+	if len(enc.Fields) > 0 {
+		entry.Fields = enc.Fields
+	}
+	c.buffer.add(entry)
+	return nil
+}
+
+func (c *logBufferCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"strconv"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogBuffer(t *testing.T) {
+	t.Run("keeps only the most recent entries, oldest first", func(t *testing.T) {
+		b := newLogBuffer(3)
+		log := b.tee(zap.NewNop(), zap.InfoLevel)
+		for i := 0; i < 5; i++ {
+			log.Info("Entry " + strconv.Itoa(i))
+		}
+
+		entries := b.Entries()
+		if len(entries) != 3 {
+			t.Fatalf("expected 3 entries, got %v", len(entries))
+		}
+		for i, e := range entries {
+			if expected := "Entry " + strconv.Itoa(i+2); e.Message != expected {
+				t.Fatalf("expected message %v at %v, got %v", expected, i, e.Message)
+			}
+		}
+	})
+
+	t.Run("returns fewer entries until it's full", func(t *testing.T) {
+		b := newLogBuffer(3)
+		log := b.tee(zap.NewNop(), zap.InfoLevel)
+		log.Info("Entry 0")
+
+		if entries := b.Entries(); len(entries) != 1 || entries[0].Message != "Entry 0" {
+			t.Fatalf("expected only Entry 0, got %v", entries)
+		}
+	})
+
+	t.Run("keeps levels and fields", func(t *testing.T) {
+		b := newLogBuffer(3)
+		log := b.tee(zap.NewNop(), zap.InfoLevel).With(zap.String("release", "abc"))
+		log.Warn("Careful", zap.Int("count", 2))
+
+		e := b.Entries()[0]
+		if e.Level != "warn" {
+			t.Fatalf("expected level warn, got %v", e.Level)
+		}
+		if e.Fields["release"] != "abc" || e.Fields["count"] != int64(2) {
+			t.Fatalf("expected release and count fields, got %v", e.Fields)
+		}
+	})
+
+	t.Run("skips disabled levels", func(t *testing.T) {
+		b := newLogBuffer(3)
+		log := b.tee(zap.NewNop(), zap.InfoLevel)
+		log.Debug("Hidden")
+
+		if entries := b.Entries(); len(entries) != 0 {
+			t.Fatalf("expected no entries, got %v", entries)
+		}
+	})
+
+	t.Run("still writes to the original logger", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		b := newLogBuffer(3)
+		log := b.tee(zap.New(core), zap.InfoLevel)
+		log.Info("Hello")
+
+		if logs.FilterMessage("Hello").Len() != 1 {
+			t.Fatal("expected the entry in the original logger")
+		}
+		if len(b.Entries()) != 1 {
+			t.Fatal("expected the entry in the buffer")
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const loggerContextKey = contextKey("logger")
+
+// LoggerFromContext returns the logger for the request that ctx belongs to, which has the request ID, method,
+// and path as fields. Outside of a Server, it returns the global logger from zap.L.
+func LoggerFromContext(ctx context.Context) *zap.Logger {
+	if log, ok := ctx.Value(loggerContextKey).(*zap.Logger); ok {
+		return log
+	}
+	return zap.L()
+}
+
+// withRequestLogger is middleware that stores a child of the current logger with request fields in the request context,
+// for LoggerFromContext. It must come after requestID.
+func withRequestLogger(log func() *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestLog := log().With(
+				zap.String("request_id", RequestIDFromContext(r.Context())),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+			)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), loggerContextKey, requestLog)))
+		})
+	}
+}
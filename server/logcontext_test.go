@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLoggerFromContext(t *testing.T) {
+	t.Run("carries the request ID, method, and path", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		s := New(Options{Log: zap.New(core)})
+		s.mux.Post("/things", func(w http.ResponseWriter, r *http.Request) {
+			LoggerFromContext(r.Context()).Info("Handling")
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/things", nil)
+		req.Header.Set(requestIDHeader, "abc123")
+		s.mux.ServeHTTP(httptest.NewRecorder(), req)
+
+		entries := logs.FilterMessage("Handling").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 log entry, got %v", len(entries))
+		}
+		fields := entries[0].ContextMap()
+		expected := map[string]any{"request_id": "abc123", "method": http.MethodPost, "path": "/things"}
+		for name, value := range expected {
+			if fields[name] != value {
+				t.Fatalf("expected %v to be %v, got %v", name, value, fields[name])
+			}
+		}
+	})
+
+	t.Run("uses the logger set with SetLogger", func(t *testing.T) {
+		s := New(Options{})
+		s.mux.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			LoggerFromContext(r.Context()).Info("Handling")
+		})
+		core, logs := observer.New(zap.InfoLevel)
+		s.SetLogger(zap.New(core))
+
+		s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if logs.FilterMessage("Handling").Len() != 1 {
+			t.Fatal("request logger does not use the new logger")
+		}
+	})
+
+	t.Run("falls back to the global logger outside of a Server", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		defer zap.ReplaceGlobals(zap.New(core))()
+
+		LoggerFromContext(context.Background()).Info("Hello")
+		if logs.FilterMessage("Hello").Len() != 1 {
+			t.Fatal("global logger not used")
+		}
+	})
+}
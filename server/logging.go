@@ -0,0 +1,153 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// requestCounts are the totals of requests served, by status class, for the summary logged by Stop.
+type requestCounts struct {
+	total     atomic.Uint64
+	status2xx atomic.Uint64
+	status4xx atomic.Uint64
+	status5xx atomic.Uint64
+}
+
+// record a request that was answered with status.
+func (c *requestCounts) record(status int) {
+	c.total.Add(1)
+	switch status / 100 {
+	case 2:
+		c.status2xx.Add(1)
+	case 4:
+		c.status4xx.Add(1)
+	case 5:
+		c.status5xx.Add(1)
+	}
+}
+
+// accessLogFields are the names of the fields logRequests logs for each request.
+var accessLogFields = []string{"method", "path", "status", "duration", "remote_addr", "client_ip", "request_id"}
+
+// accessLogFieldSet of the known names, for requestLogOptions.Fields, and the unknown names.
+// No names means all fields, for which the set is nil.
+func accessLogFieldSet(names []string) (map[string]bool, []string) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	set := map[string]bool{}
+	var unknown []string
+	for _, name := range names {
+		if !slices.Contains(accessLogFields, name) {
+			unknown = append(unknown, name)
+			continue
+		}
+		set[name] = true
+	}
+	return set, unknown
+}
+
+// excludedPath reports whether path matches one of the patterns. A pattern like /healthz matches that path
+// and the paths below it, like /healthz/live, and a pattern ending in * like /debug* matches all paths starting with the rest.
+func excludedPath(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+			continue
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+		if path == pattern || strings.HasPrefix(path, pattern+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+type requestLogOptions struct {
+	// Counts are updated for every request, regardless of sampling, if set.
+	Counts *requestCounts
+	// ExcludePaths are paths whose requests aren't logged, unless they result in a server error.
+	// See excludedPath for the patterns.
+	ExcludePaths []string
+	// Fields are the names of the fields to log for each request. Nil means all of them.
+	Fields map[string]bool
+	// SampleRate makes only every Nth successful request be logged. Errors are always logged.
+	// Zero and one mean logging every request.
+	SampleRate int
+	// SlowThreshold is the duration above which requests are also logged at warn level, regardless of sampling.
+	// Zero turns it off.
+	SlowThreshold time.Duration
+}
+
+// logRequests is middleware that logs every request with method, path, status, duration, and remote address.
+// Requests resulting in a server error are logged at error level, everything else at info level.
+// Requests the client closed before getting a response are logged with status 499.
+// Requests taking longer than the slow threshold get an additional warning.
+// Requests for excluded paths are only logged with server errors. The logged fields can be limited to a set of them with requestLogOptions.Fields.
+func logRequests(log func() *zap.Logger, opts requestLogOptions) func(http.Handler) http.Handler {
+	var successes atomic.Uint64
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			w, rec := recordResponse(w, r)
+
+			next.ServeHTTP(w, r)
+			duration := time.Since(start)
+
+			status := rec.Status()
+			switch {
+			case clientClosed(r):
+				status = statusClientClosedRequest
+			case status == 0:
+				status = http.StatusOK
+			}
+
+			if opts.Counts != nil {
+				opts.Counts.record(status)
+			}
+
+			if opts.SlowThreshold > 0 && duration > opts.SlowThreshold {
+				log().Warn("Slow request", zap.String("method", r.Method), zap.String("path", r.URL.Path),
+					zap.Int("status", status), zap.Duration("duration", duration), zap.Duration("threshold", opts.SlowThreshold),
+					zap.String("request_id", RequestIDFromContext(r.Context())))
+			}
+
+			if status < http.StatusInternalServerError && excludedPath(opts.ExcludePaths, r.URL.Path) {
+				return
+			}
+
+			if status < http.StatusBadRequest && opts.SampleRate > 1 && (successes.Add(1)-1)%uint64(opts.SampleRate) != 0 {
+				return
+			}
+
+			fields := []zap.Field{
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", status),
+				zap.Duration("duration", duration),
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.Stringer("client_ip", ClientIP(r)),
+				zap.String("request_id", RequestIDFromContext(r.Context())),
+			}
+			if opts.Fields != nil {
+				fields = slices.DeleteFunc(fields, func(f zap.Field) bool {
+					return !opts.Fields[f.Key]
+				})
+			}
+			if status >= http.StatusInternalServerError {
+				log().Error("Request", fields...)
+				return
+			}
+			log().Info("Request", fields...)
+		})
+	}
+}
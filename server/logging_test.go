@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		level  zapcore.Level
+	}{
+		{name: "logs successful requests at info level", status: http.StatusOK, level: zapcore.InfoLevel},
+		{name: "logs client errors at info level", status: http.StatusNotFound, level: zapcore.InfoLevel},
+		{name: "logs server errors at error level", status: http.StatusInternalServerError, level: zapcore.ErrorLevel},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			log := zap.New(core)
+
+			h := logRequests(func() *zap.Logger { return log }, requestLogOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/things?id=1", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			entries := logs.All()
+			if len(entries) != 1 {
+				t.Fatalf("expected 1 log entry, got %v", len(entries))
+			}
+			entry := entries[0]
+			if entry.Level != test.level {
+				t.Fatalf("expected level %v, got %v", test.level, entry.Level)
+			}
+
+			fields := entry.ContextMap()
+			expected := map[string]any{
+				"method":      http.MethodPost,
+				"path":        "/things",
+				"status":      int64(test.status),
+				"remote_addr": "192.0.2.1:1234",
+			}
+			for k, v := range expected {
+				if fields[k] != v {
+					t.Fatalf("expected field %v to be %v, got %v", k, v, fields[k])
+				}
+			}
+			if _, ok := fields["duration"]; !ok {
+				t.Fatal("duration field missing")
+			}
+		})
+	}
+}
+
+func TestLogRequestsFields(t *testing.T) {
+	t.Run("logs only the configured fields", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		log := zap.New(core)
+		fields, unknown := accessLogFieldSet([]string{"method", "status", "duration", "bytes"})
+		if len(unknown) != 1 || unknown[0] != "bytes" {
+			t.Fatalf("expected bytes to be unknown, got %v", unknown)
+		}
+
+		h := logRequests(func() *zap.Logger { return log }, requestLogOptions{Fields: fields})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things", nil))
+
+		entries := logs.All()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 log entry, got %v", len(entries))
+		}
+		logged := entries[0].ContextMap()
+		if len(logged) != 3 {
+			t.Fatalf("expected 3 fields, got %v", logged)
+		}
+		for _, name := range []string{"method", "status", "duration"} {
+			if _, ok := logged[name]; !ok {
+				t.Fatalf("expected field %v, got %v", name, logged)
+			}
+		}
+	})
+
+	t.Run("logs all fields by default", func(t *testing.T) {
+		if fields, unknown := accessLogFieldSet(nil); fields != nil || unknown != nil {
+			t.Fatalf("expected all fields, got %v and unknown %v", fields, unknown)
+		}
+	})
+
+	t.Run("warns about unknown fields on setup", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		New(Options{AccessLogFields: []string{"path", "bytes"}, Log: zap.New(core), LogRequests: true})
+
+		entries := logs.FilterMessage("Unknown access log fields, ignoring").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 warning, got %v", len(entries))
+		}
+		if names := entries[0].ContextMap()["names"]; fmt.Sprint(names) != "[bytes]" {
+			t.Fatalf("expected the unknown names in the warning, got %v", names)
+		}
+	})
+}
+
+func TestLogRequestsExcludePaths(t *testing.T) {
+	tests := []struct {
+		path   string
+		status int
+		logged bool
+	}{
+		{path: "/healthz", status: http.StatusOK, logged: false},
+		{path: "/healthz/live", status: http.StatusOK, logged: false},
+		{path: "/healthz/ready", status: http.StatusServiceUnavailable, logged: true},
+		{path: "/metrics", status: http.StatusNotFound, logged: false},
+		{path: "/metricsfoo", status: http.StatusOK, logged: true},
+		{path: "/debug/vars", status: http.StatusOK, logged: false},
+		{path: "/debugging", status: http.StatusOK, logged: false},
+		{path: "/things", status: http.StatusOK, logged: true},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%v with status %v", test.path, test.status), func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			log := zap.New(core)
+			opts := requestLogOptions{ExcludePaths: []string{"/healthz", "/metrics/", "/debug*"}}
+			h := logRequests(func() *zap.Logger { return log }, opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+			}))
+
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			if logged := logs.FilterMessage("Request").Len() == 1; logged != test.logged {
+				t.Fatalf("expected logged to be %v, got %v", test.logged, logged)
+			}
+		})
+	}
+}
+
+func TestLogRequestsSampling(t *testing.T) {
+	t.Run("logs only every Nth successful request", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		log := zap.New(core)
+		h := logRequests(func() *zap.Logger { return log }, requestLogOptions{SampleRate: 10})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		for i := 0; i < 100; i++ {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+
+		if n := logs.Len(); n != 10 {
+			t.Fatalf("expected 10 log entries, got %v", n)
+		}
+	})
+
+	t.Run("never samples out errors", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		log := zap.New(core)
+		h := logRequests(func() *zap.Logger { return log }, requestLogOptions{SampleRate: 10})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+
+		for i := 0; i < 100; i++ {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+
+		if n := logs.Len(); n != 100 {
+			t.Fatalf("expected 100 log entries, got %v", n)
+		}
+	})
+}
+
+func TestLogRequestsSlow(t *testing.T) {
+	tests := []struct {
+		name  string
+		sleep time.Duration
+		warns int
+	}{
+		{name: "warns about requests over the threshold", sleep: 50 * time.Millisecond, warns: 1},
+		{name: "does not warn about requests under the threshold", warns: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			log := zap.New(core)
+			h := logRequests(func() *zap.Logger { return log }, requestLogOptions{SlowThreshold: 20 * time.Millisecond})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(test.sleep)
+			}))
+
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			slow := logs.FilterMessage("Slow request").FilterLevelExact(zapcore.WarnLevel).All()
+			if len(slow) != test.warns {
+				t.Fatalf("expected %v slow request warnings, got %v", test.warns, len(slow))
+			}
+			if test.warns > 0 && slow[0].ContextMap()["duration"].(time.Duration) < test.sleep {
+				t.Fatalf("expected a duration of at least %v, got %v", test.sleep, slow[0].ContextMap()["duration"])
+			}
+			if logs.FilterMessage("Request").Len() != 1 {
+				t.Fatal("expected the request to be logged as usual")
+			}
+		})
+	}
+
+	t.Run("warns about slow requests even if sampled out", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		log := zap.New(core)
+		h := logRequests(func() *zap.Logger { return log }, requestLogOptions{SampleRate: 10, SlowThreshold: time.Millisecond})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(5 * time.Millisecond)
+		}))
+
+		for i := 0; i < 2; i++ {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}
+
+		if n := logs.FilterMessage("Slow request").Len(); n != 2 {
+			t.Fatalf("expected 2 slow request warnings, got %v", n)
+		}
+	})
+}
+
+func TestLogRequestsClientClosed(t *testing.T) {
+	t.Run("logs requests the client cancelled with status 499", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		log := zap.New(core)
+		h := logRequests(func() *zap.Logger { return log }, requestLogOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+		go cancel()
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		entries := logs.All()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 log entry, got %v", len(entries))
+		}
+		if entries[0].Level != zapcore.InfoLevel {
+			t.Fatalf("expected info level, got %v", entries[0].Level)
+		}
+		if status := entries[0].ContextMap()["status"]; status != int64(499) {
+			t.Fatalf("expected status 499, got %v", status)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+const maintenanceMessage = "We're down for maintenance and will be back shortly."
+
+// inMaintenance is middleware that responds with 503 Service Unavailable while on is set, except for exempt requests.
+func inMaintenance(on *atomic.Bool, exempt func(r *http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !on.Load() || exempt(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Retry-After", "60")
+			writeErrorMessage(w, r, http.StatusServiceUnavailable, maintenanceMessage)
+		})
+	}
+}
+
+// MaintenanceMode reports whether the Server responds to requests on Port with 503 Service Unavailable.
+func (s *Server) MaintenanceMode() bool {
+	return s.maintenance.Load()
+}
+
+// SetMaintenanceMode turns maintenance mode on or off, in which all requests on Port get 503 Service Unavailable,
+// except for health, metrics, and version. It's safe to call while the Server is running.
+func (s *Server) SetMaintenanceMode(on bool) {
+	if s.maintenance.Swap(on) != on {
+		s.logger().Info("Set maintenance mode", zap.Bool("enabled", on))
+	}
+}
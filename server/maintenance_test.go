@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_maintenanceMode(t *testing.T) {
+	t.Run("responds with 503 to application routes while health stays up", func(t *testing.T) {
+		s := New(Options{})
+		s.setupRoutes()
+		s.mux.Get("/things", func(w http.ResponseWriter, r *http.Request) {})
+
+		for _, on := range []bool{true, false} {
+			s.SetMaintenanceMode(on)
+
+			req := httptest.NewRequest(http.MethodGet, "/things", nil)
+			req.Header.Set("Accept", "application/json")
+			res := httptest.NewRecorder()
+			s.mux.ServeHTTP(res, req)
+
+			if on {
+				if res.Code != http.StatusServiceUnavailable {
+					t.Fatalf("expected status %v in maintenance mode, got %v", http.StatusServiceUnavailable, res.Code)
+				}
+				if body := res.Body.String(); !strings.Contains(body, maintenanceMessage) {
+					t.Fatalf("expected the maintenance message, got %v", body)
+				}
+				if retryAfter := res.Header().Get("Retry-After"); retryAfter == "" {
+					t.Fatal("expected a Retry-After header")
+				}
+			} else if res.Code != http.StatusOK {
+				t.Fatalf("expected status %v after maintenance mode, got %v", http.StatusOK, res.Code)
+			}
+
+			res = httptest.NewRecorder()
+			s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz/live", nil))
+			if res.Code != http.StatusOK {
+				t.Fatalf("expected status %v for the health check, got %v", http.StatusOK, res.Code)
+			}
+		}
+	})
+
+	t.Run("starts in maintenance mode if set", func(t *testing.T) {
+		s := New(Options{MaintenanceMode: true})
+		if !s.MaintenanceMode() {
+			t.Fatal("expected maintenance mode to be on")
+		}
+	})
+
+	t.Run("is toggled on the admin port", func(t *testing.T) {
+		s := New(adminOptions(t, Options{Host: "localhost"}))
+		s.mux.Get("/things", func(w http.ResponseWriter, r *http.Request) {})
+		address := startTestServer(t, s)
+
+		req, err := http.NewRequest(http.MethodPut, adminURL(s, "/admin/maintenance"), strings.NewReader(`{"enabled":true}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.StatusCode)
+		}
+
+		if code := getStatus(t, "http://"+address+"/things"); code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v, got %v", http.StatusServiceUnavailable, code)
+		}
+		if code := getStatus(t, adminURL(s, "/healthz/live")); code != http.StatusOK {
+			t.Fatalf("expected status %v on the admin port, got %v", http.StatusOK, code)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrMemoryThreshold is returned by Start after the Server shut itself down because memory use exceeded
+// the MemoryShutdownThreshold.
+var ErrMemoryThreshold = errors.New("memory shutdown threshold exceeded")
+
+// memoryCheckInterval is how often memory use is compared to the MemoryShutdownThreshold.
+// It's a variable so tests can shorten it.
+var memoryCheckInterval = time.Second
+
+// readMemory returns the memory obtained from the operating system by the Go runtime, minus what it has returned,
+// which is close to what the OOM killer looks at.
+func readMemory() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.Sys - stats.HeapReleased
+}
+
+// watchMemory every memoryCheckInterval until ctx is done. Once memory use exceeds the threshold,
+// the Server reports not ready and Start is told to shut down gracefully.
+func (s *Server) watchMemory(ctx context.Context) {
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			used := s.readMemory()
+			if used <= uint64(s.memoryThreshold) {
+				continue
+			}
+
+			fields := []zap.Field{zap.Uint64("memory_bytes", used), zap.Int64("threshold_bytes", s.memoryThreshold),
+				zap.Int64("in_flight", s.inFlight.Load())}
+			if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+				fields = append(fields, zap.Int64("memory_limit_bytes", limit))
+			}
+			s.logger().Warn("Memory above shutdown threshold, shutting down", fields...)
+
+			s.ready.Store(false)
+			close(s.memoryExceeded)
+			return
+		}
+	}
+}
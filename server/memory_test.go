@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestServer_memoryShutdownThreshold(t *testing.T) {
+	original := memoryCheckInterval
+	memoryCheckInterval = time.Millisecond
+	t.Cleanup(func() {
+		memoryCheckInterval = original
+	})
+
+	t.Run("shuts down gracefully once memory exceeds the threshold", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		s := New(Options{Host: "localhost", Log: zap.New(core), MemoryShutdownThreshold: 1000})
+		var used atomic.Uint64
+		used.Store(500)
+		s.readMemory = used.Load
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		if err := s.WaitReady(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case err := <-errs:
+			t.Fatalf("stopped below the threshold with %v", err)
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		used.Store(1001)
+		if err := <-errs; !errors.Is(err, ErrMemoryThreshold) {
+			t.Fatalf("expected ErrMemoryThreshold, got %v", err)
+		}
+		if s.ready.Load() {
+			t.Fatal("still ready after exceeding the threshold")
+		}
+		if state := s.State(); state != StateStopped {
+			t.Fatalf("expected state %v, got %v", StateStopped, state)
+		}
+
+		entries := logs.FilterMessage("Memory above shutdown threshold, shutting down").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 log entry, got %v", len(entries))
+		}
+		fields := entries[0].ContextMap()
+		if fields["memory_bytes"] != uint64(1001) || fields["threshold_bytes"] != int64(1000) {
+			t.Fatalf("expected memory 1001 and threshold 1000, got %v", fields)
+		}
+	})
+
+	t.Run("does not watch memory without a threshold", func(t *testing.T) {
+		s := New(Options{Host: "localhost"})
+		s.readMemory = func() uint64 {
+			t.Error("memory read without a threshold")
+			return 0
+		}
+		startTestServer(t, s)
+		time.Sleep(10 * time.Millisecond)
+	})
+}
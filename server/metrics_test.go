@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestServer_metrics(t *testing.T) {
+	t.Run("counts requests by method, route, and status", func(t *testing.T) {
+		s := New(Options{MetricsEnabled: true})
+		s.setupRoutes()
+
+		req := httptest.NewRequest(http.MethodGet, "/healthz/live", nil)
+		s.mux.ServeHTTP(httptest.NewRecorder(), req)
+
+		families, err := s.metrics.registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range families {
+			if f.GetName() != "http_requests_total" {
+				continue
+			}
+			for _, m := range f.GetMetric() {
+				labels := map[string]string{}
+				for _, l := range m.GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+				}
+				if labels["method"] == "GET" && labels["route"] == "/healthz/live" && labels["status"] == "200" {
+					if v := m.GetCounter().GetValue(); v != 1 {
+						t.Fatalf("expected counter to be 1, got %v", v)
+					}
+					return
+				}
+			}
+		}
+		t.Fatal("request counter not found")
+	})
+
+	t.Run("serves metrics only when enabled", func(t *testing.T) {
+		for _, enabled := range []bool{true, false} {
+			s := New(Options{MetricsEnabled: enabled})
+			s.setupRoutes()
+
+			res := httptest.NewRecorder()
+			s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+			expected := http.StatusNotFound
+			if enabled {
+				expected = http.StatusOK
+			}
+			if res.Code != expected {
+				t.Fatalf("expected status %v with metrics enabled %v, got %v", expected, enabled, res.Code)
+			}
+		}
+	})
+
+	t.Run("labels requests by route pattern instead of path", func(t *testing.T) {
+		s := New(Options{MetricsEnabled: true})
+		s.mux.Get("/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		s.mux.Route("/teams/{team}", func(r chi.Router) {
+			r.Get("/members/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		})
+		s.setupRoutes()
+
+		for _, path := range []string{"/users/1", "/users/2", "/users/3", "/teams/a/members/1", "/teams/b/members/2", "/nope/1", "/nope/2"} {
+			s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+
+		expected := map[string]float64{
+			"/users/{id}":                3,
+			"/teams/{team}/members/{id}": 2,
+			unmatchedRoute:               2,
+		}
+		counts := routeCounts(t, s)
+		for route, count := range expected {
+			if counts[route] != count {
+				t.Fatalf("expected %v requests for route %v, got %v", count, route, counts[route])
+			}
+		}
+		if len(counts) != len(expected) {
+			t.Fatalf("expected only routes %v, got %v", expected, counts)
+		}
+	})
+}
+
+// routeCounts of s by route label, summed over methods and statuses.
+func routeCounts(t *testing.T, s *Server) map[string]float64 {
+	t.Helper()
+
+	families, err := s.metrics.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]float64{}
+	for _, f := range families {
+		if f.GetName() != "http_requests_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "route" {
+					counts[l.GetValue()] += m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return counts
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// routingMethods are the methods checked for the Allow header of 405 Method Not Allowed responses.
+var routingMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// setupErrorHandlers on mux for requests that match no route, using notFound and methodNotAllowed if not nil,
+// and WriteError otherwise.
+// Responses for methods not allowed always get an Allow header, listing the methods that match the path.
+func setupErrorHandlers(mux chi.Router, notFound, methodNotAllowed http.Handler) {
+	if notFound == nil {
+		notFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, http.StatusNotFound, errors.New(http.StatusText(http.StatusNotFound)))
+		})
+	}
+	if methodNotAllowed == nil {
+		methodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, http.StatusMethodNotAllowed, errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+		})
+	}
+
+	mux.NotFound(notFound.ServeHTTP)
+	mux.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", strings.Join(allowedMethods(mux, r.URL.Path), ", "))
+		methodNotAllowed.ServeHTTP(w, r)
+	})
+}
+
+// allowedMethods for path on routes. HEAD is allowed wherever GET is, because answerHead answers it.
+func allowedMethods(routes chi.Routes, path string) []string {
+	var methods []string
+	for _, method := range routingMethods {
+		if routes.Match(chi.NewRouteContext(), method, path) ||
+			method == http.MethodHead && routes.Match(chi.NewRouteContext(), http.MethodGet, path) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
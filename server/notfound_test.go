@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_errorHandlers(t *testing.T) {
+	t.Run("responds to unknown paths with a JSON 404", func(t *testing.T) {
+		s := New(Options{})
+		s.setupRoutes()
+
+		req := httptest.NewRequest(http.MethodGet, "/things/1", nil)
+		req.Header.Set("Accept", "application/json")
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, req)
+
+		if res.Code != http.StatusNotFound {
+			t.Fatalf("expected status %v, got %v", http.StatusNotFound, res.Code)
+		}
+		if body := strings.TrimSpace(res.Body.String()); body != `{"error":"Not Found"}` {
+			t.Fatalf("expected body %v, got %v", `{"error":"Not Found"}`, body)
+		}
+	})
+
+	t.Run("responds to unsupported methods with a JSON 405 and the allowed methods", func(t *testing.T) {
+		s := New(Options{})
+		s.setupRoutes()
+		s.mux.Post("/healthz/live", func(w http.ResponseWriter, r *http.Request) {})
+
+		req := httptest.NewRequest(http.MethodDelete, "/healthz/live", nil)
+		req.Header.Set("Accept", "application/json")
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, req)
+
+		if res.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("expected status %v, got %v", http.StatusMethodNotAllowed, res.Code)
+		}
+		if allow := res.Header().Get("Allow"); allow != "GET, HEAD, POST" {
+			t.Fatalf("expected Allow header %v, got %v", "GET, HEAD, POST", allow)
+		}
+		if body := strings.TrimSpace(res.Body.String()); body != `{"error":"Method Not Allowed"}` {
+			t.Fatalf("expected body %v, got %v", `{"error":"Method Not Allowed"}`, body)
+		}
+	})
+
+	t.Run("uses custom handlers", func(t *testing.T) {
+		s := New(Options{
+			NotFoundHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte("nothing here"))
+			}),
+			MethodNotAllowedHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				_, _ = w.Write([]byte("not like this"))
+			}),
+		})
+		s.setupRoutes()
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/things/1", nil))
+		if res.Code != http.StatusNotFound || res.Body.String() != "nothing here" {
+			t.Fatalf("expected the custom 404, got %v %q", res.Code, res.Body.String())
+		}
+
+		res = httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/healthz/live", nil))
+		if res.Code != http.StatusMethodNotAllowed || res.Body.String() != "not like this" {
+			t.Fatalf("expected the custom 405, got %v %q", res.Code, res.Body.String())
+		}
+		if allow := res.Header().Get("Allow"); allow != "GET, HEAD" {
+			t.Fatalf("expected Allow header %v, got %v", "GET, HEAD", allow)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Option configures a Server in New. Options itself is an Option, which replaces everything set before it,
+// so it goes first when combined with the With functions.
+type Option interface {
+	apply(opts *Options)
+}
+
+func (o Options) apply(opts *Options) {
+	*opts = o
+}
+
+// optionFunc is an Option that sets part of the Options.
+type optionFunc func(opts *Options)
+
+func (f optionFunc) apply(opts *Options) {
+	f(opts)
+}
+
+// WithAdminPort sets Options.AdminPort and Options.AdminPassword.
+func WithAdminPort(port int, password string) Option {
+	return optionFunc(func(opts *Options) {
+		opts.AdminPort = port
+		opts.AdminPassword = password
+	})
+}
+
+// WithHandler sets Options.Handler.
+func WithHandler(h http.Handler) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Handler = h
+	})
+}
+
+// WithHost sets Options.Host.
+func WithHost(host string) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Host = host
+	})
+}
+
+// WithLogger sets Options.Log.
+func WithLogger(log *zap.Logger) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Log = log
+	})
+}
+
+// WithMiddlewares appends to Options.Middlewares, so they're applied after those added before.
+func WithMiddlewares(middlewares ...func(http.Handler) http.Handler) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Middlewares = append(opts.Middlewares, middlewares...)
+	})
+}
+
+// WithPort sets Options.Port.
+func WithPort(port int) Option {
+	return optionFunc(func(opts *Options) {
+		opts.Port = port
+	})
+}
+
+// WithShutdownTimeout sets Options.ShutdownTimeout.
+func WithShutdownTimeout(d time.Duration) Option {
+	return optionFunc(func(opts *Options) {
+		opts.ShutdownTimeout = d
+	})
+}
+
+// WithTimeout sets Options.ReadTimeout and Options.WriteTimeout to d, for requests that should be done within it.
+func WithTimeout(d time.Duration) Option {
+	return optionFunc(func(opts *Options) {
+		opts.ReadTimeout = d
+		opts.WriteTimeout = d
+	})
+}
+
+// WithTLS sets Options.TLSCertFile and Options.TLSKeyFile.
+func WithTLS(certFile, keyFile string) Option {
+	return optionFunc(func(opts *Options) {
+		opts.TLSCertFile = certFile
+		opts.TLSKeyFile = keyFile
+	})
+}
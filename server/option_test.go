@@ -0,0 +1,93 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNew_options(t *testing.T) {
+	t.Run("configures the server with functional options", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		s := New(
+			WithHost("localhost"),
+			WithPort(8080),
+			WithLogger(zap.New(core)),
+			WithTimeout(3*time.Second),
+			WithShutdownTimeout(time.Minute),
+			WithTLS("cert.pem", "key.pem"),
+			WithAdminPort(8081, "hunter2"),
+		)
+
+		expected := map[string]any{
+			"AdminPassword":   redacted,
+			"AdminPort":       8081,
+			"Host":            "localhost",
+			"IdleTimeout":     "2m0s",
+			"Port":            8080,
+			"ReadTimeout":     "3s",
+			"ShutdownTimeout": "1m0s",
+			"TLSCertFile":     "cert.pem",
+			"TLSKeyFile":      redacted,
+			"WriteTimeout":    "3s",
+		}
+		for name, value := range expected {
+			if s.config[name] != value {
+				t.Fatalf("expected %v to be %v, got %v", name, value, s.config[name])
+			}
+		}
+		s.logger().Info("Hello")
+		if logs.FilterMessage("Hello").Len() != 1 {
+			t.Fatal("logger not used")
+		}
+	})
+
+	t.Run("applies options after Options in order", func(t *testing.T) {
+		s := New(Options{Host: "example.com", Port: 1}, WithPort(2), WithPort(3))
+		if s.config["Host"] != "example.com" {
+			t.Fatalf("expected host example.com, got %v", s.config["Host"])
+		}
+		if s.config["Port"] != 3 {
+			t.Fatalf("expected port 3, got %v", s.config["Port"])
+		}
+	})
+
+	t.Run("uses the handler and appends middlewares", func(t *testing.T) {
+		var order []string
+		middleware := func(name string) func(http.Handler) http.Handler {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					order = append(order, name)
+					next.ServeHTTP(w, r)
+				})
+			}
+		}
+		s := New(
+			WithHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})),
+			WithMiddlewares(middleware("first")),
+			WithMiddlewares(middleware("second")),
+		)
+		s.setupRoutes()
+
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusTeapot {
+			t.Fatalf("expected status %v, got %v", http.StatusTeapot, rec.Code)
+		}
+		if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+			t.Fatalf("expected middlewares first and second in order, got %v", order)
+		}
+	})
+
+	t.Run("has the same defaults as an empty Options", func(t *testing.T) {
+		if withOptions, withNothing := New(Options{}).config, New().config; len(withOptions) != len(withNothing) {
+			t.Fatalf("expected %v config values, got %v", len(withOptions), len(withNothing))
+		}
+	})
+}
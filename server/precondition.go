@@ -0,0 +1,67 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrPreconditionFailed is for handlers to write with 412 Precondition Failed when CheckPrecondition or
+// CheckUnmodifiedSince fails, because the resource changed since the client last saw it.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// CheckPrecondition evaluates the If-Match header of r against currentETag, the current ETag of the resource,
+// for optimistic concurrency control on updates like PUT and PATCH. It doesn't write anything,
+// so the handler decides what to do with the outcome. handled reports whether r has an If-Match header at all,
+// and ok whether it matches. Without one, ok is true. An empty currentETag means the resource doesn't exist:
+//
+//	if ok, _ := server.CheckPrecondition(r, thing.ETag); !ok {
+//		server.WriteError(w, r, http.StatusPreconditionFailed, server.ErrPreconditionFailed)
+//		return
+//	}
+func CheckPrecondition(r *http.Request, currentETag string) (ok, handled bool) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return true, false
+	}
+	return etagMatchesStrong(ifMatch, currentETag), true
+}
+
+// CheckUnmodifiedSince evaluates the If-Unmodified-Since header of r against modified, when the resource was last modified,
+// like CheckPrecondition does for If-Match. Like in RFC 9110, the header only counts without If-Match,
+// so it's for handlers that have a modification time too, after CheckPrecondition wasn't handled.
+// It's not handled either with an invalid date, or a zero modified time, which means it's unknown.
+func CheckUnmodifiedSince(r *http.Request, modified time.Time) (ok, handled bool) {
+	ifUnmodifiedSince := r.Header.Get("If-Unmodified-Since")
+	if r.Header.Get("If-Match") != "" || ifUnmodifiedSince == "" || modified.IsZero() {
+		return true, false
+	}
+	since, err := http.ParseTime(ifUnmodifiedSince)
+	if err != nil {
+		// Invalid dates are ignored.
+		return true, false
+	}
+	// HTTP dates have second precision.
+	return !modified.Truncate(time.Second).After(since), true
+}
+
+// etagMatchesStrong reports whether the If-Match header value matches etag, using the strong comparison,
+// so weak ETags never match.
+func etagMatchesStrong(ifMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(ifMatch) == "*" {
+		return true
+	}
+	if strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckPrecondition(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		etag    string
+		ok      bool
+		handled bool
+	}{
+		{name: "passes without If-Match", etag: `"v2"`, ok: true},
+		{name: "passes with a matching If-Match", ifMatch: `"v2"`, etag: `"v2"`, ok: true, handled: true},
+		{name: "passes with a matching If-Match among others", ifMatch: `"v1", "v2"`, etag: `"v2"`, ok: true, handled: true},
+		{name: "passes with If-Match * for an existing resource", ifMatch: "*", etag: `"v2"`, ok: true, handled: true},
+		{name: "fails with a non-matching If-Match", ifMatch: `"v1"`, etag: `"v2"`, handled: true},
+		{name: "fails with If-Match for a missing resource", ifMatch: "*", handled: true},
+		{name: "fails with If-Match for a weak ETag", ifMatch: `W/"v2"`, etag: `W/"v2"`, handled: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/things/1", nil)
+			if test.ifMatch != "" {
+				req.Header.Set("If-Match", test.ifMatch)
+			}
+
+			ok, handled := CheckPrecondition(req, test.etag)
+			if ok != test.ok || handled != test.handled {
+				t.Fatalf("expected %v, %v, got %v, %v", test.ok, test.handled, ok, handled)
+			}
+		})
+	}
+}
+
+func TestCheckUnmodifiedSince(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		modified time.Time
+		ok       bool
+		handled  bool
+	}{
+		{name: "passes without If-Unmodified-Since", modified: modified, ok: true},
+		{name: "passes if not modified since", value: modified.Format(http.TimeFormat), modified: modified.Add(500 * time.Millisecond),
+			ok: true, handled: true},
+		{name: "fails if modified since", value: modified.Add(-time.Second).Format(http.TimeFormat), modified: modified, handled: true},
+		{name: "ignores If-Unmodified-Since without a modification time", value: modified.Format(http.TimeFormat), ok: true},
+		{name: "ignores invalid If-Unmodified-Since dates", value: "yesterday", modified: modified, ok: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/things/1", nil)
+			if test.value != "" {
+				req.Header.Set("If-Unmodified-Since", test.value)
+			}
+
+			ok, handled := CheckUnmodifiedSince(req, test.modified)
+			if ok != test.ok || handled != test.handled {
+				t.Fatalf("expected %v, %v, got %v, %v", test.ok, test.handled, ok, handled)
+			}
+		})
+	}
+
+	t.Run("ignores If-Unmodified-Since with If-Match", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPatch, "/things/1", nil)
+		req.Header.Set("If-Match", `"v2"`)
+		req.Header.Set("If-Unmodified-Since", modified.Add(-time.Hour).Format(http.TimeFormat))
+
+		if ok, handled := CheckUnmodifiedSince(req, modified); !ok || handled {
+			t.Fatalf("expected If-Match to win, got %v, %v", ok, handled)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServer_proxyProtocol(t *testing.T) {
+	t.Run("uses the client address from the PROXY header", func(t *testing.T) {
+		s := New(Options{AllowedCIDRs: []string{"203.0.113.0/24"}, Host: "localhost", ProxyProtocol: true})
+		s.mux.Get("/ip", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, r.RemoteAddr)
+		})
+		address := startTestServer(t, s)
+
+		c, err := net.Dial("tcp", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = c.Close()
+		}()
+		_, err = io.WriteString(c, "PROXY TCP4 203.0.113.7 192.0.2.1 56324 443\r\n"+
+			"GET /ip HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := http.ReadResponse(bufio.NewReader(c), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.StatusCode)
+		}
+		if !strings.HasPrefix(string(body), "203.0.113.7:") {
+			t.Fatalf("expected the client address from the header, got %v", string(body))
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitTTL is how long a client can be idle before its limiter is evicted.
+const rateLimitTTL = 3 * time.Minute
+
+// rateLimiter keeps a token bucket per client IP.
+type rateLimiter struct {
+	burst   int
+	clients map[string]*rateLimitedClient
+	limit   rate.Limit
+	lock    sync.Mutex
+	now     func() time.Time
+}
+
+type rateLimitedClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// newRateLimiter allowing limit requests per second per client, with bursts of up to burst requests.
+func newRateLimiter(limit float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = max(1, int(math.Ceil(limit)))
+	}
+	return &rateLimiter{
+		burst:   burst,
+		clients: map[string]*rateLimitedClient{},
+		limit:   rate.Limit(limit),
+		now:     time.Now,
+	}
+}
+
+// middleware that responds with 429 Too Many Requests and a Retry-After header when a client exceeds its limit.
+func (l *rateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := r.RemoteAddr
+		if clientIP := ClientIP(r); clientIP != nil {
+			ip = clientIP.String()
+		}
+
+		res := l.limiter(ip).Reserve()
+		if delay := res.Delay(); delay > 0 {
+			res.Cancel()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limiter for the given client, created if it doesn't exist yet.
+func (l *rateLimiter) limiter(ip string) *rate.Limiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	c, ok := l.clients[ip]
+	if !ok {
+		c = &rateLimitedClient{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[ip] = c
+	}
+	c.lastSeen = l.now()
+	return c.limiter
+}
+
+// sweep evicts limiters for clients that have been idle for longer than rateLimitTTL.
+func (l *rateLimiter) sweep() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for ip, c := range l.clients {
+		if l.now().Sub(c.lastSeen) > rateLimitTTL {
+			delete(l.clients, ip)
+		}
+	}
+}
+
+// sweepUntilDone sweeps regularly until ctx is done.
+func (l *rateLimiter) sweepUntilDone(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitTTL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.sweep()
+		}
+	}
+}
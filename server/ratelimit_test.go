@@ -0,0 +1,77 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	t.Run("responds with 429 and Retry-After when a client exceeds its limit", func(t *testing.T) {
+		h := newRateLimiter(1, 3).middleware(next)
+
+		var codes []int
+		var retryAfter string
+		for i := 0; i < 5; i++ {
+			res := makeRequestFrom(h, "192.0.2.1:1234")
+			codes = append(codes, res.Code)
+			if res.Code == http.StatusTooManyRequests {
+				retryAfter = res.Header().Get("Retry-After")
+			}
+		}
+
+		expected := []int{200, 200, 200, 429, 429}
+		for i := range expected {
+			if codes[i] != expected[i] {
+				t.Fatalf("expected status codes %v, got %v", expected, codes)
+			}
+		}
+		if retryAfter != "1" {
+			t.Fatalf("expected Retry-After 1, got %q", retryAfter)
+		}
+	})
+
+	t.Run("gives different clients independent buckets", func(t *testing.T) {
+		h := newRateLimiter(1, 1).middleware(next)
+
+		if code := makeRequestFrom(h, "192.0.2.1:1234").Code; code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, code)
+		}
+		if code := makeRequestFrom(h, "192.0.2.1:1234").Code; code != http.StatusTooManyRequests {
+			t.Fatalf("expected status %v, got %v", http.StatusTooManyRequests, code)
+		}
+		if code := makeRequestFrom(h, "192.0.2.2:1234").Code; code != http.StatusOK {
+			t.Fatalf("expected status %v for another client, got %v", http.StatusOK, code)
+		}
+	})
+
+	t.Run("evicts idle clients", func(t *testing.T) {
+		l := newRateLimiter(1, 1)
+		now := time.Now()
+		l.now = func() time.Time { return now }
+		l.limiter("192.0.2.1")
+		now = now.Add(rateLimitTTL / 2)
+		l.limiter("192.0.2.2")
+
+		now = now.Add(rateLimitTTL/2 + time.Second)
+		l.sweep()
+
+		if _, ok := l.clients["192.0.2.1"]; ok {
+			t.Fatal("idle client not evicted")
+		}
+		if _, ok := l.clients["192.0.2.2"]; !ok {
+			t.Fatal("recent client evicted")
+		}
+	})
+}
+
+func makeRequestFrom(h http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res
+}
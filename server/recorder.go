@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+)
+
+const responseRecorderContextKey = contextKey("responseRecorder")
+
+// responseRecorder is an http.ResponseWriter that remembers the status code and the number of body bytes written to it.
+// It's installed once by recordResponses, in front of the middleware that report on responses, like logging, metrics,
+// and tracing, which get it with recordResponse instead of each wrapping the writer again.
+// It forwards flushing, hijacking, and io.ReaderFrom, so wrapping doesn't hide what the underlying writer supports.
+type responseRecorder struct {
+	http.ResponseWriter
+	bytes  int64
+	status int
+}
+
+// recordResponses is middleware that wraps the http.ResponseWriter in a responseRecorder,
+// and stores the recorder in the request context for recordResponse.
+func recordResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), responseRecorderContextKey, rec)))
+	})
+}
+
+// recordResponse returns the responseRecorder installed by recordResponses for r, together with w to write to.
+// Without one, like when the middleware calling it is used by itself, it wraps w in a new responseRecorder and returns that.
+func recordResponse(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *responseRecorder) {
+	if rec, ok := r.Context().Value(responseRecorderContextKey).(*responseRecorder); ok {
+		return w, rec
+	}
+	rec := &responseRecorder{ResponseWriter: w}
+	return rec, rec
+}
+
+// Status written so far, which is 200 OK once the body is written without an explicit status, or 0 if nothing is written.
+func (rec *responseRecorder) Status() int {
+	return rec.status
+}
+
+// Bytes of the body written so far.
+func (rec *responseRecorder) Bytes() int64 {
+	return rec.bytes
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	// Informational responses like 103 Early Hints come before the final one, except for 101 Switching Protocols.
+	if rec.status == 0 && (code >= http.StatusOK || code == http.StatusSwitchingProtocols) {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom src into the underlying http.ResponseWriter, which can use sendfile for files that way.
+func (rec *responseRecorder) ReadFrom(src io.Reader) (int64, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := io.Copy(rec.ResponseWriter, src)
+	rec.bytes += n
+	return n, err
+}
+
+// Flush the underlying http.ResponseWriter, if it supports that.
+func (rec *responseRecorder) Flush() {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	_ = http.NewResponseController(rec.ResponseWriter).Flush()
+}
+
+// Hijack the connection of the underlying http.ResponseWriter,
+// returning an error wrapping http.ErrNotSupported if it doesn't support that, like with HTTP/2.
+func (rec *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rec.ResponseWriter).Hijack()
+}
+
+// Unwrap the underlying http.ResponseWriter, so http.ResponseController can reach it.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
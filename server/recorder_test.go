@@ -0,0 +1,117 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecordResponses(t *testing.T) {
+	t.Run("records the status and size once for all middleware", func(t *testing.T) {
+		var statuses []int
+		var sizes []int64
+		report := func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w, rec := recordResponse(w, r)
+				next.ServeHTTP(w, r)
+				statuses = append(statuses, rec.Status())
+				sizes = append(sizes, rec.Bytes())
+			})
+		}
+
+		h := recordResponses(report(report(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := w.(*responseRecorder); !ok {
+				t.Errorf("expected the handler to get the responseRecorder, got %T", w)
+			}
+			if _, stacked := w.(*responseRecorder).ResponseWriter.(*responseRecorder); stacked {
+				t.Error("expected a single responseRecorder")
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("hello"))
+			_, _ = io.Copy(w, strings.NewReader(", world"))
+		}))))
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		for i := range statuses {
+			if statuses[i] != http.StatusCreated || sizes[i] != 12 {
+				t.Fatalf("expected status %v and size 12, got %v and %v", http.StatusCreated, statuses[i], sizes[i])
+			}
+		}
+		if body := res.Body.String(); body != "hello, world" {
+			t.Fatalf("expected hello, world, got %v", body)
+		}
+	})
+
+	t.Run("records 200 OK for a body without a status", func(t *testing.T) {
+		w, rec := recordResponse(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Status() != 0 {
+			t.Fatalf("expected no status before writing, got %v", rec.Status())
+		}
+		_, _ = w.Write([]byte("hi"))
+		if rec.Status() != http.StatusOK || rec.Bytes() != 2 {
+			t.Fatalf("expected status %v and size 2, got %v and %v", http.StatusOK, rec.Status(), rec.Bytes())
+		}
+	})
+
+	t.Run("reports hijacking as not supported without the underlying writer supporting it", func(t *testing.T) {
+		w, _ := recordResponse(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if _, _, err := w.(http.Hijacker).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+			t.Fatalf("expected http.ErrNotSupported, got %v", err)
+		}
+	})
+}
+
+func TestServer_recordResponses(t *testing.T) {
+	t.Run("flushes and hijacks through the middleware", func(t *testing.T) {
+		s := New(Options{Host: "localhost", LogRequests: true, MetricsEnabled: true})
+		release := make(chan struct{})
+		s.mux.Get("/stream", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("first\n"))
+			w.(http.Flusher).Flush()
+			<-release
+		})
+		s.mux.Get("/hijack", func(w http.ResponseWriter, r *http.Request) {
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer func() { _ = conn.Close() }()
+			_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+			_ = buf.Flush()
+		})
+		address := startTestServer(t, s)
+
+		res, err := http.Get("http://" + address + "/stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		line, err := bufio.NewReader(res.Body).ReadString('\n')
+		close(release)
+		_ = res.Body.Close()
+		if err != nil || line != "first\n" {
+			t.Fatalf("expected the flushed line before the handler returned, got %q and %v", line, err)
+		}
+
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = conn.Write([]byte("GET /hijack HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+		hijacked, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(hijacked.Body)
+		if string(body) != "hijacked" {
+			t.Fatalf("expected the hijacked response, got %q", body)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// panicModeRecover responds to panics in handlers with 500 Internal Server Error and keeps serving.
+	panicModeRecover = "recover"
+	// panicModeCrash logs panics in handlers and then crashes the process.
+	panicModeCrash = "crash"
+)
+
+// recoverPanics is middleware that recovers panics in handlers, logs them with a stack trace, reports them,
+// and responds with 500 Internal Server Error instead of crashing.
+// With crash, panics are logged and the log is synced, but then passed on instead of responding.
+// Panics with http.ErrAbortHandler are passed on, because net/http uses them to abort a response on purpose.
+func recoverPanics(log func() *zap.Logger, crash bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				value, stack := v, zap.Stack("stack")
+				if p, ok := v.(*handlerPanic); ok {
+					value, stack = p.value, zap.String("stack", string(p.stack))
+				}
+
+				if crash {
+					log().Error("Panic in handler, crashing", zap.Any("panic", value), stack,
+						zap.String("method", r.Method), zap.String("path", r.URL.Path))
+					_ = log().Sync()
+					panic(v)
+				}
+
+				log().Error("Recovered from panic", zap.Any("panic", value), stack,
+					zap.String("method", r.Method), zap.String("path", r.URL.Path))
+				ReportError(r.Context(), fmt.Errorf("panic: %v", value))
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// handlerPanic is a panic recovered on another goroutine than the one serving the request, like in timeoutRequests,
+// for panicking with again on that one. It keeps the stack of where the panic happened, which recoverPanics logs.
+type handlerPanic struct {
+	value any
+	stack []byte
+}
+
+// String is what the process prints if the panic crashes it, with the original stack.
+func (p *handlerPanic) String() string {
+	return fmt.Sprintf("%v\n\n%s", p.value, p.stack)
+}
+
+// crashOnPanic makes panics in next crash the process, which net/http would otherwise recover per connection.
+// Panics with http.ErrAbortHandler are passed on to net/http.
+func crashOnPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			// A panic on a new goroutine can't be recovered, so it ends the process.
+			go func() { panic(v) }()
+			select {}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
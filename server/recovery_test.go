@@ -0,0 +1,112 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	t.Run("responds with 500 and logs the panic with a stack trace", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		log := zap.New(core)
+
+		h := recoverPanics(func() *zap.Logger { return log }, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("oh no")
+		}))
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if res.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status %v, got %v", http.StatusInternalServerError, res.Code)
+		}
+
+		entries := logs.All()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 log entry, got %v", len(entries))
+		}
+		fields := entries[0].ContextMap()
+		if fields["panic"] != "oh no" {
+			t.Fatalf("expected panic value in log, got %v", fields["panic"])
+		}
+		if stack, _ := fields["stack"].(string); !strings.Contains(stack, "TestRecoverPanics") {
+			t.Fatalf("expected stack trace in log, got %v", stack)
+		}
+	})
+
+	t.Run("logs the stack of a handler running behind the request timeout", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		log := zap.New(core)
+
+		h := recoverPanics(func() *zap.Logger { return log }, false)(timeoutRequests(time.Second)(http.HandlerFunc(panicOhNo)))
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if res.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status %v, got %v", http.StatusInternalServerError, res.Code)
+		}
+		fields := logs.All()[0].ContextMap()
+		if fields["panic"] != "oh no" {
+			t.Fatalf("expected panic value in log, got %v", fields["panic"])
+		}
+		if stack, _ := fields["stack"].(string); !strings.Contains(stack, "panicOhNo") {
+			t.Fatalf("expected the stack of the handler in log, got %v", stack)
+		}
+	})
+
+	t.Run("passes on http.ErrAbortHandler", func(t *testing.T) {
+		h := recoverPanics(zap.NewNop, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		}))
+
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Fatalf("expected http.ErrAbortHandler, got %v", v)
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+
+	t.Run("logs, syncs, and passes on panics when crashing", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		log := zap.New(core)
+
+		h := recoverPanics(func() *zap.Logger { return log }, true)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("oh no")
+		}))
+
+		defer func() {
+			if v := recover(); v != "oh no" {
+				t.Fatalf("expected the panic to be passed on, got %v", v)
+			}
+			entries := logs.FilterMessage("Panic in handler, crashing").All()
+			if len(entries) != 1 {
+				t.Fatalf("expected 1 log entry, got %v", len(entries))
+			}
+			if fields := entries[0].ContextMap(); fields["panic"] != "oh no" {
+				t.Fatalf("expected panic value in log, got %v", fields["panic"])
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		t.Fatalf("expected a panic")
+	})
+
+	t.Run("refuses to start with an unknown panic mode", func(t *testing.T) {
+		s := New(Options{PanicMode: "ignore"})
+		if err := s.Start(t.Context()); !errors.Is(err, ErrInvalidOptions) {
+			t.Fatalf("expected ErrInvalidOptions, got %v", err)
+		}
+	})
+}
+
+func panicOhNo(http.ResponseWriter, *http.Request) {
+	panic("oh no")
+}
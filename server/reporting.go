@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"net/http"
+)
+
+// ErrorReporter reports errors to an external error tracking service, like Sentry.
+type ErrorReporter interface {
+	ReportError(ctx context.Context, err error)
+}
+
+type nopErrorReporter struct{}
+
+func (nopErrorReporter) ReportError(context.Context, error) {}
+
+const (
+	errorReporterContextKey = contextKey("errorReporter")
+	releaseContextKey       = contextKey("release")
+)
+
+// ReportError with the ErrorReporter of the Server handling the request that ctx belongs to.
+// Handlers should call it for internal errors they don't write with WriteError, which reports server errors itself.
+// Outside of a request, it does nothing.
+func ReportError(ctx context.Context, err error) {
+	if reporter, ok := ctx.Value(errorReporterContextKey).(ErrorReporter); ok {
+		reporter.ReportError(ctx, err)
+	}
+}
+
+// ReleaseFromContext returns the release of the Server handling the request that ctx belongs to.
+// ErrorReporter implementations can use it to tag reports with the version.
+func ReleaseFromContext(ctx context.Context) string {
+	release, _ := ctx.Value(releaseContextKey).(string)
+	return release
+}
+
+// withErrorReporting is middleware that makes the reporter and release available to ReportError.
+func withErrorReporting(reporter ErrorReporter, release string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), errorReporterContextKey, reporter)
+			ctx = context.WithValue(ctx, releaseContextKey, release)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
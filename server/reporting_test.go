@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type report struct {
+	err     error
+	release string
+}
+
+type fakeErrorReporter struct {
+	lock    sync.Mutex
+	reports []report
+}
+
+func (f *fakeErrorReporter) ReportError(ctx context.Context, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.reports = append(f.reports, report{err: err, release: ReleaseFromContext(ctx)})
+}
+
+func TestServer_errorReporting(t *testing.T) {
+	t.Run("reports a panic exactly once, tagged with the release", func(t *testing.T) {
+		reporter := &fakeErrorReporter{}
+		s := New(Options{ErrorReporter: reporter, Release: "abc123"})
+		s.mux.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+			panic("oh no")
+		})
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+		if res.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status %v, got %v", http.StatusInternalServerError, res.Code)
+		}
+		if len(reporter.reports) != 1 {
+			t.Fatalf("expected 1 report, got %v", len(reporter.reports))
+		}
+		if reporter.reports[0].release != "abc123" {
+			t.Fatalf("expected release abc123, got %q", reporter.reports[0].release)
+		}
+	})
+
+	t.Run("reports errors from handlers", func(t *testing.T) {
+		reporter := &fakeErrorReporter{}
+		s := New(Options{ErrorReporter: reporter})
+		s.mux.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			ReportError(r.Context(), errors.New("database on fire"))
+		})
+
+		s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if len(reporter.reports) != 1 || reporter.reports[0].err.Error() != "database on fire" {
+			t.Fatalf("expected the handler error to be reported, got %v", reporter.reports)
+		}
+	})
+
+	t.Run("reports server errors written with WriteError", func(t *testing.T) {
+		reporter := &fakeErrorReporter{}
+		s := New(Options{ErrorReporter: reporter})
+		s.mux.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, http.StatusBadRequest, errors.New("bad thing"))
+			WriteError(httptest.NewRecorder(), r, http.StatusBadGateway, errors.New("upstream down"))
+		})
+
+		s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if len(reporter.reports) != 1 || reporter.reports[0].err.Error() != "upstream down" {
+			t.Fatalf("expected only the server error to be reported, got %v", reporter.reports)
+		}
+	})
+}
+
+func TestReportError(t *testing.T) {
+	t.Run("does nothing outside a request", func(t *testing.T) {
+		ReportError(context.Background(), errors.New("nobody cares"))
+	})
+}
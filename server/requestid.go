@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+type contextKey string
+
+const requestIDContextKey = contextKey("requestID")
+
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest X-Request-ID header accepted from clients.
+const maxRequestIDLength = 128
+
+// RequestIDFromContext returns the ID of the request that ctx belongs to, or the empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// requestID is middleware that gives every request an ID, taken from the X-Request-ID header if it's valid,
+// and generated otherwise. The ID is stored in the request context and echoed in the response header.
+// Since it ends up in every log line and span, only IDs up to maxRequestIDLength characters of
+// letters, digits, dots, underscores, and dashes are taken from the header.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey, id)))
+	})
+}
+
+// validRequestID reports whether id is a non-empty request ID of at most maxRequestIDLength safe characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range []byte(id) {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns 16 random bytes, hex-encoded.
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
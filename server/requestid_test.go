@@ -0,0 +1,109 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Run("generates an ID if none is given", func(t *testing.T) {
+		var id string
+		h := requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = RequestIDFromContext(r.Context())
+		}))
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if len(id) != 32 {
+			t.Fatalf("expected a 32 character ID, got %q", id)
+		}
+		if header := res.Header().Get("X-Request-ID"); header != id {
+			t.Fatalf("expected response header %q, got %q", id, header)
+		}
+	})
+
+	t.Run("passes through an existing ID", func(t *testing.T) {
+		var id string
+		h := requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = RequestIDFromContext(r.Context())
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", "abc")
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+
+		if id != "abc" {
+			t.Fatalf("expected ID abc, got %q", id)
+		}
+		if header := res.Header().Get("X-Request-ID"); header != "abc" {
+			t.Fatalf("expected response header abc, got %q", header)
+		}
+	})
+
+	t.Run("generates an ID instead of a too long or unsafe one", func(t *testing.T) {
+		var id string
+		h := requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = RequestIDFromContext(r.Context())
+		}))
+
+		for _, given := range []string{strings.Repeat("a", maxRequestIDLength+1), "abc\x7fdef", "abc def", "abc<script>"} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Request-ID", given)
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, req)
+
+			if id == given || len(id) != 32 {
+				t.Fatalf("expected a generated ID instead of %q, got %q", given, id)
+			}
+			if header := res.Header().Get("X-Request-ID"); header != id {
+				t.Fatalf("expected response header %q, got %q", id, header)
+			}
+		}
+	})
+
+	t.Run("passes through an ID of the maximum length", func(t *testing.T) {
+		var id string
+		h := requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = RequestIDFromContext(r.Context())
+		}))
+
+		given := strings.Repeat("a.b_c-D9", maxRequestIDLength/8)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", given)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if id != given {
+			t.Fatalf("expected ID %q, got %q", given, id)
+		}
+	})
+
+	t.Run("adds the ID to request logs", func(t *testing.T) {
+		core, logs := observer.New(zapcore.InfoLevel)
+		log := zap.New(core)
+		h := requestID(logRequests(func() *zap.Logger { return log }, requestLogOptions{})(http.NotFoundHandler()))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", "abc")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if id := logs.All()[0].ContextMap()["request_id"]; id != "abc" {
+			t.Fatalf("expected request_id abc in log, got %v", id)
+		}
+	})
+}
+
+func TestRequestIDFromContext(t *testing.T) {
+	t.Run("returns the empty string without an ID", func(t *testing.T) {
+		if id := RequestIDFromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()); id != "" {
+			t.Fatalf("expected no ID, got %q", id)
+		}
+	})
+}
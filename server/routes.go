@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"canvas/handlers"
+)
+
+// setupRoutes registers all handlers on the Server's routers.
+// Operational endpoints go on the admin router if there is one, and on the main router otherwise,
+// unless default routes are disabled. Static files, if any, are always served on the main router.
+// Endpoints that change the Server or expose its internals, like the configuration, setting the log level,
+// maintenance mode, recent logs, requests in flight, registered routes, profiling, and garbage collection,
+// are only served on the admin router, where they need the admin credentials.
+// Health probes and the version don't, so probes keep working without them.
+func (s *Server) setupRoutes() {
+	var probes, admin chi.Router = s.mux, s.mux
+	if s.adminMux != nil {
+		probes = s.adminMux
+		admin = s.adminMux.With(s.adminAuth)
+		handlers.Config(admin, s.config)
+		handlers.LogLevel(admin, s.logLevel)
+		handlers.Maintenance(admin, s.MaintenanceMode, s.SetMaintenanceMode)
+		handlers.InFlight(admin, s.inFlight.Load)
+		handlers.Routes(admin, s.mux)
+		if s.logBuffer != nil {
+			handlers.Logs(admin, s.logBuffer.Entries)
+		}
+		if s.pprof {
+			handlers.Pprof(admin)
+		}
+		if s.debugGC {
+			handlers.GC(admin)
+		}
+	}
+	if s.adminMux != nil || !s.disableDefaultRoutes {
+		s.setupAdminRoutes(probes, admin)
+	}
+
+	if routes, ok := s.handler.(chi.Routes); ok {
+		// Mounting keeps the routes of a chi router visible to RouteConcurrencyLimits and the route listing.
+		s.mux.Mount("/", mountedRoutes{handler: s.handler, routes: routes})
+	} else if s.handler != nil {
+		s.mux.Handle("/*", s.handler)
+	}
+	if s.staticFS != nil {
+		handlers.Static(s.mux, s.staticPrefix, s.staticFS)
+	}
+}
+
+// mountedRoutes is a chi router to Mount that isn't a *chi.Mux, so chi doesn't give it the Server's
+// not found and method not allowed handlers, which are only for requests without a Handler.
+type mountedRoutes struct {
+	handler http.Handler
+	routes  chi.Routes
+}
+
+func (m mountedRoutes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.handler.ServeHTTP(w, r)
+}
+
+func (m mountedRoutes) Routes() []chi.Route {
+	return m.routes.Routes()
+}
+
+func (m mountedRoutes) Middlewares() chi.Middlewares {
+	return m.routes.Middlewares()
+}
+
+func (m mountedRoutes) Match(rctx *chi.Context, method, path string) bool {
+	return m.routes.Match(rctx, method, path)
+}
+
+func (m mountedRoutes) Find(rctx *chi.Context, method, path string) string {
+	return m.routes.Find(rctx, method, path)
+}
+
+// setupAdminRoutes registers health and version endpoints on probes, and metrics on mux.
+// The readiness probe includes the checks added with AddHealthCheck.
+func (s *Server) setupAdminRoutes(probes, mux chi.Router) {
+	handlers.Health(probes, s.ready.Load, s.currentHealthChecks)
+	handlers.Version(probes, s.release)
+	if s.metrics != nil {
+		handlers.Metrics(mux, s.metrics.registry)
+	}
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestServer_setupRoutes(t *testing.T) {
+	t.Run("routes unmatched requests to a custom handler", func(t *testing.T) {
+		s := New(Options{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Custom", r.URL.Path)
+			w.WriteHeader(http.StatusTeapot)
+		})})
+		s.setupRoutes()
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/things/1", nil))
+		if res.Code != http.StatusTeapot || res.Header().Get("X-Custom") != "/things/1" {
+			t.Fatalf("expected custom handler to serve /things/1, got status %v", res.Code)
+		}
+
+		res = httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz/live", nil))
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected built-in route to still respond with %v, got %v", http.StatusOK, res.Code)
+		}
+	})
+
+	t.Run("serves all routes under the base path", func(t *testing.T) {
+		handler := chi.NewMux()
+		handler.Get("/foo", func(w http.ResponseWriter, r *http.Request) {})
+		_, address := startServer(t, Options{BasePath: "/api/v1/", Handler: handler})
+
+		for path, expected := range map[string]int{
+			"/api/v1/foo":          http.StatusOK,
+			"/api/v1/healthz/live": http.StatusOK,
+			"/foo":                 http.StatusNotFound,
+			"/healthz/live":        http.StatusNotFound,
+		} {
+			if code := getStatus(t, "http://"+address+path); code != expected {
+				t.Fatalf("expected status %v for %v, got %v", expected, path, code)
+			}
+		}
+	})
+
+	t.Run("applies custom middlewares in order", func(t *testing.T) {
+		appendHeader := func(value string) func(http.Handler) http.Handler {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Add("X-Order", value)
+					next.ServeHTTP(w, r)
+				})
+			}
+		}
+		s := New(Options{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Order", "handler")
+			}),
+			Middlewares: []func(http.Handler) http.Handler{appendHeader("first"), appendHeader("second")},
+		})
+		s.setupRoutes()
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/things", nil))
+
+		if order := strings.Join(res.Header().Values("X-Order"), ","); order != "first,second,handler" {
+			t.Fatalf("expected order first,second,handler, got %v", order)
+		}
+	})
+
+	t.Run("leaves out the default routes if disabled", func(t *testing.T) {
+		for _, disabled := range []bool{true, false} {
+			s := New(Options{DisableDefaultRoutes: disabled, MetricsEnabled: true})
+			s.setupRoutes()
+
+			expected := http.StatusOK
+			if disabled {
+				expected = http.StatusNotFound
+			}
+			for _, path := range []string{"/healthz/live", "/metrics", "/version"} {
+				res := httptest.NewRecorder()
+				s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+				if res.Code != expected {
+					t.Fatalf("expected status %v for %v with disabled %v, got %v", expected, path, disabled, res.Code)
+				}
+			}
+		}
+	})
+
+	t.Run("gives the custom handler all paths if default routes are disabled", func(t *testing.T) {
+		s := New(Options{DisableDefaultRoutes: true, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})})
+		s.setupRoutes()
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz/live", nil))
+		if res.Code != http.StatusTeapot {
+			t.Fatalf("expected status %v, got %v", http.StatusTeapot, res.Code)
+		}
+	})
+
+	t.Run("responds with 404 without a custom handler", func(t *testing.T) {
+		s := New(Options{})
+		s.setupRoutes()
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/things/1", nil))
+		if res.Code != http.StatusNotFound {
+			t.Fatalf("expected status %v, got %v", http.StatusNotFound, res.Code)
+		}
+	})
+
+	t.Run("serves static files under the prefix", func(t *testing.T) {
+		s := New(Options{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}),
+			StaticFS: fstest.MapFS{
+				"index.html": {Data: []byte("<html>app</html>")},
+				"app.js":     {Data: []byte("console.log('app')")},
+			},
+			StaticPrefix: "/app",
+		})
+		s.setupRoutes()
+
+		for path, expected := range map[string]string{
+			"/app/app.js":   "console.log('app')",
+			"/app/things/1": "<html>app</html>",
+			"/app/":         "<html>app</html>",
+		} {
+			res := httptest.NewRecorder()
+			s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, path, nil))
+			if res.Code != http.StatusOK || res.Body.String() != expected {
+				t.Fatalf("expected %v for %v, got status %v and %v", expected, path, res.Code, res.Body.String())
+			}
+		}
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/things/1", nil))
+		if res.Code != http.StatusTeapot {
+			t.Fatalf("expected the custom handler outside the prefix, got status %v", res.Code)
+		}
+	})
+
+	t.Run("keeps the base path when redirecting to the prefix with a slash", func(t *testing.T) {
+		_, address := startServer(t, Options{
+			BasePath:     "/api/v1",
+			StaticFS:     fstest.MapFS{"index.html": {Data: []byte("<html>app</html>")}},
+			StaticPrefix: "/app",
+		})
+
+		client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		res, err := client.Get("http://" + address + "/api/v1/app?a=b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if location := res.Header.Get("Location"); location != "/api/v1/app/?a=b" {
+			t.Fatalf("expected location /api/v1/app/?a=b, got %v", location)
+		}
+	})
+
+	t.Run("serves static files at the root for GET requests only", func(t *testing.T) {
+		s := New(Options{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}),
+			StaticFS: fstest.MapFS{"index.html": {Data: []byte("<html>app</html>")}},
+		})
+		s.setupRoutes()
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/things/1", nil))
+		if res.Code != http.StatusOK || res.Body.String() != "<html>app</html>" {
+			t.Fatalf("expected index.html, got status %v and %v", res.Code, res.Body.String())
+		}
+
+		res = httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/things/1", nil))
+		if res.Code != http.StatusTeapot {
+			t.Fatalf("expected status %v, got %v", http.StatusTeapot, res.Code)
+		}
+
+		res = httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz/live", nil))
+		if res.Code != http.StatusOK || res.Body.String() == "<html>app</html>" {
+			t.Fatalf("expected built-in route to still respond, got status %v", res.Code)
+		}
+	})
+
+	t.Run("includes added health checks in the readiness probe", func(t *testing.T) {
+		s := New(Options{HealthCheckTimeout: 10 * time.Millisecond})
+		s.setupRoutes()
+		s.ready.Store(true)
+
+		s.AddHealthCheck("database", func(context.Context) error { return nil })
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz/ready", nil))
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.Code)
+		}
+
+		s.AddHealthCheck("cache", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		res = httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz/ready", nil))
+		if res.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v, got %v", http.StatusServiceUnavailable, res.Code)
+		}
+		if body := res.Body.String(); !strings.Contains(body, `"cache":{"status":"failed","error":"context deadline exceeded"}`) {
+			t.Fatalf("expected the cache check to have failed, got %v", body)
+		}
+	})
+}
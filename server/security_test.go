@@ -0,0 +1,85 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	t.Run("sets default headers even if the handler writes the header right away", func(t *testing.T) {
+		h := securityHeaders(SecurityHeaders{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assertHeaders(t, res.Header(), map[string]string{
+			"Content-Security-Policy": "default-src 'self'",
+			"X-Content-Type-Options":  "nosniff",
+			"X-Frame-Options":         "DENY",
+			"Referrer-Policy":         "strict-origin-when-cross-origin",
+		})
+	})
+
+	t.Run("uses the given headers and defaults for the rest", func(t *testing.T) {
+		h := securityHeaders(SecurityHeaders{
+			ContentSecurityPolicy: "default-src 'none'",
+			FrameOptions:          "SAMEORIGIN",
+		})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assertHeaders(t, res.Header(), map[string]string{
+			"Content-Security-Policy": "default-src 'none'",
+			"X-Content-Type-Options":  "nosniff",
+			"X-Frame-Options":         "SAMEORIGIN",
+			"Referrer-Policy":         "strict-origin-when-cross-origin",
+		})
+	})
+
+	t.Run("lets handlers override a header", func(t *testing.T) {
+		h := securityHeaders(SecurityHeaders{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		}))
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assertHeaders(t, res.Header(), map[string]string{"X-Frame-Options": "SAMEORIGIN"})
+	})
+}
+
+func TestServer_securityHeaders(t *testing.T) {
+	t.Run("are set on all responses when enabled", func(t *testing.T) {
+		s := New(Options{SecurityHeadersEnabled: true, SecurityHeaders: SecurityHeaders{ReferrerPolicy: "no-referrer"}})
+		s.setupRoutes()
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/nope", nil))
+		assertHeaders(t, res.Header(), map[string]string{"X-Frame-Options": "DENY", "Referrer-Policy": "no-referrer"})
+	})
+
+	t.Run("are not set when disabled", func(t *testing.T) {
+		s := New(Options{})
+		s.setupRoutes()
+
+		res := httptest.NewRecorder()
+		s.mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/healthz/live", nil))
+		if v := res.Header().Get("X-Frame-Options"); v != "" {
+			t.Fatalf("expected no X-Frame-Options header, got %q", v)
+		}
+	})
+}
+
+func assertHeaders(t *testing.T, h http.Header, expected map[string]string) {
+	t.Helper()
+
+	for name, value := range expected {
+		if v := h.Get(name); v != value {
+			t.Fatalf("expected %v header %q, got %q", name, value, v)
+		}
+	}
+}
@@ -1,2 +1,1271 @@
 // Package server contains everything for setting up and running the HTTP server.
 package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pires/go-proxyproto"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"canvas/handlers"
+)
+
+// ErrForcedShutdown is returned by Stop if the Server couldn't shut down gracefully before the context was done,
+// and open connections had to be closed forcefully.
+var ErrForcedShutdown = errors.New("server forcefully closed")
+
+// ErrInvalidOptions is returned by Start if the Options given to New don't make sense together.
+var ErrInvalidOptions = errors.New("invalid server options")
+
+// restart is a new http.Server and the listener it should serve on, handed over by Restart.
+type restart struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+type Server struct {
+	addr    net.Addr
+	addrs   []net.Addr
+	address string
+	// adminAddr, adminAddress, adminAuth, adminMux, adminPort, adminServer, and adminShutdownFirst are only set with an AdminPort.
+	adminAddr          net.Addr
+	adminAddress       string
+	adminAuth          func(http.Handler) http.Handler
+	adminMux           chi.Router
+	adminPort          int
+	adminServer        *http.Server
+	adminShutdownFirst bool
+	bindRetries        int
+	bindRetryDelay     time.Duration
+	cancelTasks        context.CancelFunc
+	// certificate is loaded from tlsCert and tlsKey on Start, and on ReloadCertificate.
+	certificate certificate
+	// conns of the main server, including the ones replaced on Restart.
+	conns *connStates
+	// config is the effective configuration from the Options, reported on the admin port.
+	config               map[string]any
+	debugGC              bool
+	disableDefaultRoutes bool
+	disableKeepAlives    bool
+	// draining is set once Stop starts shutting down, to turn away new requests.
+	draining atomic.Bool
+	handler  http.Handler
+	// healthChecks are protected by healthLock.
+	healthChecks       []handlers.HealthCheck
+	healthCheckTimeout time.Duration
+	healthLock         sync.Mutex
+	host               string
+	inFlight           atomic.Int64
+	lifecycle          *lifecycle
+	limiter            *rateLimiter
+	// listener is only set by NewWithListener.
+	listener net.Listener
+	// lock protects addr, addrs, adminAddr, address, host, port, and server, which change on Restart, as well as startedAt.
+	lock sync.RWMutex
+	log  *zap.Logger
+	// logBuffer is only set with an AdminPort and a LogBufferSize.
+	logBuffer   *logBuffer
+	maxConns    int
+	logLevel    zap.AtomicLevel
+	logLock     sync.RWMutex
+	maintenance atomic.Bool
+	// memoryExceeded is closed by watchMemory to make Start shut down.
+	memoryExceeded  chan struct{}
+	memoryThreshold int64
+	metrics         *metrics
+	mux             chi.Router
+	onShutdown      func(ctx context.Context) error
+	onShutdownOnce  sync.Once
+	onStart         func(ctx context.Context) error
+	onStopped       func(ctx context.Context) error
+	onStoppedOnce   sync.Once
+	// optionsErr is set by New for invalid Options, and returned by Start.
+	optionsErr    error
+	port          int
+	pprof         bool
+	preStop       time.Duration
+	proxyProtocol bool
+	ready         atomic.Bool
+	readMemory    func() uint64
+	release       string
+	restartLock   sync.Mutex
+	restarts      chan restart
+	server        *http.Server
+	// served counts the requests on Port with LogRequests.
+	served requestCounts
+	// shutdownTimeout is used when stopping because the context given to Start is done.
+	shutdownTimeout time.Duration
+	// startedAt is when Start began serving.
+	startedAt          time.Time
+	staticFS           fs.FS
+	staticPrefix       string
+	streamDrainTimeout time.Duration
+	streams            *streams
+	summaryOnce        sync.Once
+	tcpKeepAlive       time.Duration
+	tasks              sync.WaitGroup
+	tasksCtx           context.Context
+	tlsCert            string
+	tlsKey             string
+	unixSocket         string
+	warmup             func(ctx context.Context) error
+	warmupTimeout      time.Duration
+	workerPool         *WorkerPool
+}
+
+type Options struct {
+	// AccessLogFields are the names of the fields logged for each request with LogRequests, out of method, path, status,
+	// duration, remote_addr, client_ip, and request_id. Unknown names are ignored with a warning. Defaults to all of them.
+	AccessLogFields []string
+	// AdminPort, if non-zero, is a separate port serving only the health, metrics, and version endpoints,
+	// which are then not served on Port.
+	AdminPort int
+	// AdminPassword is the password for HTTP Basic Auth on the admin port. It's required with an AdminPort.
+	// The health probes and version on the admin port don't need it.
+	AdminPassword string
+	// AdminShutdownFirst makes Stop shut down the admin server before the main one, instead of after it.
+	AdminShutdownFirst bool
+	// AdminUser is the user for HTTP Basic Auth on the admin port. It's required with an AdminPort.
+	AdminUser string
+	// AllowedCIDRs are the IP ranges, like 10.0.0.0/8, that clients must be in. If empty, all clients are allowed.
+	AllowedCIDRs []string
+	// BasePath is a prefix like /api/v1 that all routes on Port are served under, including the built-in ones.
+	// Handlers see paths with the prefix stripped. Use AdminPort to serve the built-in routes without it.
+	BasePath string
+	// BindRetries is how many more times Start tries to bind Port if it's in use, like during a rolling restart.
+	BindRetries int
+	// BindRetryDelay is the time between bind attempts. Defaults to 1 second.
+	BindRetryDelay time.Duration
+	// CompressionEnabled turns on gzip compression of responses for clients that accept it.
+	CompressionEnabled bool
+	// CORSAllowedOrigins are the origins allowed to make cross-origin requests. Use "*" to allow all origins.
+	CORSAllowedOrigins []string
+	// DebugBodies turns on logging the headers and bodies of requests and responses on Port, for debugging.
+	// Don't use it in production, as bodies may contain personal data.
+	DebugBodies bool
+	// DebugBodiesMaxBytes is how much of each body DebugBodies logs. Defaults to 4 KB.
+	DebugBodiesMaxBytes int
+	// DebugBodiesRedact are the names of headers, JSON fields, and form fields whose values DebugBodies replaces.
+	// Defaults to Authorization, Cookie, Set-Cookie, password, secret, and token.
+	DebugBodiesRedact []string
+	// DebugGC turns on the /admin/gc endpoint on the admin port, which runs a garbage collection on POST
+	// and responds with heap stats. It's never served on Port, so it needs an AdminPort.
+	DebugGC bool
+	// DeniedCIDRs are the IP ranges clients must not be in. They take precedence over AllowedCIDRs.
+	DeniedCIDRs []string
+	// DisableDefaultRoutes leaves out the health, version, and metrics endpoints on Port, so Handler gets all paths.
+	// With an AdminPort, they're still served there.
+	DisableDefaultRoutes bool
+	// DisableKeepAlives makes the Server close connections on Port after each response instead of reusing them.
+	DisableKeepAlives bool
+	// ErrorReporter is notified of panics and errors passed to ReportError. Defaults to a no-op reporter.
+	ErrorReporter ErrorReporter
+	// H2CEnabled turns on accepting HTTP/2 without TLS (h2c) on Port, next to HTTP/1, for example behind a proxy.
+	H2CEnabled bool
+	// Handler serves all requests that don't match a built-in route, like /healthz/live.
+	// Built-in middleware still applies. If nil, unmatched requests get 404 Not Found.
+	Handler http.Handler
+	// HealthCheckTimeout is how long each check added with AddHealthCheck gets in the readiness probe.
+	// Defaults to 1 second.
+	HealthCheckTimeout time.Duration
+	// Host is the address to listen on with Port. Several can be given separated by commas, like 127.0.0.1,10.0.0.5,
+	// to serve on each of them without binding to all interfaces. The admin server only listens on the first.
+	Host string
+	// IdleTimeout for keep-alive connections. Defaults to 120 seconds.
+	IdleTimeout time.Duration
+	Log         *zap.Logger
+	// LogLevel is the level Log was built with, returned by Server.LogLevel for changing it at runtime.
+	// Defaults to a new info level, which then has no effect on Log.
+	LogLevel zap.AtomicLevel
+	// LogBufferSize is how many of the most recent log entries are kept in memory and served at /admin/logs.
+	// They're only kept and served with an AdminPort. Zero turns it off.
+	LogBufferSize int
+	// LogExcludePaths are paths whose requests aren't logged with LogRequests, unless they result in a server error,
+	// like for frequent probes. /healthz also excludes the paths below it, and a trailing * like in /debug* matches
+	// all paths starting with the rest.
+	LogExcludePaths []string
+	// LogRequests turns on logging of every request. It also counts them for the summary logged when the Server stops.
+	LogRequests bool
+	// LogSampleRate makes only every Nth successful request be logged. Requests with errors are always logged.
+	LogSampleRate int
+	// MaintenanceMode starts the Server in maintenance mode. See Server.SetMaintenanceMode.
+	MaintenanceMode bool
+	// MaxConcurrentRequests caps the number of requests handled at the same time on Port.
+	// Further requests get 503 Service Unavailable right away, except for health, metrics, and version. Zero means no limit.
+	MaxConcurrentRequests int
+	// MaxConnections caps the number of simultaneous connections on Port. Further connections wait until one closes.
+	// Zero means no limit.
+	MaxConnections int
+	// MaxHeaderBytes is the largest size of request headers allowed, including the request line.
+	// Larger headers get 431 Request Header Fields Too Large. Defaults to 1 MB.
+	MaxHeaderBytes int
+	// MaxRequestBodyBytes is the largest request body allowed. Defaults to 1 MB. See MaxBodyBytes for raising it per route.
+	MaxRequestBodyBytes int64
+	// MethodNotAllowedHandler responds to requests on Port for paths with routes, but not for the request method.
+	// The Allow header is set before it's called. Defaults to a 405 Method Not Allowed response from WriteError.
+	MethodNotAllowedHandler http.Handler
+	// Middlewares are applied to all requests on Port, after the built-in middleware and in order,
+	// so the first one in the slice is the outermost and sees the request first.
+	Middlewares []func(http.Handler) http.Handler
+	// MemoryShutdownThreshold is the memory use in bytes above which the Server reports not ready and shuts down gracefully,
+	// before the OOM killer ends it abruptly. Start then returns ErrMemoryThreshold. Set it somewhat below the container
+	// memory limit and GOMEMLIMIT. Zero turns it off.
+	MemoryShutdownThreshold int64
+	// MetricsEnabled turns on request instrumentation and the /metrics endpoint.
+	MetricsEnabled bool
+	// NotFoundHandler responds to requests on Port that match no route, if there's no Handler.
+	// Defaults to a 404 Not Found response from WriteError.
+	NotFoundHandler http.Handler
+	Port            int
+	// OnShutdown is called once when the Server starts stopping, before it stops reporting ready and draining.
+	// An error is returned by Stop, but doesn't prevent the shutdown.
+	OnShutdown func(ctx context.Context) error
+	// OnStart is called with the context given to Start after the Server is bound, but before it serves requests.
+	// An error aborts the start, and is returned by Start.
+	OnStart func(ctx context.Context) error
+	// OnStopped is called once after the Server has shut down and its background tasks have stopped.
+	// An error is returned by Stop.
+	OnStopped func(ctx context.Context) error
+	// PanicMode is what happens on panics in handlers. With "recover", they're logged and reported, and answered
+	// with 500 Internal Server Error. With "crash", they're logged, and the log is synced, before the process crashes,
+	// like for an orchestrator to restart it and keep a core dump. Defaults to "recover".
+	PanicMode string
+	// PprofEnabled turns on the profiling endpoints under /debug/pprof/ on the admin port.
+	// They're never served on Port, so they need an AdminPort.
+	PprofEnabled bool
+	// PreStopDelay is how long Stop keeps serving while reporting not ready, before shutting down.
+	// This gives load balancers time to stop routing new traffic to the Server. It counts towards the Stop timeout.
+	PreStopDelay time.Duration
+	// ProxyProtocol makes the Server expect a PROXY protocol header (v1 or v2) on every connection on Port,
+	// like from a load balancer, so the request RemoteAddr is the real client address.
+	ProxyProtocol bool
+	// RateBurst is the number of requests a client can make in a burst. Defaults to RateLimit, rounded up.
+	RateBurst int
+	// RateLimit is the number of requests per second allowed per client IP. Zero means no limit.
+	RateLimit float64
+	// ReadTimeout for reading a whole request, including the body. Defaults to 5 seconds.
+	ReadTimeout time.Duration
+	// RedirectHTTPToHTTPS redirects plain HTTP requests on Port to HTTPS with 301 Moved Permanently,
+	// except for health, metrics, and version. Requests from TrustedProxies count as HTTPS with an X-Forwarded-Proto of https,
+	// for when a proxy terminates TLS.
+	RedirectHTTPToHTTPS bool
+	// RequestTimeout is how long handlers get before the request is answered with 503 Service Unavailable.
+	// Zero means no timeout. Use the RequestTimeout middleware for changing it per route.
+	RequestTimeout time.Duration
+	// RouteConcurrencyLimits caps the number of requests handled at the same time per route pattern, like /reports/{id},
+	// so slow routes can't take up all of MaxConcurrentRequests. Further requests for a saturated route
+	// get 503 Service Unavailable right away, while other routes are not affected.
+	RouteConcurrencyLimits map[string]int
+	// SecurityHeaders overrides the values of the headers set with SecurityHeadersEnabled.
+	SecurityHeaders SecurityHeaders
+	// SecurityHeadersEnabled turns on setting Content-Security-Policy, X-Content-Type-Options, X-Frame-Options,
+	// and Referrer-Policy on all responses.
+	SecurityHeadersEnabled bool
+	// ShowErrorDetails makes WriteError include the error message in server error responses,
+	// which are otherwise only the status text. Only use it in development.
+	ShowErrorDetails bool
+	// ShutdownTimeout for the graceful shutdown after the context given to Start is done. Defaults to 15 seconds.
+	ShutdownTimeout time.Duration
+	// SlowRequestThreshold is the duration above which requests are also logged as warnings, with LogRequests.
+	// Zero turns it off.
+	SlowRequestThreshold time.Duration
+	// Release is the version of the running build, added to all logs.
+	Release string
+	// StaticFS are files served on Port under StaticPrefix, like a single-page app embedded with embed.FS.
+	// Paths under the prefix that aren't files get index.html, so the app can handle its own routes.
+	StaticFS fs.FS
+	// StaticPrefix is the path StaticFS is served under, like /app. Defaults to the root, where the files take
+	// the place of Handler for GET requests.
+	StaticPrefix string
+	// StreamDrainTimeout is how long Stop gives streams started with StartStream, like WebSockets, to end
+	// after the other requests are drained, before closing their connections. Defaults to 30 seconds.
+	StreamDrainTimeout time.Duration
+	// TCPKeepAlive is the keep-alive period for TCP connections accepted on Port.
+	// Zero keeps the default of the operating system and the Go runtime.
+	TCPKeepAlive time.Duration
+	// TLSCertFile is the path to a PEM-encoded certificate. If set together with TLSKeyFile, the Server serves HTTPS.
+	// Both files are read again on ReloadCertificate.
+	TLSCertFile string
+	// TLSKeyFile is the path to the PEM-encoded private key for TLSCertFile. Setting only one of them is invalid.
+	TLSKeyFile string
+	// TracerProvider turns on tracing of requests on Port, with a server span for each, continuing traces from the
+	// traceparent header. Handlers get the span in the request context. See InjectTraceContext.
+	TracerProvider trace.TracerProvider
+	// TrustedProxies are the IP ranges of proxies in front of the Server, like 10.0.0.0/8.
+	// Only for requests from them, the client IP is taken from the X-Forwarded-For or X-Real-IP header.
+	// See ClientIP, which is what the IP filter, rate limiting, and request logging use.
+	TrustedProxies []string
+	// TrustedProxyHops is how many addresses of proxies are skipped from the right of X-Forwarded-For
+	// to find the client IP, for requests from TrustedProxies. Use it when the addresses of proxies in a chain
+	// aren't known, like for a CDN in front of a load balancer. Zero means skipping the addresses in TrustedProxies instead.
+	TrustedProxyHops int
+	// UnixSocket is the path of a Unix domain socket to listen on instead of Host and Port.
+	UnixSocket string
+	// Warmup is called in the background once the Server serves requests, like for priming caches.
+	// The readiness probe only succeeds after it returns without an error. Errors are logged, and keep the Server not ready.
+	Warmup func(ctx context.Context) error
+	// WarmupTimeout is how long Warmup gets. Defaults to 1 minute.
+	WarmupTimeout time.Duration
+	// WorkerPoolSize is how much work submitted to the Server's WorkerPool runs at the same time.
+	// Defaults to GOMAXPROCS, which suits CPU-bound work.
+	WorkerPoolSize int
+	// WriteTimeout for writing a response. Defaults to 10 seconds.
+	WriteTimeout time.Duration
+}
+
+// New Server configured by the given Options, or functional options like WithPort, applied in order.
+// A nil Log is replaced by a no-op logger, and zero timeouts are replaced by their defaults.
+func New(options ...Option) *Server {
+	var opts Options
+	for _, o := range options {
+		o.apply(&opts)
+	}
+
+	if opts.Log == nil {
+		opts.Log = zap.NewNop()
+	}
+	if opts.LogLevel == (zap.AtomicLevel{}) {
+		opts.LogLevel = zap.NewAtomicLevel()
+	}
+	if opts.ErrorReporter == nil {
+		opts.ErrorReporter = nopErrorReporter{}
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = 5 * time.Second
+	}
+	if opts.WriteTimeout == 0 {
+		opts.WriteTimeout = 10 * time.Second
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = 120 * time.Second
+	}
+	if opts.MaxHeaderBytes == 0 {
+		opts.MaxHeaderBytes = http.DefaultMaxHeaderBytes
+	}
+	if opts.MaxRequestBodyBytes == 0 {
+		opts.MaxRequestBodyBytes = defaultMaxRequestBodyBytes
+	}
+	if opts.BindRetryDelay == 0 {
+		opts.BindRetryDelay = time.Second
+	}
+	if opts.ShutdownTimeout == 0 {
+		opts.ShutdownTimeout = 15 * time.Second
+	}
+	if opts.StreamDrainTimeout == 0 {
+		opts.StreamDrainTimeout = 30 * time.Second
+	}
+	if opts.DebugBodiesMaxBytes == 0 {
+		opts.DebugBodiesMaxBytes = defaultDebugBodiesMaxBytes
+	}
+	if opts.DebugBodiesRedact == nil {
+		opts.DebugBodiesRedact = defaultDebugBodiesRedact
+	}
+	if opts.PanicMode == "" {
+		opts.PanicMode = panicModeRecover
+	}
+	if opts.WarmupTimeout == 0 {
+		opts.WarmupTimeout = time.Minute
+	}
+	if opts.WorkerPoolSize == 0 {
+		opts.WorkerPoolSize = runtime.GOMAXPROCS(0)
+	}
+
+	address := net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	mux := chi.NewMux()
+
+	var handler http.Handler = mux
+	if basePath := strings.TrimSuffix(opts.BasePath, "/"); basePath != "" {
+		handler = http.StripPrefix(basePath, mux)
+	}
+	crash := opts.PanicMode == panicModeCrash
+	if crash {
+		handler = crashOnPanic(handler)
+	}
+
+	s := &Server{
+		address:              address,
+		bindRetries:          opts.BindRetries,
+		bindRetryDelay:       opts.BindRetryDelay,
+		conns:                newConnStates(),
+		config:               effectiveConfig(opts),
+		debugGC:              opts.DebugGC,
+		disableDefaultRoutes: opts.DisableDefaultRoutes,
+		disableKeepAlives:    opts.DisableKeepAlives,
+		handler:              opts.Handler,
+		healthCheckTimeout:   opts.HealthCheckTimeout,
+		host:                 opts.Host,
+		lifecycle:            newLifecycle(),
+		logLevel:             opts.LogLevel,
+		maxConns:             opts.MaxConnections,
+		memoryExceeded:       make(chan struct{}),
+		memoryThreshold:      opts.MemoryShutdownThreshold,
+		mux:                  mux,
+		onShutdown:           opts.OnShutdown,
+		onStart:              opts.OnStart,
+		onStopped:            opts.OnStopped,
+		port:                 opts.Port,
+		pprof:                opts.PprofEnabled,
+		preStop:              opts.PreStopDelay,
+		proxyProtocol:        opts.ProxyProtocol,
+		readMemory:           readMemory,
+		release:              opts.Release,
+		restarts:             make(chan restart, 1),
+		server: &http.Server{
+			Addr:              address,
+			Handler:           handler,
+			ReadTimeout:       opts.ReadTimeout,
+			ReadHeaderTimeout: opts.ReadTimeout,
+			WriteTimeout:      opts.WriteTimeout,
+			IdleTimeout:       opts.IdleTimeout,
+			MaxHeaderBytes:    opts.MaxHeaderBytes,
+		},
+		shutdownTimeout:    opts.ShutdownTimeout,
+		staticFS:           opts.StaticFS,
+		staticPrefix:       opts.StaticPrefix,
+		streamDrainTimeout: opts.StreamDrainTimeout,
+		streams:            newStreams(),
+		tcpKeepAlive:       opts.TCPKeepAlive,
+		tlsCert:            opts.TLSCertFile,
+		tlsKey:             opts.TLSKeyFile,
+		unixSocket:         opts.UnixSocket,
+		warmup:             opts.Warmup,
+		warmupTimeout:      opts.WarmupTimeout,
+		workerPool:         NewWorkerPool(opts.WorkerPoolSize),
+	}
+
+	if opts.AdminPort != 0 && opts.LogBufferSize > 0 {
+		s.logBuffer = newLogBuffer(opts.LogBufferSize)
+	}
+	s.SetLogger(opts.Log)
+
+	s.server.SetKeepAlivesEnabled(!opts.DisableKeepAlives)
+	s.server.TLSConfig = s.tlsConfig()
+	s.server.ConnState = s.conns.track
+	s.server.ConnContext = withConn
+
+	if opts.H2CEnabled {
+		s.server.Protocols = new(http.Protocols)
+		s.server.Protocols.SetHTTP1(true)
+		s.server.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	if opts.PanicMode != panicModeRecover && !crash {
+		s.optionsErr = fmt.Errorf("%w: unknown panic mode %q, expected recover or crash", ErrInvalidOptions, opts.PanicMode)
+	}
+
+	if opts.AdminPort != 0 {
+		s.adminAddress = net.JoinHostPort(opts.Host, strconv.Itoa(opts.AdminPort))
+		s.adminMux = chi.NewMux()
+		s.adminMux.Use(requestID, withRequestLogger(s.logger), withErrorReporting(opts.ErrorReporter, opts.Release),
+			withErrorWriting(s.logger, opts.ShowErrorDetails), recoverPanics(s.logger, crash), answerHead)
+		s.adminAuth = requireBasicAuth(opts.AdminUser, opts.AdminPassword)
+		setupErrorHandlers(s.adminMux, nil, nil)
+		if opts.AdminUser == "" || opts.AdminPassword == "" {
+			// Refuse to expose the admin endpoints without protection.
+			s.optionsErr = fmt.Errorf("%w: AdminUser and AdminPassword are required with an AdminPort", ErrInvalidOptions)
+		}
+		s.adminPort = opts.AdminPort
+		s.adminShutdownFirst = opts.AdminShutdownFirst
+		var adminHandler http.Handler = s.adminMux
+		if crash {
+			adminHandler = crashOnPanic(adminHandler)
+		}
+		s.adminServer = &http.Server{
+			Addr:              s.adminAddress,
+			Handler:           adminHandler,
+			ReadTimeout:       opts.ReadTimeout,
+			ReadHeaderTimeout: opts.ReadTimeout,
+			WriteTimeout:      opts.WriteTimeout,
+			IdleTimeout:       opts.IdleTimeout,
+			MaxHeaderBytes:    opts.MaxHeaderBytes,
+		}
+	}
+
+	s.tasksCtx, s.cancelTasks = context.WithCancel(context.Background())
+
+	trustedProxies, err := parseCIDRs(opts.TrustedProxies)
+	if err != nil {
+		s.optionsErr = fmt.Errorf("%w: trusted proxies: %w", ErrInvalidOptions, err)
+	}
+	mux.Use(recordResponses, countInFlight(&s.inFlight), requestID, withRequestLogger(s.logger), withClientIP(trustedProxies, opts.TrustedProxyHops),
+		withErrorReporting(opts.ErrorReporter, opts.Release), withErrorWriting(s.logger, opts.ShowErrorDetails), withWorkerPool(s.workerPool), withStreams(s.streams))
+	if opts.SecurityHeadersEnabled {
+		mux.Use(securityHeaders(opts.SecurityHeaders))
+	}
+	if opts.TracerProvider != nil {
+		mux.Use(traceRequests(opts.TracerProvider))
+	}
+	if opts.MetricsEnabled {
+		s.metrics = newMetrics()
+		mux.Use(s.metrics.middleware)
+	}
+	if opts.LogRequests {
+		fields, unknown := accessLogFieldSet(opts.AccessLogFields)
+		if len(unknown) > 0 {
+			s.logger().Warn("Unknown access log fields, ignoring", zap.Strings("names", unknown))
+		}
+		mux.Use(logRequests(s.logger, requestLogOptions{
+			Counts:        &s.served,
+			ExcludePaths:  opts.LogExcludePaths,
+			Fields:        fields,
+			SampleRate:    opts.LogSampleRate,
+			SlowThreshold: opts.SlowRequestThreshold,
+		}))
+	}
+	if opts.DebugBodies {
+		mux.Use(logBodies(s.logger, bodyLogOptions{MaxBytes: opts.DebugBodiesMaxBytes, Redact: opts.DebugBodiesRedact}))
+	}
+	mux.Use(logClientDisconnects(s.logger), recoverPanics(s.logger, crash))
+	if opts.RedirectHTTPToHTTPS {
+		mux.Use(redirectToHTTPS(trustedProxies, s.isOperational))
+	}
+	if len(opts.AllowedCIDRs) > 0 || len(opts.DeniedCIDRs) > 0 {
+		if f, err := newIPFilter(opts.AllowedCIDRs, opts.DeniedCIDRs); err != nil {
+			s.optionsErr = fmt.Errorf("%w: %w", ErrInvalidOptions, err)
+		} else {
+			mux.Use(f.middleware)
+		}
+	}
+	if opts.RateLimit > 0 {
+		s.limiter = newRateLimiter(opts.RateLimit, opts.RateBurst)
+		mux.Use(s.limiter.middleware)
+	}
+	s.maintenance.Store(opts.MaintenanceMode)
+	mux.Use(inMaintenance(&s.maintenance, s.isOperational))
+	mux.Use(rejectWhileDraining(&s.draining, s.isOperational))
+	if len(opts.RouteConcurrencyLimits) > 0 {
+		for route, max := range opts.RouteConcurrencyLimits {
+			if max < 1 {
+				s.optionsErr = fmt.Errorf("%w: concurrency limit %v for route %v, expected at least 1", ErrInvalidOptions, max, route)
+			}
+		}
+		mux.Use(limitRouteConcurrency(mux, opts.RouteConcurrencyLimits))
+	}
+	if opts.MaxConcurrentRequests > 0 {
+		mux.Use(limitConcurrency(opts.MaxConcurrentRequests, s.isOperational))
+	}
+	if len(opts.CORSAllowedOrigins) > 0 {
+		mux.Use(cors(opts.CORSAllowedOrigins))
+	}
+	mux.Use(limitBody(opts.MaxRequestBodyBytes))
+	if opts.RequestTimeout > 0 {
+		mux.Use(timeoutRequests(opts.RequestTimeout))
+	}
+	if opts.CompressionEnabled {
+		mux.Use(compress)
+	}
+	mux.Use(answerHead)
+	mux.Use(opts.Middlewares...)
+	setupErrorHandlers(mux, opts.NotFoundHandler, opts.MethodNotAllowedHandler)
+
+	return s
+}
+
+// NewWithListener is like New, but the Server serves on l instead of binding to Host and Port or UnixSocket.
+// This is useful for tests, which can pass a listener from httptest or an in-memory one,
+// and for serving on a socket inherited from the parent process.
+// The admin server, if configured, still binds to its own port.
+func NewWithListener(opts Options, l net.Listener) *Server {
+	s := New(opts)
+	s.listener = l
+	return s
+}
+
+// Start the Server by setting up routes and listening for HTTP requests on the given address,
+// and on the admin address if an AdminPort is configured. The admin server is bound and serving before the main one,
+// and the Server only reports ready after both are.
+// It blocks until the Server is stopped, either through Stop or by cancelling ctx,
+// which shuts the Server down gracefully within the ShutdownTimeout. It returns nil after a clean shutdown.
+// Cancelling ctx while still starting, like during bind retries, aborts the start and also returns nil.
+func (s *Server) Start(ctx context.Context) error {
+	s.lifecycle.set(StateStarting)
+	if err := s.start(ctx); err != nil {
+		s.lifecycle.set(StateStopped)
+		if errors.Is(err, errStartCancelled) {
+			s.logger().Info("Start cancelled before serving")
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// validate returns an error wrapping ErrInvalidOptions if the Options given to New don't make sense together.
+func (s *Server) validate() error {
+	if s.optionsErr != nil {
+		return s.optionsErr
+	}
+	if (s.tlsCert == "") != (s.tlsKey == "") {
+		return fmt.Errorf("%w: TLSCertFile and TLSKeyFile must be set together", ErrInvalidOptions)
+	}
+	return nil
+}
+
+// errStartCancelled is returned by start if ctx is done before the Server serves, which Start treats as a clean stop.
+var errStartCancelled = errors.New("start cancelled")
+
+// start does the work of Start, except for setting the final State if it fails.
+func (s *Server) start(ctx context.Context) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return errStartCancelled
+	}
+
+	if s.tlsCert != "" {
+		if err := s.certificate.load(s.tlsCert, s.tlsKey); err != nil {
+			return fmt.Errorf("error loading TLS certificate: %w", err)
+		}
+	}
+
+	s.setupRoutes()
+
+	// The admin server binds and serves first, so health probes get answers once the main listener accepts requests.
+	var adminL net.Listener
+	var err error
+	if s.adminServer != nil {
+		adminL, err = s.listen(ctx, splitHosts(s.host)[0], s.adminPort)
+		if err != nil {
+			return startError(ctx, err)
+		}
+	}
+
+	var ls []net.Listener
+	switch {
+	case s.listener != nil:
+		ls = []net.Listener{s.listener}
+	case s.unixSocket != "":
+		var l net.Listener
+		l, err = s.listenUnix(s.unixSocket)
+		ls = []net.Listener{l}
+	default:
+		ls, err = s.listenAll(ctx, splitHosts(s.host), s.port)
+	}
+	if err != nil {
+		if adminL != nil {
+			_ = adminL.Close()
+		}
+		return startError(ctx, err)
+	}
+
+	for i := range ls {
+		ls[i] = s.wrapListener(ls[i])
+	}
+	l := ls[0]
+
+	s.lock.Lock()
+	s.addr = l.Addr()
+	s.addrs = addrsOf(ls)
+	s.startedAt = time.Now()
+	if adminL != nil {
+		s.adminAddr = adminL.Addr()
+	}
+	s.lock.Unlock()
+
+	hosts := splitHosts(s.host)
+	for i, l := range ls {
+		switch addr := l.Addr().(type) {
+		case *net.TCPAddr:
+			s.logger().Info("Server listening", zap.String("host", hosts[i]), zap.Int("port", addr.Port))
+		case *net.UnixAddr:
+			s.logger().Info("Server listening", zap.String("socket", addr.Name))
+		default:
+			s.logger().Info("Server listening", zap.Stringer("address", addr))
+		}
+	}
+	if adminL != nil {
+		s.logger().Info("Admin server listening", zap.String("host", hosts[0]), zap.Int("port", adminL.Addr().(*net.TCPAddr).Port))
+	}
+
+	if s.onStart != nil {
+		if err := s.onStart(ctx); err != nil {
+			closeAll(ls)
+			if adminL != nil {
+				_ = adminL.Close()
+			}
+			return startError(ctx, fmt.Errorf("error in start hook: %w", err))
+		}
+	}
+
+	// Binding and the start hook may have taken a while, so don't start serving if ctx is done by now.
+	if ctx.Err() != nil {
+		closeAll(ls)
+		if adminL != nil {
+			_ = adminL.Close()
+		}
+		return errStartCancelled
+	}
+
+	if s.limiter != nil {
+		s.Go(func(ctx context.Context) error {
+			s.limiter.sweepUntilDone(ctx)
+			return nil
+		})
+	}
+	if s.memoryThreshold > 0 {
+		s.Go(func(ctx context.Context) error {
+			s.watchMemory(ctx)
+			return nil
+		})
+	}
+
+	serveErrs := make(chan error, len(ls)+1)
+	running := len(ls)
+	if adminL != nil {
+		running++
+		accepting := make(chan struct{})
+		go func() {
+			serveErrs <- s.adminServer.Serve(&acceptNotifyingListener{Listener: adminL, accepting: accepting})
+		}()
+		select {
+		case <-accepting:
+		case serveErr := <-serveErrs:
+			// The admin server only stops before accepting if it's closed while starting.
+			_ = adminL.Close()
+			closeAll(ls)
+			return checkServeError(serveErr)
+		}
+	}
+
+	go func() {
+		serveErrs <- s.serveMain(l)
+	}()
+	// Restart only supports a single host, so additional listeners are served without handing over.
+	for _, l := range ls[1:] {
+		go func() {
+			serveErrs <- s.serve(s.currentServer(), l)
+		}()
+	}
+
+	if s.warmup == nil {
+		s.ready.Store(true)
+	}
+	s.lifecycle.set(StateRunning)
+	if s.warmup != nil {
+		s.Go(s.warmUp)
+	}
+
+	select {
+	case serveErr := <-serveErrs:
+		running--
+		if err = checkServeError(serveErr); err != nil {
+			// One listener failed, so take the other one down with it.
+			_ = s.stopWithTimeout()
+		}
+	case <-ctx.Done():
+		err = s.stopWithTimeout()
+	case <-s.memoryExceeded:
+		if err = s.stopWithTimeout(); err == nil {
+			err = ErrMemoryThreshold
+		}
+	}
+
+	for ; running > 0; running-- {
+		if serveErr := checkServeError(<-serveErrs); serveErr != nil && err == nil {
+			err = serveErr
+		}
+	}
+	return err
+}
+
+// startError returns errStartCancelled instead of err if ctx is done, which likely caused err.
+func startError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return errStartCancelled
+	}
+	return err
+}
+
+// splitHosts from a comma-separated list. There's always at least one, which may be empty for all interfaces.
+func splitHosts(hosts string) []string {
+	var split []string
+	for _, host := range strings.Split(hosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			split = append(split, host)
+		}
+	}
+	if len(split) == 0 {
+		return []string{""}
+	}
+	return split
+}
+
+// listenAll on port on each of the hosts. If binding to one fails, the ones bound before are closed again.
+func (s *Server) listenAll(ctx context.Context, hosts []string, port int) ([]net.Listener, error) {
+	var ls []net.Listener
+	for _, host := range hosts {
+		l, err := s.listen(ctx, host, port)
+		if err != nil {
+			closeAll(ls)
+			return nil, err
+		}
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
+
+func closeAll(ls []net.Listener) {
+	for _, l := range ls {
+		_ = l.Close()
+	}
+}
+
+func addrsOf(ls []net.Listener) []net.Addr {
+	addrs := make([]net.Addr, len(ls))
+	for i, l := range ls {
+		addrs[i] = l.Addr()
+	}
+	return addrs
+}
+
+// listen on the TCP host and port, logging them if binding fails.
+// If the port is in use, it retries up to BindRetries times, unless ctx is done first, which also aborts binding.
+func (s *Server) listen(ctx context.Context, host string, port int) (net.Listener, error) {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	// The context aborts resolving the host, which may be slow.
+	var lc net.ListenConfig
+	l, err := lc.Listen(ctx, "tcp", address)
+	for attempt := 1; errors.Is(err, syscall.EADDRINUSE) && attempt <= s.bindRetries; attempt++ {
+		s.logger().Info("Port in use, retrying", zap.String("host", host), zap.Int("port", port),
+			zap.Int("attempt", attempt), zap.Duration("delay", s.bindRetryDelay))
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error binding to %v: %w", address, ctx.Err())
+		case <-time.After(s.bindRetryDelay):
+		}
+		l, err = lc.Listen(ctx, "tcp", address)
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("error binding to %v: %w", address, ctx.Err())
+	}
+	if err != nil {
+		s.logger().Error("Error binding", zap.String("host", host), zap.Int("port", port), zap.Error(err))
+		return nil, fmt.Errorf("error binding to %v: %w", address, err)
+	}
+	return l, nil
+}
+
+// listenUnix on the Unix domain socket at path, removing a stale socket file left behind by a previous run.
+// The socket file is removed again when the listener is closed.
+func (s *Server) listenUnix(path string) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		s.logger().Info("Removing stale socket", zap.String("socket", path))
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("error removing stale socket %v: %w", path, err)
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		s.logger().Error("Error binding", zap.String("socket", path), zap.Error(err))
+		return nil, fmt.Errorf("error binding to %v: %w", path, err)
+	}
+	return l, nil
+}
+
+// wrapListener l for the TCP keep-alive period, the PROXY protocol, and the connection limit, if configured.
+func (s *Server) wrapListener(l net.Listener) net.Listener {
+	if s.tcpKeepAlive > 0 {
+		l = &keepAliveListener{Listener: l, period: s.tcpKeepAlive}
+	}
+	if s.proxyProtocol {
+		l = &proxyproto.Listener{Listener: l}
+	}
+	if s.maxConns > 0 {
+		l = limitConnections(l, s.maxConns, s.logger)
+	}
+	return l
+}
+
+// acceptNotifyingListener closes accepting when Accept is first called, which is when a server starts serving on it.
+type acceptNotifyingListener struct {
+	net.Listener
+	accepting chan struct{}
+	once      sync.Once
+}
+
+func (l *acceptNotifyingListener) Accept() (net.Conn, error) {
+	l.once.Do(func() {
+		close(l.accepting)
+	})
+	return l.Listener.Accept()
+}
+
+// checkServeError returns nil if err is from the server being closed on purpose.
+func checkServeError(err error) error {
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error starting server: %w", err)
+	}
+	return nil
+}
+
+// Addr returns the address the Server is listening on, or nil if it hasn't started listening yet.
+// This is the actually bound address, so it's useful when binding to port 0.
+// With several hosts, it's the address for the first one.
+func (s *Server) Addr() net.Addr {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.addr
+}
+
+// Addrs returns all addresses the Server is listening on, one for each host, or nil if it hasn't started listening yet.
+func (s *Server) Addrs() []net.Addr {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.addrs
+}
+
+// AdminAddr returns the address the admin server is listening on,
+// or nil if it hasn't started listening yet or there's no AdminPort configured.
+func (s *Server) AdminAddr() net.Addr {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.adminAddr
+}
+
+// serveMain serves the main http.Server on l, and keeps serving the replacements handed over by Restart.
+func (s *Server) serveMain(l net.Listener) error {
+	hs := s.currentServer()
+	for {
+		err := s.serve(hs, l)
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+
+		select {
+		case r := <-s.restarts:
+			hs, l = r.server, r.listener
+		default:
+			return err
+		}
+	}
+}
+
+// tlsConfig for serving HTTPS, or nil without a certificate and key.
+// The certificate is looked up on each handshake, so ReloadCertificate takes effect without restarting.
+func (s *Server) tlsConfig() *tls.Config {
+	if s.tlsCert == "" || s.tlsKey == "" {
+		return nil
+	}
+	return &tls.Config{GetCertificate: s.certificate.get}
+}
+
+// serve HTTPS with hs on l if a certificate and key are configured, plain HTTP otherwise.
+func (s *Server) serve(hs *http.Server, l net.Listener) error {
+	if s.tlsCert != "" && s.tlsKey != "" {
+		return hs.ServeTLS(l, "", "")
+	}
+	return hs.Serve(l)
+}
+
+// currentServer returns the main http.Server, which is replaced on Restart.
+func (s *Server) currentServer() *http.Server {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.server
+}
+
+// Restart the running Server on the Host and Port from opts, without stopping the process.
+// The new address is bound first, so if that fails, an error is returned and the Server keeps serving where it was.
+// Otherwise the new listener takes requests, while the old one is shut down gracefully within the ShutdownTimeout.
+// Only Host and Port are used. Routes, middleware, and other settings stay as they are.
+func (s *Server) Restart(opts Options) error {
+	s.restartLock.Lock()
+	defer s.restartLock.Unlock()
+
+	if s.State() != StateRunning {
+		return errors.New("error restarting server: not running")
+	}
+	if len(s.Addrs()) > 1 || len(splitHosts(opts.Host)) > 1 {
+		return fmt.Errorf("%w: Restart doesn't support multiple hosts", ErrInvalidOptions)
+	}
+
+	l, err := s.listen(context.Background(), opts.Host, opts.Port)
+	if err != nil {
+		return err
+	}
+	l = s.wrapListener(l)
+
+	s.lock.Lock()
+	old := s.server
+	s.server = &http.Server{
+		Addr:              l.Addr().String(),
+		Handler:           old.Handler,
+		ReadTimeout:       old.ReadTimeout,
+		ReadHeaderTimeout: old.ReadHeaderTimeout,
+		WriteTimeout:      old.WriteTimeout,
+		IdleTimeout:       old.IdleTimeout,
+		MaxHeaderBytes:    old.MaxHeaderBytes,
+		Protocols:         old.Protocols,
+		TLSConfig:         s.tlsConfig(),
+		ConnState:         old.ConnState,
+		ConnContext:       old.ConnContext,
+	}
+	s.server.SetKeepAlivesEnabled(!s.disableKeepAlives)
+	s.addr = l.Addr()
+	s.addrs = []net.Addr{l.Addr()}
+	s.address = net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port))
+	s.host = opts.Host
+	s.port = opts.Port
+	s.restarts <- restart{listener: l, server: s.server}
+	s.lock.Unlock()
+
+	s.logger().Info("Server restarted", zap.String("host", opts.Host), zap.Int("port", l.Addr().(*net.TCPAddr).Port))
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	return s.shutdown(ctx, old)
+}
+
+// Stop the Server gracefully, waiting for in-flight requests until ctx is done.
+// If that happens first, remaining connections are closed and ErrForcedShutdown is returned,
+// also wrapping the context error, like context.DeadlineExceeded.
+// Streams started with StartStream get their own budget of the StreamDrainTimeout after the other requests.
+// With a PreStopDelay, the Server first reports not ready and keeps serving for the delay.
+// Then new requests get 503 Service Unavailable with Connection: close, while the ones in flight finish.
+// The admin server, if any, is shut down after the main one has drained, so its health checks keep responding,
+// or before it with AdminShutdownFirst. Once shut down, it logs a summary of the requests served.
+func (s *Server) Stop(ctx context.Context) error {
+	s.logger().Info("Stopping")
+	var hookErr error
+	if s.onShutdown != nil {
+		s.onShutdownOnce.Do(func() {
+			if hookErr = s.onShutdown(ctx); hookErr != nil {
+				s.logger().Error("Error in shutdown hook", zap.Error(hookErr))
+				hookErr = fmt.Errorf("error in shutdown hook: %w", hookErr)
+			}
+		})
+	}
+
+	s.ready.Store(false)
+	s.lifecycle.set(StateDraining)
+	defer s.lifecycle.set(StateStopped)
+
+	if s.preStop > 0 {
+		s.logger().Info("Draining before shutdown", zap.Duration("delay", s.preStop))
+		select {
+		case <-ctx.Done():
+		case <-time.After(s.preStop):
+		}
+	}
+
+	// New requests are turned away from here on, while the ones in flight are drained.
+	s.draining.Store(true)
+
+	// Idle keep-alive connections are closed right away, while the others are drained.
+	idle, active := s.conns.count()
+	s.logger().Info("Shutting down", zap.Int64("in_flight", s.inFlight.Load()),
+		zap.Int("idle_connections", idle), zap.Int("active_connections", active))
+	logCtx, stopLogging := context.WithCancel(ctx)
+	go s.logInFlight(logCtx)
+	defer stopLogging()
+
+	servers := []*http.Server{s.currentServer()}
+	if s.adminServer != nil {
+		if s.adminShutdownFirst {
+			servers = []*http.Server{s.adminServer, servers[0]}
+		} else {
+			servers = append(servers, s.adminServer)
+		}
+	}
+
+	// Streams don't count against ctx, so the steps after them may use what's left of the streams' budget.
+	rest := ctx
+	var err error
+	for _, hs := range servers {
+		var shutdownErr error
+		if hs == s.adminServer {
+			shutdownErr = s.shutdown(rest, hs)
+		} else {
+			var deadline time.Time
+			deadline, shutdownErr = s.shutdownWithStreams(ctx, hs)
+			if current, ok := ctx.Deadline(); ok && deadline.After(current) {
+				var cancel context.CancelFunc
+				rest, cancel = context.WithDeadline(context.WithoutCancel(ctx), deadline)
+				defer cancel()
+			}
+		}
+		if err == nil {
+			err = shutdownErr
+		}
+	}
+
+	if tasksErr := s.stopTasks(rest); err == nil {
+		err = tasksErr
+	}
+	if err == nil {
+		err = hookErr
+	}
+	s.summaryOnce.Do(s.logSummary)
+
+	if s.onStopped != nil {
+		s.onStoppedOnce.Do(func() {
+			if stoppedErr := s.onStopped(ctx); stoppedErr != nil {
+				s.logger().Error("Error in stopped hook", zap.Error(stoppedErr))
+				if err == nil {
+					err = fmt.Errorf("error in stopped hook: %w", stoppedErr)
+				}
+			}
+		})
+	}
+	return err
+}
+
+// logSummary logs the totals of requests served, and how long the Server was up.
+// It's called once by Stop or Close, whichever finishes first.
+func (s *Server) logSummary() {
+	s.lock.RLock()
+	startedAt := s.startedAt
+	s.lock.RUnlock()
+
+	var uptime time.Duration
+	if !startedAt.IsZero() {
+		uptime = time.Since(startedAt)
+	}
+	s.logger().Info("Served requests",
+		zap.Uint64("total", s.served.total.Load()),
+		zap.Uint64("status_2xx", s.served.status2xx.Load()),
+		zap.Uint64("status_4xx", s.served.status4xx.Load()),
+		zap.Uint64("status_5xx", s.served.status5xx.Load()),
+		zap.Duration("uptime", uptime))
+}
+
+// Close the Server immediately, dropping in-flight requests and open connections instead of waiting like Stop.
+// Background tasks are cancelled, but not waited for. It's safe to call during or after Stop, and cuts Stop short.
+func (s *Server) Close() error {
+	s.logger().Info("Closing")
+	s.ready.Store(false)
+	defer s.lifecycle.set(StateStopped)
+
+	err := s.currentServer().Close()
+	s.streams.close()
+	if s.adminServer != nil {
+		if adminErr := s.adminServer.Close(); err == nil {
+			err = adminErr
+		}
+	}
+	s.cancelTasks()
+	s.summaryOnce.Do(s.logSummary)
+
+	if err != nil {
+		return fmt.Errorf("error closing server: %w", err)
+	}
+	return nil
+}
+
+// Go runs task in the background until the Server stops.
+// Stop cancels the context given to task, and waits for it to return. Errors from task are logged.
+func (s *Server) Go(task func(ctx context.Context) error) {
+	s.tasks.Add(1)
+	go func() {
+		defer s.tasks.Done()
+		if err := task(s.tasksCtx); err != nil && !errors.Is(err, context.Canceled) {
+			s.logger().Error("Error in background task", zap.Error(err))
+		}
+	}()
+}
+
+// stopTasks cancels the background tasks started with Go and waits for them to return until ctx is done.
+func (s *Server) stopTasks(ctx context.Context) error {
+	s.cancelTasks()
+
+	done := make(chan struct{})
+	go func() {
+		s.tasks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.logger().Warn("Background tasks did not stop in time")
+		return fmt.Errorf("%w: background tasks still running: %w", ErrForcedShutdown, ctx.Err())
+	}
+}
+
+// stopWithTimeout stops the Server within the ShutdownTimeout, for when there's no caller to give a deadline.
+func (s *Server) stopWithTimeout() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	return s.Stop(ctx)
+}
+
+// shutdown hs gracefully until ctx is done, and forcefully after that.
+func (s *Server) shutdown(ctx context.Context, hs *http.Server) error {
+	// This closes idle connections now, and makes active ones close after their current response.
+	hs.SetKeepAlivesEnabled(false)
+	if err := hs.Shutdown(ctx); err != nil {
+		if ctx.Err() == nil {
+			return fmt.Errorf("error stopping server: %w", err)
+		}
+
+		s.logger().Warn("Graceful shutdown did not complete in time, force-closing connections", zap.Error(err))
+		if err := hs.Close(); err != nil {
+			return fmt.Errorf("error closing server: %w", err)
+		}
+		return fmt.Errorf("%w: %w", ErrForcedShutdown, err)
+	}
+
+	return nil
+}
+
+// AddHealthCheck named name to the readiness probe, which then fails whenever check returns an error
+// or doesn't return within the HealthCheckTimeout. It's safe to call while the Server is running.
+func (s *Server) AddHealthCheck(name string, check func(ctx context.Context) error) {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+	s.healthChecks = append(s.healthChecks, handlers.HealthCheck{Name: name, Check: check, Timeout: s.healthCheckTimeout})
+}
+
+// currentHealthChecks returns a copy of the checks added with AddHealthCheck.
+func (s *Server) currentHealthChecks() []handlers.HealthCheck {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+	return append([]handlers.HealthCheck(nil), s.healthChecks...)
+}
+
+// SetLogger replaces the Server's logger, for example after reloading logging configuration.
+// Like the one given in Options, it's tagged with the release, and also writes to the log buffer if there is one.
+// It's safe to call while the Server is running.
+func (s *Server) SetLogger(log *zap.Logger) {
+	if log == nil {
+		log = zap.NewNop()
+	}
+	if s.logBuffer != nil {
+		log = s.logBuffer.tee(log, s.logLevel)
+	}
+
+	s.logLock.Lock()
+	defer s.logLock.Unlock()
+	s.log = log.With(zap.String("release", s.release))
+}
+
+// LogLevel returns the level of the Server's logger, as given in Options. Changing it takes effect immediately.
+func (s *Server) LogLevel() zap.AtomicLevel {
+	return s.logLevel
+}
+
+// logger returns the Server's current logger.
+func (s *Server) logger() *zap.Logger {
+	s.logLock.RLock()
+	defer s.logLock.RUnlock()
+	return s.log
+}
@@ -1 +1,576 @@
-package server_test
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("applies default timeouts", func(t *testing.T) {
+		s := New(Options{})
+		assertTimeouts(t, s.server, 5*time.Second, 10*time.Second, 120*time.Second)
+	})
+
+	t.Run("applies given timeouts", func(t *testing.T) {
+		s := New(Options{ReadTimeout: time.Second, WriteTimeout: 2 * time.Second, IdleTimeout: 3 * time.Second})
+		assertTimeouts(t, s.server, time.Second, 2*time.Second, 3*time.Second)
+	})
+}
+
+func assertTimeouts(t *testing.T, s *http.Server, read, write, idle time.Duration) {
+	t.Helper()
+
+	if s.ReadTimeout != read {
+		t.Fatalf("expected read timeout %v, got %v", read, s.ReadTimeout)
+	}
+	if s.WriteTimeout != write {
+		t.Fatalf("expected write timeout %v, got %v", write, s.WriteTimeout)
+	}
+	if s.IdleTimeout != idle {
+		t.Fatalf("expected idle timeout %v, got %v", idle, s.IdleTimeout)
+	}
+}
+
+func TestServer_Start(t *testing.T) {
+	t.Run("starts listening and responds to requests", func(t *testing.T) {
+		port := freePort(t)
+		s := New(Options{Host: "localhost", Port: port})
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		address := net.JoinHostPort("localhost", strconv.Itoa(port))
+		waitForListener(t, address)
+
+		res, err := http.Get("http://" + address + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected status %v, got %v", http.StatusNotFound, res.StatusCode)
+		}
+
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestNewWithListener(t *testing.T) {
+	t.Run("serves on the given listener", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := NewWithListener(Options{}, l)
+		address := startTestServer(t, s)
+
+		if address != l.Addr().String() {
+			t.Fatalf("expected address %v, got %v", l.Addr(), address)
+		}
+		if code := getStatus(t, "http://"+address+"/healthz/live"); code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, code)
+		}
+	})
+}
+
+func TestServer_StartBindError(t *testing.T) {
+	t.Run("returns an error if the port is already in use", func(t *testing.T) {
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = l.Close()
+		}()
+
+		s := New(Options{Host: "localhost", Port: l.Addr().(*net.TCPAddr).Port})
+		err = s.Start(context.Background())
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			t.Fatal("expected an error other than http.ErrServerClosed")
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) {
+			t.Fatalf("expected EADDRINUSE, got %v", err)
+		}
+	})
+}
+
+func TestServer_StartBindRetry(t *testing.T) {
+	t.Run("retries binding until the port is released", func(t *testing.T) {
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		port := l.Addr().(*net.TCPAddr).Port
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			_ = l.Close()
+		}()
+
+		core, logs := observer.New(zapcore.InfoLevel)
+		s, _ := startServer(t, Options{BindRetries: 20, BindRetryDelay: 20 * time.Millisecond, Log: zap.New(core), Port: port})
+
+		if s.Addr().(*net.TCPAddr).Port != port {
+			t.Fatalf("expected port %v, got %v", port, s.Addr())
+		}
+		if logs.FilterMessage("Port in use, retrying").Len() == 0 {
+			t.Fatal("expected retries to be logged")
+		}
+	})
+
+	t.Run("does not retry other errors", func(t *testing.T) {
+		core, logs := observer.New(zapcore.InfoLevel)
+		s := New(Options{BindRetries: 20, BindRetryDelay: time.Second, Host: "256.0.0.1", Log: zap.New(core)})
+
+		if err := s.Start(context.Background()); err == nil {
+			t.Fatal("expected an error")
+		}
+		if logs.FilterMessage("Port in use, retrying").Len() != 0 {
+			t.Fatal("expected no retries")
+		}
+	})
+}
+
+func TestServer_StartWithContext(t *testing.T) {
+	t.Run("keeps serving until the context is cancelled, then shuts down cleanly", func(t *testing.T) {
+		s := New(Options{Host: "localhost"})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(ctx)
+		}()
+		for s.Addr() == nil {
+			time.Sleep(time.Millisecond)
+		}
+
+		select {
+		case err := <-errs:
+			t.Fatal("stopped before the context was cancelled:", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		if !s.ready.Load() {
+			t.Fatal("not ready before the context was cancelled")
+		}
+
+		cancel()
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("did not stop after the context was cancelled")
+		}
+		if s.ready.Load() {
+			t.Fatal("ready after stopping")
+		}
+	})
+
+	t.Run("returns promptly without serving if the context is cancelled while starting", func(t *testing.T) {
+		port := freePort(t)
+		s := New(adminOptions(t, Options{Host: "localhost", Port: port}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(ctx)
+		}()
+		cancel()
+
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Fatalf("expected a clean stop, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("did not return after the context was cancelled")
+		}
+		if state := s.State(); state != StateStopped {
+			t.Fatalf("expected state %v, got %v", StateStopped, state)
+		}
+
+		// Listeners bound before noticing the cancellation are closed again.
+		l, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = l.Close()
+	})
+
+	t.Run("aborts retrying to bind if the context is cancelled", func(t *testing.T) {
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = l.Close()
+		}()
+
+		s := New(Options{BindRetries: 100, BindRetryDelay: time.Second, Host: "localhost", Port: l.Addr().(*net.TCPAddr).Port})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(ctx)
+		}()
+
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Fatalf("expected a clean stop, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("did not return after the context was cancelled")
+		}
+	})
+}
+
+func TestServer_StartLogging(t *testing.T) {
+	t.Run("logs the address and release once after binding", func(t *testing.T) {
+		core, logs := observer.New(zapcore.InfoLevel)
+		port := freePort(t)
+		s := New(Options{Host: "localhost", Log: zap.New(core), Port: port, Release: "abc123"})
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		waitForListener(t, net.JoinHostPort("localhost", strconv.Itoa(port)))
+
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+
+		entries := logs.FilterMessage("Server listening").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 listening log entry, got %v", len(entries))
+		}
+		fields := entries[0].ContextMap()
+		if fields["host"] != "localhost" || fields["port"] != int64(port) || fields["release"] != "abc123" {
+			t.Fatalf("unexpected fields %v", fields)
+		}
+	})
+}
+
+func TestServer_Ready(t *testing.T) {
+	t.Run("is ready after binding and not ready after stopping", func(t *testing.T) {
+		port := freePort(t)
+		s := New(Options{Host: "localhost", Port: port})
+		if s.ready.Load() {
+			t.Fatal("ready before start")
+		}
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		waitForListener(t, net.JoinHostPort("localhost", strconv.Itoa(port)))
+		if !s.ready.Load() {
+			t.Fatal("not ready after start")
+		}
+
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		if s.ready.Load() {
+			t.Fatal("ready after stop")
+		}
+	})
+}
+
+func TestServer_MaxHeaderBytes(t *testing.T) {
+	t.Run("rejects requests with headers over the limit", func(t *testing.T) {
+		_, address := startServer(t, Options{MaxHeaderBytes: 1024})
+
+		get := func(size int) int {
+			req, err := http.NewRequest(http.MethodGet, "http://"+address+"/healthz/live", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Padding", strings.Repeat("a", size))
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = res.Body.Close()
+			return res.StatusCode
+		}
+
+		if code := get(100); code != http.StatusOK {
+			t.Fatalf("expected status %v for small headers, got %v", http.StatusOK, code)
+		}
+		// net/http allows some slack on top of the limit, so go well over it.
+		if code := get(16 << 10); code != http.StatusRequestHeaderFieldsTooLarge {
+			t.Fatalf("expected status %v for large headers, got %v", http.StatusRequestHeaderFieldsTooLarge, code)
+		}
+	})
+}
+
+func TestServer_SetLogger(t *testing.T) {
+	t.Run("replaces the logger and falls back to a no-op logger", func(t *testing.T) {
+		s := New(Options{Release: "abc"})
+		core, logs := observer.New(zapcore.InfoLevel)
+		s.SetLogger(zap.New(core))
+		s.logger().Info("Hi")
+		if logs.Len() != 1 || logs.All()[0].ContextMap()["release"] != "abc" {
+			t.Fatal("logger not replaced or not tagged with release")
+		}
+
+		s.SetLogger(nil)
+		if s.logger() == nil {
+			t.Fatal("logger is nil")
+		}
+	})
+}
+
+func TestServer_LogLevel(t *testing.T) {
+	t.Run("changes the level of the logger at runtime", func(t *testing.T) {
+		level := zap.NewAtomicLevelAt(zap.ErrorLevel)
+		core, logs := observer.New(level)
+		s := New(Options{Log: zap.New(core), LogLevel: level})
+
+		s.logger().Info("Suppressed")
+		s.LogLevel().SetLevel(zap.InfoLevel)
+		s.logger().Info("Visible")
+
+		if logs.Len() != 1 || logs.All()[0].Message != "Visible" {
+			t.Fatalf("expected only the log after changing the level, got %v", logs.All())
+		}
+	})
+}
+
+func TestServer_Addr(t *testing.T) {
+	t.Run("is nil before start", func(t *testing.T) {
+		s := New(Options{})
+		if s.Addr() != nil {
+			t.Fatal("address is not nil")
+		}
+	})
+
+	t.Run("reports the chosen port when binding to port 0", func(t *testing.T) {
+		s, address := startServer(t, Options{Port: 0})
+
+		if port := s.Addr().(*net.TCPAddr).Port; port == 0 {
+			t.Fatal("port is 0")
+		}
+
+		c, err := net.Dial("tcp", address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = c.Close()
+	})
+}
+
+func TestServer_StartMultipleHosts(t *testing.T) {
+	t.Run("serves on every host and closes them all on stop", func(t *testing.T) {
+		port := freePort(t)
+		s := New(Options{Host: "127.0.0.1, 127.0.0.2", Port: port})
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		if err := s.WaitReady(context.Background()); err != nil {
+			t.Fatal(err, <-errs)
+		}
+
+		addresses := []string{net.JoinHostPort("127.0.0.1", strconv.Itoa(port)), net.JoinHostPort("127.0.0.2", strconv.Itoa(port))}
+		if addrs := s.Addrs(); len(addrs) != 2 || addrs[0].String() != addresses[0] || addrs[1].String() != addresses[1] {
+			t.Fatalf("expected addresses %v, got %v", addresses, addrs)
+		}
+		for _, address := range addresses {
+			if code := getStatus(t, "http://"+address+"/healthz/live"); code != http.StatusOK {
+				t.Fatalf("expected status %v on %v, got %v", http.StatusOK, address, code)
+			}
+		}
+
+		if err := s.Restart(Options{Host: "127.0.0.1", Port: freePort(t)}); !errors.Is(err, ErrInvalidOptions) {
+			t.Fatalf("expected ErrInvalidOptions from Restart, got %v", err)
+		}
+
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		for _, address := range addresses {
+			if c, err := net.Dial("tcp", address); err == nil {
+				_ = c.Close()
+				t.Fatalf("still listening on %v after stop", address)
+			}
+		}
+	})
+
+	t.Run("releases bound hosts if binding another fails", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.2:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = l.Close()
+		}()
+		port := l.Addr().(*net.TCPAddr).Port
+
+		s := New(Options{Host: "127.0.0.1,127.0.0.2", Port: port})
+		if err := s.Start(context.Background()); !errors.Is(err, syscall.EADDRINUSE) {
+			t.Fatalf("expected EADDRINUSE, got %v", err)
+		}
+
+		c, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+		if err != nil {
+			t.Fatalf("first host not released: %v", err)
+		}
+		_ = c.Close()
+	})
+}
+
+func TestServer_Restart(t *testing.T) {
+	t.Run("moves to a new port and stops responding on the old one", func(t *testing.T) {
+		s, oldAddress := startServer(t, Options{})
+		if code := getStatus(t, "http://"+oldAddress+"/healthz/live"); code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, code)
+		}
+
+		port := freePort(t)
+		if err := s.Restart(Options{Host: "localhost", Port: port}); err != nil {
+			t.Fatal(err)
+		}
+
+		if newPort := s.Addr().(*net.TCPAddr).Port; newPort != port {
+			t.Fatalf("expected port %v, got %v", port, newPort)
+		}
+		if code := getStatus(t, "http://"+s.Addr().String()+"/healthz/live"); code != http.StatusOK {
+			t.Fatalf("expected status %v on the new port, got %v", http.StatusOK, code)
+		}
+		if _, err := http.Get("http://" + oldAddress + "/healthz/live"); err == nil {
+			t.Fatal("old port still responding after restart")
+		}
+	})
+
+	t.Run("keeps serving on the old port if binding the new one fails", func(t *testing.T) {
+		s, address := startServer(t, Options{})
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = l.Close()
+		}()
+
+		if err := s.Restart(Options{Host: "localhost", Port: l.Addr().(*net.TCPAddr).Port}); !errors.Is(err, syscall.EADDRINUSE) {
+			t.Fatalf("expected EADDRINUSE, got %v", err)
+		}
+		if s.Addr().String() != address {
+			t.Fatalf("expected address %v, got %v", address, s.Addr())
+		}
+		if code := getStatus(t, "http://"+address+"/healthz/live"); code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, code)
+		}
+	})
+
+	t.Run("errors if the server is not running", func(t *testing.T) {
+		s := New(Options{})
+		if err := s.Restart(Options{Host: "localhost"}); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+// startServer with the given Options, on a port chosen by the OS unless one is given,
+// and stop it when the test is done. It returns the Server and the address it's listening on.
+func startServer(t *testing.T, opts Options) (*Server, string) {
+	t.Helper()
+
+	if opts.Host == "" {
+		opts.Host = "localhost"
+	}
+	s := New(opts)
+	return s, startTestServer(t, s)
+}
+
+// startTestServer s and stop it when the test is done. It returns the address s is listening on.
+// Use it instead of startServer to register routes on s before it starts.
+func startTestServer(t *testing.T, s *Server) string {
+	t.Helper()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Start(context.Background())
+	}()
+
+	if err := s.WaitReady(context.Background()); err != nil {
+		t.Fatal(err, <-errs)
+	}
+
+	t.Cleanup(func() {
+		if err := s.Stop(context.Background()); err != nil {
+			t.Error(err)
+		}
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	})
+
+	return s.Addr().String()
+}
+
+// freePort asks the OS for an unused port, by binding to port 0 and releasing it again.
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = l.Close()
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// waitForListener blocks until something accepts TCP connections on address.
+func waitForListener(t *testing.T, address string) {
+	t.Helper()
+
+	var err error
+	for i := 0; i < 50; i++ {
+		var c net.Conn
+		c, err = net.Dial("tcp", address)
+		if err == nil {
+			_ = c.Close()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal(err)
+}
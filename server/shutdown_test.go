@@ -0,0 +1,312 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestServer_Stop(t *testing.T) {
+	t.Run("force-closes connections when shutdown exceeds the timeout", func(t *testing.T) {
+		port := freePort(t)
+		s := New(Options{Host: "localhost", Port: port})
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		s.mux.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		waitForListener(t, net.JoinHostPort("localhost", strconv.Itoa(port)))
+
+		go func() {
+			res, err := http.Get("http://" + net.JoinHostPort("localhost", strconv.Itoa(port)) + "/slow")
+			if err == nil {
+				_ = res.Body.Close()
+			}
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		before := time.Now()
+		err := s.Stop(ctx)
+		if !errors.Is(err, ErrForcedShutdown) {
+			t.Fatalf("expected ErrForcedShutdown, got %v", err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+		}
+		if elapsed := time.Since(before); elapsed > time.Second {
+			t.Fatalf("shutdown took %v, longer than the timeout allows", elapsed)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestServer_StopWithPreStopDelay(t *testing.T) {
+	t.Run("reports not ready during the delay while finishing requests", func(t *testing.T) {
+		s := New(Options{Host: "localhost", PreStopDelay: 300 * time.Millisecond})
+		started := make(chan struct{})
+		s.mux.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		})
+		address := startTestServer(t, s)
+
+		slow := make(chan int, 1)
+		go func() {
+			res, err := http.Get("http://" + address + "/slow")
+			if err != nil {
+				slow <- 0
+				return
+			}
+			_ = res.Body.Close()
+			slow <- res.StatusCode
+		}()
+		<-started
+
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- s.Stop(context.Background())
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		res, err := http.Get("http://" + address + "/healthz/ready")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v during delay, got %v", http.StatusServiceUnavailable, res.StatusCode)
+		}
+
+		if code := <-slow; code != http.StatusOK {
+			t.Fatalf("expected slow request to complete with %v, got %v", http.StatusOK, code)
+		}
+		if err := <-stopped; err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestServer_Go(t *testing.T) {
+	t.Run("cancels background tasks on stop and waits for them", func(t *testing.T) {
+		s, _ := startServer(t, Options{})
+
+		var finished atomic.Bool
+		s.Go(func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(50 * time.Millisecond)
+			finished.Store(true)
+			return ctx.Err()
+		})
+
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if !finished.Load() {
+			t.Fatal("stop returned before the background task finished")
+		}
+	})
+
+	t.Run("gives up on background tasks that don't stop in time", func(t *testing.T) {
+		s, _ := startServer(t, Options{})
+
+		release := make(chan struct{})
+		defer close(release)
+		s.Go(func(ctx context.Context) error {
+			<-release
+			return nil
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := s.Stop(ctx); !errors.Is(err, ErrForcedShutdown) {
+			t.Fatalf("expected ErrForcedShutdown, got %v", err)
+		}
+	})
+}
+
+func TestServer_Close(t *testing.T) {
+	t.Run("drops in-flight requests right away", func(t *testing.T) {
+		s := New(Options{Host: "localhost"})
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		s.mux.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+		})
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		for s.Addr() == nil {
+			time.Sleep(time.Millisecond)
+		}
+
+		requestErrs := make(chan error, 1)
+		go func() {
+			res, err := http.Get("http://" + s.Addr().String() + "/slow")
+			if err == nil {
+				_ = res.Body.Close()
+			}
+			requestErrs <- err
+		}()
+		<-started
+
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-requestErrs; err == nil {
+			t.Fatal("expected the in-flight request to fail")
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		if s.ready.Load() {
+			t.Fatal("ready after close")
+		}
+	})
+}
+
+func TestServer_StopSummary(t *testing.T) {
+	t.Run("logs the requests served by status class once", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		s := New(Options{Host: "localhost", Log: zap.New(core), LogRequests: true})
+		s.mux.Get("/ok", func(w http.ResponseWriter, r *http.Request) {})
+		s.mux.Get("/fail", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		if err := s.WaitReady(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		address := s.Addr().String()
+
+		for _, path := range []string{"/ok", "/ok", "/missing", "/fail", "/ok"} {
+			_ = getStatus(t, "http://"+address+path)
+		}
+
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		summaries := logs.FilterMessage("Served requests").All()
+		if len(summaries) != 1 {
+			t.Fatalf("expected 1 summary, got %v", len(summaries))
+		}
+		fields := summaries[0].ContextMap()
+		for name, expected := range map[string]uint64{"total": 5, "status_2xx": 3, "status_4xx": 1, "status_5xx": 1} {
+			if fields[name] != expected {
+				t.Fatalf("expected %v %v, got %v", name, expected, fields[name])
+			}
+		}
+		if uptime, ok := fields["uptime"].(time.Duration); !ok || uptime <= 0 {
+			t.Fatalf("expected positive uptime, got %v", fields["uptime"])
+		}
+	})
+}
+
+func TestServer_StopIdleConnections(t *testing.T) {
+	t.Run("closes idle keep-alive connections right away", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		s := New(Options{Host: "localhost", Log: zap.New(core)})
+		var wg sync.WaitGroup
+		const conns = 5
+		wg.Add(conns)
+		s.mux.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			// Hold all requests until each has its own connection.
+			wg.Done()
+			wg.Wait()
+		})
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		if err := s.WaitReady(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: conns}}
+		defer client.CloseIdleConnections()
+		var requests sync.WaitGroup
+		for range conns {
+			requests.Add(1)
+			go func() {
+				defer requests.Done()
+				res, err := client.Get("http://" + s.Addr().String() + "/")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				_ = res.Body.Close()
+			}()
+		}
+		requests.Wait()
+
+		for i := 0; ; i++ {
+			if idle, _ := s.conns.count(); idle == conns {
+				break
+			}
+			if i == 100 {
+				t.Fatal("connections did not become idle")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		start := time.Now()
+		if err := s.Stop(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("expected stop to finish quickly, took %v", elapsed)
+		}
+
+		entries := logs.FilterMessage("Shutting down").All()
+		if len(entries) != 1 {
+			t.Fatalf("expected 1 log entry, got %v", len(entries))
+		}
+		fields := entries[0].ContextMap()
+		if fields["idle_connections"] != int64(conns) || fields["active_connections"] != int64(0) {
+			t.Fatalf("expected %v idle and 0 active connections, got %v", conns, fields)
+		}
+	})
+}
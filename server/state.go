@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// State of a Server in its lifecycle.
+type State int32
+
+const (
+	// StateNew is the State of a Server that hasn't been started.
+	StateNew State = iota
+	// StateStarting is the State while Start binds the listeners.
+	StateStarting
+	// StateRunning is the State while the Server serves requests.
+	StateRunning
+	// StateDraining is the State while Stop waits for in-flight requests.
+	StateDraining
+	// StateStopped is the State after the Server stopped or failed to start.
+	StateStopped
+)
+
+func (s State) String() string {
+	switch s {
+	case StateNew:
+		return "new"
+	case StateStarting:
+		return "starting"
+	case StateRunning:
+		return "running"
+	case StateDraining:
+		return "draining"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// lifecycle keeps track of the State of a Server, and signals when it's running and stopped.
+type lifecycle struct {
+	running     chan struct{}
+	runningOnce sync.Once
+	state       atomic.Int32
+	stopped     chan struct{}
+	stoppedOnce sync.Once
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{running: make(chan struct{}), stopped: make(chan struct{})}
+}
+
+func (l *lifecycle) set(state State) {
+	l.state.Store(int32(state))
+	switch state {
+	case StateRunning:
+		l.runningOnce.Do(func() { close(l.running) })
+	case StateStopped:
+		l.stoppedOnce.Do(func() { close(l.stopped) })
+	}
+}
+
+// State of the Server. It's safe to call concurrently with Start and Stop.
+func (s *Server) State() State {
+	return State(s.lifecycle.state.Load())
+}
+
+// Ready returns a channel that is closed once the Server is running, with its listeners bound and serving.
+// If Start fails before that, the channel is never closed, and Start returns the error instead. See also WaitReady.
+func (s *Server) Ready() <-chan struct{} {
+	return s.lifecycle.running
+}
+
+// WaitReady blocks until the Server is running, and returns nil then.
+// It returns an error if the Server stops before it's running, or if ctx is done first.
+func (s *Server) WaitReady(ctx context.Context) error {
+	select {
+	case <-s.lifecycle.running:
+		return nil
+	case <-s.lifecycle.stopped:
+		return errors.New("server stopped before running")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServer_State(t *testing.T) {
+	t.Run("goes through all states in a start and stop cycle", func(t *testing.T) {
+		l, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := New(Options{
+			BindRetries:    50,
+			BindRetryDelay: 10 * time.Millisecond,
+			Host:           "localhost",
+			Port:           l.Addr().(*net.TCPAddr).Port,
+			PreStopDelay:   100 * time.Millisecond,
+		})
+		assertState(t, s, StateNew)
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+
+		// The port is still taken, so the Server keeps starting.
+		waitForState(t, s, StateStarting)
+		_ = l.Close()
+
+		if err := s.WaitReady(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		assertState(t, s, StateRunning)
+
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- s.Stop(context.Background())
+		}()
+		waitForState(t, s, StateDraining)
+
+		if err := <-stopped; err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+		assertState(t, s, StateStopped)
+	})
+
+	t.Run("is stopped if starting fails", func(t *testing.T) {
+		s := New(Options{TLSCertFile: "cert.pem"})
+		if err := s.Start(context.Background()); err == nil {
+			t.Fatal("expected an error")
+		}
+		assertState(t, s, StateStopped)
+
+		if err := s.WaitReady(context.Background()); err == nil {
+			t.Fatal("expected an error from WaitReady")
+		}
+	})
+}
+
+func TestServer_WaitReady(t *testing.T) {
+	t.Run("returns when the context is done", func(t *testing.T) {
+		s := New(Options{})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		if err := s.WaitReady(ctx); err != context.DeadlineExceeded {
+			t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+func TestServer_ReadySignal(t *testing.T) {
+	t.Run("is closed once the server accepts connections", func(t *testing.T) {
+		s := New(Options{Host: "localhost"})
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+
+		select {
+		case <-s.Ready():
+		case err := <-errs:
+			t.Fatal(err)
+		}
+
+		conn, err := net.Dial("tcp", s.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.Close()
+
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("is not closed if the server fails to start", func(t *testing.T) {
+		s := New(Options{TLSCertFile: "cert.pem"})
+		if err := s.Start(context.Background()); err == nil {
+			t.Fatal("expected an error, got nil")
+		}
+
+		select {
+		case <-s.Ready():
+			t.Fatal("ready after failing to start")
+		default:
+		}
+	})
+}
+
+func assertState(t *testing.T, s *Server, expected State) {
+	t.Helper()
+
+	if state := s.State(); state != expected {
+		t.Fatalf("expected state %v, got %v", expected, state)
+	}
+}
+
+// waitForState polls until s is in the expected State, failing the test after a second.
+func waitForState(t *testing.T, s *Server, expected State) {
+	t.Helper()
+
+	for i := 0; s.State() != expected; i++ {
+		if i == 1000 {
+			t.Fatalf("expected state %v, got %v", expected, s.State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	connContextKey    = contextKey("conn")
+	streamsContextKey = contextKey("streams")
+)
+
+// streamPollInterval is how often Stop checks whether requests and streams are done while draining.
+// It's a variable so tests can shorten it.
+var streamPollInterval = 10 * time.Millisecond
+
+// streams keeps track of long-lived connections registered with StartStream, like WebSockets and server-sent events.
+type streams struct {
+	lock     sync.Mutex
+	active   map[*stream]struct{}
+	draining bool
+}
+
+// stream is a registered long-lived connection. conn is nil if the request came from outside a Server.
+type stream struct {
+	cancel context.CancelFunc
+	conn   net.Conn
+}
+
+func newStreams() *streams {
+	return &streams{active: map[*stream]struct{}{}}
+}
+
+// StartStream registers the request r as a long-lived connection, like a WebSocket or server-sent events.
+// Stop doesn't count streams against its deadline, but drains the other requests first,
+// and then gives streams their own budget of Options.StreamDrainTimeout, after which their connections are closed.
+// The returned context is done when the Server starts draining streams, so handlers know to wind down.
+// Call the returned function when the stream ends. Outside of a Server, the stream isn't tracked.
+func StartStream(r *http.Request) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(r.Context())
+	ss, ok := r.Context().Value(streamsContextKey).(*streams)
+	if !ok {
+		return ctx, cancel
+	}
+
+	conn, _ := r.Context().Value(connContextKey).(net.Conn)
+	st := &stream{cancel: cancel, conn: conn}
+	ss.lock.Lock()
+	ss.active[st] = struct{}{}
+	if ss.draining {
+		cancel()
+	}
+	ss.lock.Unlock()
+
+	return ctx, func() {
+		cancel()
+		ss.lock.Lock()
+		delete(ss.active, st)
+		ss.lock.Unlock()
+	}
+}
+
+// withStreams is middleware that stores ss in the request context for StartStream.
+func withStreams(ss *streams) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), streamsContextKey, ss)))
+		})
+	}
+}
+
+// withConn is an http.Server.ConnContext callback that stores the connection for StartStream.
+func withConn(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey, c)
+}
+
+// count the active streams.
+func (ss *streams) count() int {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+	return len(ss.active)
+}
+
+// conns returns the connections of the active streams.
+func (ss *streams) conns() map[net.Conn]bool {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	conns := map[net.Conn]bool{}
+	for st := range ss.active {
+		if st.conn != nil {
+			conns[st.conn] = true
+		}
+	}
+	return conns
+}
+
+// drain tells active streams to wind down by cancelling their contexts. Streams started after that are cancelled right away.
+func (ss *streams) drain() {
+	ss.lock.Lock()
+	defer ss.lock.Unlock()
+
+	ss.draining = true
+	for st := range ss.active {
+		st.cancel()
+	}
+}
+
+// close the connections of the active streams.
+func (ss *streams) close() {
+	for conn := range ss.conns() {
+		_ = conn.Close()
+	}
+}
+
+// pollUntil checks done every streamPollInterval until it returns true, and reports whether it did before ctx is done.
+func pollUntil(ctx context.Context, done func() bool) bool {
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// shutdownWithStreams shuts hs down like shutdown, but doesn't let streams count against ctx.
+// Other requests are drained until ctx is done, after which their connections are closed.
+// Then streams are drained within the StreamDrainTimeout, after which their connections are closed too.
+// It returns when the streams' budget ends, or the zero time if there were no streams.
+func (s *Server) shutdownWithStreams(ctx context.Context, hs *http.Server) (time.Time, error) {
+	if s.streams.count() == 0 {
+		return time.Time{}, s.shutdown(ctx, hs)
+	}
+
+	hs.SetKeepAlivesEnabled(false)
+	shutdownCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- hs.Shutdown(shutdownCtx)
+	}()
+
+	var err error
+	if !pollUntil(ctx, func() bool { return s.inFlight.Load() <= int64(s.streams.count()) }) {
+		s.logger().Warn("Graceful shutdown did not complete in time, force-closing connections except streams", zap.Error(ctx.Err()))
+		streamConns := s.streams.conns()
+		for _, conn := range s.conns.list() {
+			// Streams know their connection from before the TLS handshake.
+			raw := conn
+			if tlsConn, ok := conn.(*tls.Conn); ok {
+				raw = tlsConn.NetConn()
+			}
+			if !streamConns[raw] {
+				_ = conn.Close()
+			}
+		}
+		err = fmt.Errorf("%w: %w", ErrForcedShutdown, ctx.Err())
+	}
+
+	s.logger().Info("Draining streams", zap.Int("streams", s.streams.count()), zap.Duration("timeout", s.streamDrainTimeout))
+	s.streams.drain()
+	deadline := time.Now().Add(s.streamDrainTimeout)
+	streamCtx, cancelStreams := context.WithDeadline(context.WithoutCancel(ctx), deadline)
+	defer cancelStreams()
+
+	if pollUntil(streamCtx, func() bool { return s.streams.count() == 0 }) {
+		select {
+		case shutdownErr := <-shutdownErr:
+			if shutdownErr != nil {
+				return deadline, fmt.Errorf("error stopping server: %w", shutdownErr)
+			}
+			return deadline, err
+		case <-streamCtx.Done():
+		}
+	}
+
+	s.logger().Warn("Streams did not end in time, force-closing connections", zap.Int("streams", s.streams.count()))
+	s.streams.close()
+	if closeErr := hs.Close(); closeErr != nil {
+		return deadline, fmt.Errorf("error closing server: %w", closeErr)
+	}
+	return deadline, fmt.Errorf("%w: %w", ErrForcedShutdown, streamCtx.Err())
+}
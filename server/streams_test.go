@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServer_StopWithStreams(t *testing.T) {
+	t.Run("gives streams their own budget after the other requests", func(t *testing.T) {
+		s := New(Options{Host: "localhost", StreamDrainTimeout: time.Second})
+		started := make(chan struct{})
+		s.mux.Get("/events", func(w http.ResponseWriter, r *http.Request) {
+			ctx, done := StartStream(r)
+			defer done()
+			w.WriteHeader(http.StatusOK)
+			http.NewResponseController(w).Flush()
+			close(started)
+
+			<-ctx.Done()
+			time.Sleep(100 * time.Millisecond)
+			_, _ = w.Write([]byte("bye\n"))
+		})
+		address := startTestServer(t, s)
+
+		body := make(chan string, 1)
+		go func() {
+			res, err := http.Get("http://" + address + "/events")
+			if err != nil {
+				body <- err.Error()
+				return
+			}
+			defer func() { _ = res.Body.Close() }()
+			b, _ := io.ReadAll(res.Body)
+			body <- string(b)
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		before := time.Now()
+		if err := s.Stop(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(before); elapsed < 100*time.Millisecond {
+			t.Fatalf("expected the stream to get longer than the shutdown timeout, got %v", elapsed)
+		}
+		if b := <-body; b != "bye\n" {
+			t.Fatalf("expected the stream to end cleanly, got %q", b)
+		}
+	})
+
+	t.Run("force-closes streams when their budget expires", func(t *testing.T) {
+		s := New(Options{Host: "localhost", StreamDrainTimeout: 100 * time.Millisecond})
+		started := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		s.mux.Get("/ws", func(w http.ResponseWriter, r *http.Request) {
+			_, done := StartStream(r)
+			defer done()
+			conn, _, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer func() { _ = conn.Close() }()
+			_, _ = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n"))
+			close(started)
+			<-release
+		})
+		address := startTestServer(t, s)
+
+		closed := make(chan error, 1)
+		go func() {
+			req, _ := http.NewRequest(http.MethodGet, "http://"+address+"/ws", nil)
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "test")
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				closed <- err
+				return
+			}
+			_, err = bufio.NewReader(res.Body.(io.Reader)).ReadByte()
+			closed <- err
+		}()
+		<-started
+
+		before := time.Now()
+		err := s.Stop(context.Background())
+		if !errors.Is(err, ErrForcedShutdown) {
+			t.Fatalf("expected ErrForcedShutdown, got %v", err)
+		}
+		if elapsed := time.Since(before); elapsed < 100*time.Millisecond || elapsed > time.Second {
+			t.Fatalf("expected the stream to be closed after its budget, got %v", elapsed)
+		}
+		if err := <-closed; !errors.Is(err, io.EOF) {
+			t.Fatalf("expected the connection to be closed, got %v", err)
+		}
+	})
+
+	t.Run("doesn't track streams outside of a Server", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		ctx, done := StartStream(r)
+		if ctx.Err() != nil {
+			t.Fatalf("expected the stream context to be active, got %v", ctx.Err())
+		}
+		done()
+		if ctx.Err() == nil {
+			t.Fatal("expected the stream context to be done after the stream ends")
+		}
+	})
+}
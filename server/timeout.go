@@ -0,0 +1,230 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const requestTimeoutContextKey = contextKey("requestTimeout")
+
+// timeoutRequests is middleware that responds with 503 Service Unavailable if the handler doesn't finish in time.
+// The request context is cancelled then, and later writes from the handler fail with http.ErrHandlerTimeout.
+// Like with http.TimeoutHandler, the response is buffered until the handler returns.
+// Use RequestTimeout to change the timeout for specific routes.
+func timeoutRequests(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+
+			tw := &timeoutWriter{
+				w:        w,
+				h:        make(http.Header),
+				cancel:   cancel,
+				start:    time.Now(),
+				timedOut: make(chan struct{}),
+			}
+			tw.timer = time.AfterFunc(timeout, tw.timeout)
+			defer tw.timer.Stop()
+			r = r.WithContext(context.WithValue(ctx, requestTimeoutContextKey, tw))
+
+			done := make(chan struct{})
+			panics := make(chan any, 1)
+			go func() {
+				defer func() {
+					v := recover()
+					if v == nil {
+						return
+					}
+					if v == http.ErrAbortHandler {
+						panics <- v
+						return
+					}
+					panics <- &handlerPanic{value: v, stack: debug.Stack()}
+				}()
+				next.ServeHTTP(tw, r)
+				close(done)
+			}()
+
+			select {
+			case v := <-panics:
+				// Re-panic here, so the panic recovery further up sees it, together with the stack from the handler.
+				panic(v)
+			case <-done:
+				tw.finish()
+			case <-tw.timedOut:
+			}
+		})
+	}
+}
+
+// RequestTimeout is middleware that changes the request timeout for the routes it's used on,
+// counting from when the request started. A timeout of zero turns it off, for example for a streaming endpoint:
+//
+//	mux.With(server.RequestTimeout(0)).Get("/events", stream)
+//
+// Without a timeout, the response isn't buffered anymore, and the connection can be hijacked, like for WebSockets.
+// Routes are still bound by the WriteTimeout.
+func RequestTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tw, ok := r.Context().Value(requestTimeoutContextKey).(*timeoutWriter); ok {
+				tw.reset(timeout)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if timeout == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			timeoutRequests(timeout)(next).ServeHTTP(w, r)
+		})
+	}
+}
+
+// timeoutWriter buffers the response until the handler is done, or writes 503 Service Unavailable on timeout.
+// After the timeout is turned off with RequestTimeout, it writes through to w.
+type timeoutWriter struct {
+	w        http.ResponseWriter
+	h        http.Header
+	buf      bytes.Buffer
+	cancel   context.CancelFunc
+	code     int
+	finished bool
+	lock     sync.Mutex
+	start    time.Time
+	through  bool
+	timer    *time.Timer
+	timedOut chan struct{}
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+	if tw.through {
+		return tw.w.Header()
+	}
+	return tw.h
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+	tw.writeHeader(code)
+}
+
+func (tw *timeoutWriter) writeHeader(code int) {
+	if tw.code != 0 || tw.finished {
+		return
+	}
+	tw.code = code
+	if tw.through {
+		tw.w.WriteHeader(code)
+	}
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if tw.finished {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	if tw.through {
+		return tw.w.Write(b)
+	}
+	return tw.buf.Write(b)
+}
+
+// Flush the response to the client, if the timeout is turned off. Otherwise the response stays buffered.
+func (tw *timeoutWriter) Flush() {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if tw.through {
+		_ = http.NewResponseController(tw.w).Flush()
+	}
+}
+
+// Hijack the connection, if the timeout is turned off. Otherwise the response is buffered, so it can't be hijacked.
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if !tw.through {
+		return nil, nil, fmt.Errorf("%w: the response is buffered for the request timeout", http.ErrNotSupported)
+	}
+	return http.NewResponseController(tw.w).Hijack()
+}
+
+// Unwrap the underlying http.ResponseWriter, so http.ResponseController can reach it, like for deadlines.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// timeout responds with 503 Service Unavailable, unless the handler already finished.
+func (tw *timeoutWriter) timeout() {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if tw.finished || tw.through {
+		return
+	}
+	tw.finished = true
+	tw.cancel()
+	http.Error(tw.w, "Request timed out", http.StatusServiceUnavailable)
+	close(tw.timedOut)
+}
+
+// finish writes the buffered response after the handler returned in time.
+func (tw *timeoutWriter) finish() {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if tw.finished || tw.through {
+		tw.finished = true
+		return
+	}
+	tw.finished = true
+	tw.timer.Stop()
+	tw.flushBuffer()
+}
+
+// flushBuffer copies the buffered header and body to w. The lock must be held.
+func (tw *timeoutWriter) flushBuffer() {
+	for name, values := range tw.h {
+		tw.w.Header()[name] = values
+	}
+	if tw.code != 0 {
+		tw.w.WriteHeader(tw.code)
+	}
+	if tw.buf.Len() > 0 {
+		_, _ = tw.w.Write(tw.buf.Bytes())
+		tw.buf.Reset()
+	}
+}
+
+// reset the timeout to count from the start of the request, or turn it off for a zero timeout.
+func (tw *timeoutWriter) reset(timeout time.Duration) {
+	tw.lock.Lock()
+	defer tw.lock.Unlock()
+
+	if tw.finished {
+		return
+	}
+	if timeout == 0 {
+		tw.timer.Stop()
+		tw.through = true
+		tw.flushBuffer()
+		return
+	}
+	tw.timer.Reset(time.Until(tw.start.Add(timeout)))
+}
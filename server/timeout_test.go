@@ -0,0 +1,164 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutRequests(t *testing.T) {
+	t.Run("responds with 503 if the handler takes too long, and cancels its context", func(t *testing.T) {
+		cancelled := make(chan struct{})
+		h := timeoutRequests(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			close(cancelled)
+			if _, err := w.Write([]byte("too late")); err != http.ErrHandlerTimeout {
+				t.Errorf("expected http.ErrHandlerTimeout, got %v", err)
+			}
+		}))
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if res.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v, got %v", http.StatusServiceUnavailable, res.Code)
+		}
+		<-cancelled
+	})
+
+	t.Run("passes the response through if the handler is fast enough", func(t *testing.T) {
+		h := timeoutRequests(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Custom", "yes")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("done"))
+		}))
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if res.Code != http.StatusCreated || res.Body.String() != "done" || res.Header().Get("X-Custom") != "yes" {
+			t.Fatalf("expected the handler's response, got status %v and body %q", res.Code, res.Body.String())
+		}
+	})
+
+	t.Run("passes panics on to the caller with the stack of the handler", func(t *testing.T) {
+		h := timeoutRequests(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("oh no")
+		}))
+
+		defer func() {
+			p, ok := recover().(*handlerPanic)
+			if !ok || p.value != "oh no" {
+				t.Fatalf("expected panic oh no, got %v", p)
+			}
+			if !strings.Contains(string(p.stack), "TestTimeoutRequests") {
+				t.Fatalf("expected the stack of the handler, got %s", p.stack)
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestRequestTimeout(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(100 * time.Millisecond):
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		outer    time.Duration
+		timeout  time.Duration
+		expected int
+	}{
+		{name: "raises the timeout for a route", outer: 50 * time.Millisecond, timeout: time.Second, expected: http.StatusOK},
+		{name: "lowers the timeout for a route", outer: time.Second, timeout: 10 * time.Millisecond, expected: http.StatusServiceUnavailable},
+		{name: "turns the timeout off for a route", outer: 50 * time.Millisecond, timeout: 0, expected: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := timeoutRequests(test.outer)(RequestTimeout(test.timeout)(slow))
+
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if res.Code != test.expected {
+				t.Fatalf("expected status %v, got %v", test.expected, res.Code)
+			}
+		})
+	}
+
+	t.Run("writes through without a timeout", func(t *testing.T) {
+		flushed := make(chan struct{})
+		wrote := make(chan struct{})
+		h := timeoutRequests(time.Second)(RequestTimeout(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("event"))
+			http.NewResponseController(w).Flush()
+			close(wrote)
+			<-flushed
+		})))
+
+		res := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+			close(done)
+		}()
+		<-wrote
+		// The recorder isn't safe for concurrent use, but the handler is blocked until flushed is closed.
+		if !res.Flushed {
+			t.Fatal("expected the response to be flushed while the handler is running")
+		}
+		close(flushed)
+		<-done
+	})
+
+	t.Run("lets the connection be hijacked only without a timeout", func(t *testing.T) {
+		hijack := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, _, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked"))
+			_ = conn.Close()
+		})
+		for _, test := range []struct {
+			timeout  time.Duration
+			expected int
+		}{{timeout: 0, expected: http.StatusOK}, {timeout: time.Second, expected: http.StatusInternalServerError}} {
+			ts := httptest.NewServer(timeoutRequests(time.Second)(RequestTimeout(test.timeout)(hijack)))
+			defer ts.Close()
+
+			res, err := http.Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(res.Body)
+			_ = res.Body.Close()
+			if res.StatusCode != test.expected {
+				t.Fatalf("expected status %v with timeout %v, got %v: %s", test.expected, test.timeout, res.StatusCode, body)
+			}
+			if test.timeout == 0 && string(body) != "hijacked" {
+				t.Fatalf("expected the hijacked response, got %q", body)
+			}
+		}
+	})
+
+	t.Run("applies the timeout without the server-wide middleware", func(t *testing.T) {
+		h := RequestTimeout(10 * time.Millisecond)(slow)
+
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if res.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v, got %v", http.StatusServiceUnavailable, res.Code)
+		}
+	})
+}
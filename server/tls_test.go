@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestServer_StartTLS(t *testing.T) {
+	t.Run("serves HTTPS with the configured certificate and key", func(t *testing.T) {
+		certFile, keyFile, client := writeTestCert(t)
+
+		port := freePort(t)
+		s := New(Options{Host: "127.0.0.1", Port: port, TLSCertFile: certFile, TLSKeyFile: keyFile})
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		address := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+		waitForListener(t, address)
+
+		res, err := client.Get("https://" + address + "/healthz/live")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.StatusCode)
+		}
+		if res.TLS == nil {
+			t.Fatal("response was not served over TLS")
+		}
+
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// writeTestCert writes the self-signed certificate and key used by httptest to PEM files,
+// and returns their paths together with a client that trusts the certificate.
+func writeTestCert(t *testing.T) (certFile, keyFile string, client *http.Client) {
+	t.Helper()
+
+	ts := httptest.NewUnstartedServer(http.NotFoundHandler())
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	cert := ts.TLS.Certificates[0]
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile, ts.Client()
+}
+
+func TestServer_StartTLSOptions(t *testing.T) {
+	t.Run("returns ErrInvalidOptions with only a certificate or only a key", func(t *testing.T) {
+		for _, opts := range []Options{{TLSCertFile: "cert.pem"}, {TLSKeyFile: "key.pem"}} {
+			s := New(opts)
+			if err := s.Start(context.Background()); !errors.Is(err, ErrInvalidOptions) {
+				t.Fatalf("expected ErrInvalidOptions, got %v", err)
+			}
+		}
+	})
+}
+
+func TestServer_ReloadCertificate(t *testing.T) {
+	t.Run("uses the new certificate for new handshakes and keeps open connections", func(t *testing.T) {
+		certFile, keyFile, _ := writeTestCert(t)
+
+		port := freePort(t)
+		s := New(Options{Host: "127.0.0.1", Port: port, TLSCertFile: certFile, TLSKeyFile: keyFile})
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		address := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+		waitForListener(t, address)
+
+		before := dialTLS(t, address)
+		oldCert := before.ConnectionState().PeerCertificates[0]
+
+		newCert := writeSelfSignedCert(t, certFile, keyFile)
+		if err := s.ReloadCertificate(); err != nil {
+			t.Fatal(err)
+		}
+
+		after := dialTLS(t, address)
+		if cert := after.ConnectionState().PeerCertificates[0]; !cert.Equal(newCert) {
+			t.Fatalf("expected new certificate with serial %v, got %v", newCert.SerialNumber, cert.SerialNumber)
+		}
+
+		if cert := before.ConnectionState().PeerCertificates[0]; !cert.Equal(oldCert) {
+			t.Fatal("certificate of open connection changed")
+		}
+		if status := getOverConn(t, before, "/healthz/live"); status != http.StatusOK {
+			t.Fatalf("expected status %v on open connection, got %v", http.StatusOK, status)
+		}
+
+		_ = before.Close()
+		_ = after.Close()
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("keeps the current certificate if the files are invalid", func(t *testing.T) {
+		certFile, keyFile, _ := writeTestCert(t)
+
+		port := freePort(t)
+		s := New(Options{Host: "127.0.0.1", Port: port, TLSCertFile: certFile, TLSKeyFile: keyFile})
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- s.Start(context.Background())
+		}()
+		address := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+		waitForListener(t, address)
+
+		before := dialTLS(t, address)
+		oldCert := before.ConnectionState().PeerCertificates[0]
+		_ = before.Close()
+
+		if err := os.WriteFile(certFile, []byte("not a certificate"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ReloadCertificate(); err == nil {
+			t.Fatal("expected an error, got nil")
+		}
+
+		after := dialTLS(t, address)
+		if cert := after.ConnectionState().PeerCertificates[0]; !cert.Equal(oldCert) {
+			t.Fatal("certificate changed after failed reload")
+		}
+		_ = after.Close()
+
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("returns an error from Start if the certificate can't be loaded", func(t *testing.T) {
+		dir := t.TempDir()
+		s := New(Options{Host: "127.0.0.1", Port: freePort(t),
+			TLSCertFile: filepath.Join(dir, "cert.pem"), TLSKeyFile: filepath.Join(dir, "key.pem")})
+		if err := s.Start(context.Background()); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected os.ErrNotExist, got %v", err)
+		}
+	})
+
+	t.Run("does nothing without TLS", func(t *testing.T) {
+		s := New(Options{})
+		if err := s.ReloadCertificate(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// dialTLS opens a TLS connection to address without verifying the certificate, and completes the handshake.
+func dialTLS(t *testing.T, address string) *tls.Conn {
+	t.Helper()
+
+	conn, err := tls.Dial("tcp", address, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// getOverConn sends a GET request for path over conn and returns the response status code.
+func getOverConn(t *testing.T, conn net.Conn, path string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, "https://"+conn.RemoteAddr().String()+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+	return res.StatusCode
+}
+
+// writeSelfSignedCert generates a new self-signed certificate for 127.0.0.1, writes it and its key to the given PEM files,
+// and returns the certificate.
+func writeSelfSignedCert(t *testing.T, certFile, keyFile string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "reloaded"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
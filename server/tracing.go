@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the spans started by traceRequests.
+const tracerName = "canvas/server"
+
+// traceContext propagates spans in the W3C traceparent and tracestate headers.
+var traceContext = propagation.TraceContext{}
+
+// traceRequests is middleware that starts a server span for every request, continuing the trace from the
+// traceparent header, if there is one. Spans are named by method and route pattern like GET /users/{id},
+// and record the status. Server errors set the span status to error. The span is in the request context,
+// so InjectTraceContext and instrumented clients continue the trace in calls to other services.
+func traceRequests(tp trace.TracerProvider) func(http.Handler) http.Handler {
+	tracer := tp.Tracer(tracerName)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := traceContext.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, r.Method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			))
+			defer span.End()
+
+			w, rec := recordResponse(w, r)
+			next.ServeHTTP(w, r.WithContext(ctx))
+
+			status := rec.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			// The route pattern is only known after routing, which happens further down the chain.
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				span.SetName(r.Method + " " + rctx.RoutePattern())
+				span.SetAttributes(semconv.HTTPRoute(rctx.RoutePattern()))
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status), semconv.HTTPResponseBodySize(int(rec.Bytes())))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		})
+	}
+}
+
+// InjectTraceContext from ctx into the traceparent and tracestate headers of h, like for a request to another service,
+// so it continues the trace of the request being handled. It does nothing if ctx has no span.
+func InjectTraceContext(ctx context.Context, h http.Header) {
+	traceContext.Inject(ctx, propagation.HeaderCarrier(h))
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestTraceRequests(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const traceparent = "00-" + traceID + "-00f067aa0ba902b7-01"
+
+	newTracedMux := func() (*chi.Mux, *tracetest.InMemoryExporter) {
+		exporter := tracetest.NewInMemoryExporter()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+		mux := chi.NewMux()
+		mux.Use(traceRequests(tp), withErrorWriting(zap.NewNop, false))
+		return mux, exporter
+	}
+
+	t.Run("records one server span per request, continuing the trace", func(t *testing.T) {
+		mux, exporter := newTracedMux()
+		mux.Get("/things/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+		req := httptest.NewRequest(http.MethodGet, "/things/1", nil)
+		req.Header.Set("traceparent", traceparent)
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := exporter.GetSpans()
+		if len(spans) != 1 {
+			t.Fatalf("expected 1 span, got %v", len(spans))
+		}
+		span := spans[0]
+		if span.Name != "GET /things/{id}" {
+			t.Fatalf("expected span name GET /things/{id}, got %v", span.Name)
+		}
+		if span.SpanKind != trace.SpanKindServer {
+			t.Fatalf("expected a server span, got %v", span.SpanKind)
+		}
+		if id := span.SpanContext.TraceID().String(); id != traceID {
+			t.Fatalf("expected trace ID %v, got %v", traceID, id)
+		}
+		if !span.Parent.IsRemote() {
+			t.Fatal("expected the span to continue the remote trace")
+		}
+
+		attributes := map[attribute.Key]attribute.Value{}
+		for _, a := range span.Attributes {
+			attributes[a.Key] = a.Value
+		}
+		expected := map[attribute.Key]any{
+			"http.request.method":       "GET",
+			"http.route":                "/things/{id}",
+			"http.response.status_code": int64(http.StatusOK),
+			"url.path":                  "/things/1",
+		}
+		for key, value := range expected {
+			if got := attributes[key].AsInterface(); got != value {
+				t.Fatalf("expected attribute %v to be %v, got %v", key, value, got)
+			}
+		}
+		if span.Status.Code == codes.Error {
+			t.Fatalf("expected no error status, got %v", span.Status)
+		}
+	})
+
+	t.Run("records server errors on the span", func(t *testing.T) {
+		mux, exporter := newTracedMux()
+		mux.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			WriteError(w, r, http.StatusInternalServerError, errors.New("oh no"))
+		})
+
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		spans := exporter.GetSpans()
+		if len(spans) != 1 {
+			t.Fatalf("expected 1 span, got %v", len(spans))
+		}
+		if spans[0].Status.Code != codes.Error {
+			t.Fatalf("expected error status, got %v", spans[0].Status)
+		}
+		if len(spans[0].Events) != 1 || spans[0].Events[0].Name != "exception" {
+			t.Fatalf("expected the error to be recorded, got events %v", spans[0].Events)
+		}
+	})
+
+	t.Run("names unmatched requests by method only", func(t *testing.T) {
+		mux, exporter := newTracedMux()
+		mux.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nothing/here", nil))
+
+		if spans := exporter.GetSpans(); len(spans) != 1 || spans[0].Name != "GET" {
+			t.Fatalf("expected 1 span named GET, got %v", spans)
+		}
+	})
+
+	t.Run("lets handlers continue the trace in calls to other services", func(t *testing.T) {
+		mux, exporter := newTracedMux()
+		outgoing := http.Header{}
+		mux.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			InjectTraceContext(r.Context(), outgoing)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("traceparent", traceparent)
+		mux.ServeHTTP(httptest.NewRecorder(), req)
+
+		spanID := exporter.GetSpans()[0].SpanContext.SpanID().String()
+		if got := outgoing.Get("traceparent"); !strings.Contains(got, traceID+"-"+spanID) {
+			t.Fatalf("expected traceparent with trace %v and span %v, got %v", traceID, spanID, got)
+		}
+	})
+
+	t.Run("traces requests on the Server with a TracerProvider", func(t *testing.T) {
+		exporter := tracetest.NewInMemoryExporter()
+		_, address := startServer(t, Options{TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))})
+
+		if code := getStatus(t, "http://"+address+"/healthz/live"); code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, code)
+		}
+		// The span ends after the response is written, so it may take a moment to show up.
+		for i := 0; len(exporter.GetSpans()) == 0 && i < 100; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if spans := exporter.GetSpans(); len(spans) != 1 || spans[0].Name != "GET /healthz/live" {
+			t.Fatalf("expected 1 span named GET /healthz/live, got %v", spans)
+		}
+	})
+}
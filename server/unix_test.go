@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServer_StartUnixSocket(t *testing.T) {
+	t.Run("serves on the socket and removes it on shutdown", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "canvas.sock")
+		s, _ := startServer(t, Options{UnixSocket: path})
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		}}
+		res, err := client.Get("http://canvas/healthz/live")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, res.StatusCode)
+		}
+		client.CloseIdleConnections()
+
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expected socket file to be removed, got %v", err)
+		}
+	})
+
+	t.Run("cleans up a stale socket on startup", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "canvas.sock")
+		stale, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Leave the socket file behind, like a crashed process would.
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		_ = stale.Close()
+
+		startServer(t, Options{UnixSocket: path})
+
+		c, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = c.Close()
+	})
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ValidationError maps input fields to what's wrong with them. WriteError responds with it as
+// 422 Unprocessable Entity and a body like {"errors":{"email":"is required"}}, regardless of the status it's given.
+type ValidationError map[string]string
+
+func (e ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("validation failed")
+	for i, field := range slices.Sorted(maps.Keys(e)) {
+		if i == 0 {
+			b.WriteString(": ")
+		} else {
+			b.WriteString(", ")
+		}
+		b.WriteString(field + " " + e[field])
+	}
+	return b.String()
+}
+
+// writeValidationError responds with 422 Unprocessable Entity and the field errors of e,
+// as JSON if the client accepts it, and as one line per field otherwise.
+func writeValidationError(w http.ResponseWriter, r *http.Request, e ValidationError) {
+	if acceptsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(struct {
+			Errors ValidationError `json:"errors"`
+		}{Errors: e})
+		return
+	}
+
+	var lines []string
+	for _, field := range slices.Sorted(maps.Keys(e)) {
+		lines = append(lines, field+": "+e[field])
+	}
+	http.Error(w, strings.Join(lines, "\n"), http.StatusUnprocessableEntity)
+}
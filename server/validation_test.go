@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestWriteError_validation(t *testing.T) {
+	validationErr := ValidationError{"email": "is required", "age": "must be positive"}
+
+	tests := []struct {
+		name        string
+		accept      string
+		err         error
+		status      int
+		contentType string
+		body        string
+	}{
+		{name: "writes field errors as JSON with status 422", accept: "application/json", err: validationErr,
+			status: http.StatusUnprocessableEntity, contentType: "application/json",
+			body: `{"errors":{"age":"must be positive","email":"is required"}}`},
+		{name: "finds wrapped validation errors", accept: "application/json", err: fmt.Errorf("creating thing: %w", validationErr),
+			status: http.StatusUnprocessableEntity, contentType: "application/json",
+			body: `{"errors":{"age":"must be positive","email":"is required"}}`},
+		{name: "writes one line per field as plain text", err: validationErr,
+			status: http.StatusUnprocessableEntity, contentType: "text/plain; charset=utf-8",
+			body: "age: must be positive\nemail: is required"},
+		{name: "keeps a generic message for plain errors", accept: "application/json", err: errors.New("database down"),
+			status: http.StatusInternalServerError, contentType: "application/json", body: `{"error":"Internal Server Error"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := withErrorWriting(zap.NewNop, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				WriteError(w, r, http.StatusInternalServerError, test.err)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, req)
+
+			if res.Code != test.status {
+				t.Fatalf("expected status %v, got %v", test.status, res.Code)
+			}
+			if contentType := res.Header().Get("Content-Type"); contentType != test.contentType {
+				t.Fatalf("expected content type %v, got %v", test.contentType, contentType)
+			}
+			if body := strings.TrimSpace(res.Body.String()); body != test.body {
+				t.Fatalf("expected body %v, got %v", test.body, body)
+			}
+		})
+	}
+}
+
+func TestValidationError_Error(t *testing.T) {
+	t.Run("lists fields in order", func(t *testing.T) {
+		err := ValidationError{"name": "is too long", "email": "is required"}
+		if message, expected := err.Error(), "validation failed: email is required, name is too long"; message != expected {
+			t.Fatalf("expected %q, got %q", expected, message)
+		}
+	})
+}
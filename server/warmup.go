@@ -0,0 +1,28 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// warmUp runs the Warmup hook within the WarmupTimeout, and makes the Server report ready if it succeeds.
+// If it fails, the Server keeps serving, but the readiness probe keeps failing.
+func (s *Server) warmUp(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.warmupTimeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := s.warmup(ctx); err != nil {
+		s.logger().Error("Error warming up, not reporting ready", zap.Error(err), zap.Duration("duration", time.Since(start)))
+		return nil
+	}
+
+	// Don't report ready again if the Server started stopping in the meantime.
+	if s.State() == StateRunning {
+		s.ready.Store(true)
+	}
+	s.logger().Info("Warmed up", zap.Duration("duration", time.Since(start)))
+	return nil
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestServer_Warmup(t *testing.T) {
+	t.Run("reports not ready until warm-up completes", func(t *testing.T) {
+		release := make(chan struct{})
+		s := New(Options{Host: "localhost", Warmup: func(ctx context.Context) error {
+			<-release
+			return nil
+		}})
+		address := startTestServer(t, s)
+
+		if code := getStatus(t, "http://"+address+"/healthz/live"); code != http.StatusOK {
+			t.Fatalf("expected status %v for liveness during warm-up, got %v", http.StatusOK, code)
+		}
+		if code := getStatus(t, "http://"+address+"/healthz/ready"); code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v for readiness during warm-up, got %v", http.StatusServiceUnavailable, code)
+		}
+
+		close(release)
+		waitForStatus(t, "http://"+address+"/healthz/ready", http.StatusOK)
+	})
+
+	t.Run("stays not ready and logs if warm-up fails", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		s := New(Options{Host: "localhost", Log: zap.New(core), Warmup: func(ctx context.Context) error {
+			return errors.New("no cache for you")
+		}})
+		address := startTestServer(t, s)
+
+		waitForLog(t, logs, "Error warming up, not reporting ready")
+		if code := getStatus(t, "http://"+address+"/healthz/ready"); code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status %v, got %v", http.StatusServiceUnavailable, code)
+		}
+	})
+
+	t.Run("gives warm-up a timeout", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		s := New(Options{Host: "localhost", Log: zap.New(core), WarmupTimeout: 10 * time.Millisecond,
+			Warmup: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}})
+		startTestServer(t, s)
+
+		entry := waitForLog(t, logs, "Error warming up, not reporting ready")
+		if err := entry.ContextMap()["error"]; err != context.DeadlineExceeded.Error() {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+		if s.ready.Load() {
+			t.Fatal("ready after warm-up timed out")
+		}
+	})
+}
+
+// waitForStatus polls url until it responds with status.
+func waitForStatus(t *testing.T, url string, status int) {
+	t.Helper()
+
+	var code int
+	for i := 0; i < 100; i++ {
+		if code = getStatus(t, url); code == status {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected status %v, got %v", status, code)
+}
+
+// waitForLog polls logs until there's an entry with message, and returns it.
+func waitForLog(t *testing.T, logs *observer.ObservedLogs, message string) observer.LoggedEntry {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if entries := logs.FilterMessage(message).All(); len(entries) > 0 {
+			return entries[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no log entry %q", message)
+	return observer.LoggedEntry{}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"net/http"
+)
+
+const workerPoolContextKey = contextKey("workerPool")
+
+// WorkerPool caps how much expensive work, like CPU-bound parts of handlers, runs at the same time,
+// independently of how many requests are handled. A nil WorkerPool runs all work right away.
+type WorkerPool struct {
+	sem chan struct{}
+}
+
+// NewWorkerPool that runs at most size pieces of work at the same time. A size below one is treated as one.
+func NewWorkerPool(size int) *WorkerPool {
+	return &WorkerPool{sem: make(chan struct{}, max(size, 1))}
+}
+
+// Submit work to the pool, and wait for it to finish. The work runs in the calling goroutine once a slot is free.
+// If ctx is done before that, the work doesn't run, and ctx.Err() is returned. Otherwise, the error of work is returned.
+func (p *WorkerPool) Submit(ctx context.Context, work func() error) error {
+	if p == nil {
+		return work()
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() {
+		<-p.sem
+	}()
+
+	// Prefer giving up over starting work that nobody waits for anymore.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return work()
+}
+
+// WorkerPool of the Server, sized by Options.WorkerPoolSize. Handlers can also get it with WorkerPoolFromContext.
+func (s *Server) WorkerPool() *WorkerPool {
+	return s.workerPool
+}
+
+// WorkerPoolFromContext returns the WorkerPool of the Server handling the request that ctx belongs to.
+// Outside of a Server, it returns nil, which runs work without a limit.
+func WorkerPoolFromContext(ctx context.Context) *WorkerPool {
+	p, _ := ctx.Value(workerPoolContextKey).(*WorkerPool)
+	return p
+}
+
+// withWorkerPool is middleware that stores p in the request context for WorkerPoolFromContext.
+func withWorkerPool(p *WorkerPool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), workerPoolContextKey, p)))
+		})
+	}
+}
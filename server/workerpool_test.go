@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkerPool_Submit(t *testing.T) {
+	t.Run("runs at most size pieces of work at the same time", func(t *testing.T) {
+		p := NewWorkerPool(2)
+		var running, most atomic.Int64
+		release := make(chan struct{})
+		started := make(chan struct{}, 5)
+
+		var wg sync.WaitGroup
+		for range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := p.Submit(context.Background(), func() error {
+					n := running.Add(1)
+					for {
+						m := most.Load()
+						if n <= m || most.CompareAndSwap(m, n) {
+							break
+						}
+					}
+					started <- struct{}{}
+					<-release
+					running.Add(-1)
+					return nil
+				})
+				if err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+
+		<-started
+		<-started
+		select {
+		case <-started:
+			t.Fatal("third piece of work started while the pool was full")
+		default:
+		}
+
+		close(release)
+		wg.Wait()
+		if n := most.Load(); n != 2 {
+			t.Fatalf("expected at most 2 running, got %v", n)
+		}
+	})
+
+	t.Run("returns the error of the work", func(t *testing.T) {
+		err := NewWorkerPool(1).Submit(context.Background(), func() error {
+			return errors.New("oh no")
+		})
+		if err == nil || err.Error() != "oh no" {
+			t.Fatalf("expected oh no, got %v", err)
+		}
+	})
+
+	t.Run("returns the context error when cancelled while the pool is full", func(t *testing.T) {
+		p := NewWorkerPool(1)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		go func() {
+			_ = p.Submit(context.Background(), func() error {
+				close(started)
+				<-release
+				return nil
+			})
+		}()
+		<-started
+		defer close(release)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errs := make(chan error, 1)
+		var ran atomic.Bool
+		go func() {
+			errs <- p.Submit(ctx, func() error {
+				ran.Store(true)
+				return nil
+			})
+		}()
+		cancel()
+
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if ran.Load() {
+			t.Fatal("work ran after cancellation")
+		}
+	})
+
+	t.Run("does not run work with a context that is already done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		var ran bool
+		err := NewWorkerPool(1).Submit(ctx, func() error {
+			ran = true
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+		if ran {
+			t.Fatal("work ran after cancellation")
+		}
+	})
+
+	t.Run("runs work right away on a nil pool", func(t *testing.T) {
+		var p *WorkerPool
+		var ran bool
+		if err := p.Submit(context.Background(), func() error {
+			ran = true
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if !ran {
+			t.Fatal("work did not run")
+		}
+	})
+}
+
+func TestServer_WorkerPool(t *testing.T) {
+	t.Run("is in the request context", func(t *testing.T) {
+		s := New(Options{WorkerPoolSize: 3})
+		var p *WorkerPool
+		s.mux.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			p = WorkerPoolFromContext(r.Context())
+		})
+
+		s.mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if p == nil || p != s.WorkerPool() {
+			t.Fatal("worker pool not in request context")
+		}
+		if size := cap(p.sem); size != 3 {
+			t.Fatalf("expected size 3, got %v", size)
+		}
+	})
+
+	t.Run("defaults to GOMAXPROCS", func(t *testing.T) {
+		s := New(Options{})
+		if size, want := cap(s.WorkerPool().sem), runtime.GOMAXPROCS(0); size != want {
+			t.Fatalf("expected size %v, got %v", want, size)
+		}
+	})
+}